@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"github.com/sultaniman/confetti/platform/keys"
+)
+
+// loadKeyring loads all keys listed in keyring manifest,
+// if manifest is not configured then single key is loaded.
+func loadKeyring() (*keys.Keyring, error) {
+	manifest := keys.SingleKeyManifest(viper.GetString("key_id"), viper.GetString("key_path"))
+	if manifestPath := viper.GetString("keyring_path"); manifestPath != "" {
+		keyringManifest, err := keys.ReadManifest(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+
+		manifest = keyringManifest
+	}
+
+	keyLoader := keys.GetLoader(viper.GetString("key_loader"))
+	return keys.LoadKeyring(keyLoader, manifest)
+}
+
+// loadSigningKeyring loads token signing keys from signing keyring manifest or a single
+// signing key. Signing with the active encryption key is only allowed when it is enabled
+// explicitly with signing_legacy_key for deployments which have not moved to signing keys yet.
+func loadSigningKeyring(keyring *keys.Keyring) (*keys.SigningKeyring, error) {
+	keyLoader := keys.GetLoader(viper.GetString("key_loader"))
+	if manifestPath := viper.GetString("signing_keyring_path"); manifestPath != "" {
+		manifest, err := keys.ReadManifest(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+
+		return keys.LoadSigningKeyring(keyLoader, manifest)
+	}
+
+	if keyPath := viper.GetString("signing_key_path"); keyPath != "" {
+		manifest := keys.SingleKeyManifest(viper.GetString("signing_key_id"), keyPath)
+		manifest.Keys[0].Algorithm = viper.GetString("signing_algorithm")
+		return keys.LoadSigningKeyring(keyLoader, manifest)
+	}
+
+	if !viper.GetBool("signing_legacy_key") {
+		return nil, fmt.Errorf("signing key is not configured, please set signing_key_path or signing_keyring_path")
+	}
+
+	activeKey, err := keyring.Active()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Warn().Msg("Signing tokens with the encryption key is deprecated, please configure a signing key")
+	return keys.LegacySigningKeyring(activeKey), nil
+}
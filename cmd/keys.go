@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/sultaniman/confetti/platform/db"
+	"github.com/sultaniman/confetti/platform/repo"
+	"github.com/sultaniman/confetti/platform/services"
+)
+
+const DefaultRotationBatchSize = 500
+
+var (
+	fromKeyID string
+	toKeyID   string
+	batchSize uint64
+	dryRun    bool
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage encryption keys",
+	Long:  "Manage encryption keys",
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = cmd.Usage()
+	},
+}
+
+var keysRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Re-encrypt card passphrases with a new key",
+	Long: `Re-encrypt card passphrases with a new key.
+Cards are processed in batches and each batch is committed separately
+so if rotation is interrupted it can be safely started again.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cardService, err := newCardService()
+		if err != nil {
+			return err
+		}
+
+		if dryRun {
+			usage, err := cardService.KeyUsage()
+			if err != nil {
+				return err
+			}
+
+			for _, keyUsage := range usage {
+				fmt.Printf("key_id=%s cards=%d\n", keyUsage.KeyID, keyUsage.Cards)
+			}
+
+			return nil
+		}
+
+		if fromKeyID == "" {
+			return fmt.Errorf("please specify key to rotate from using --from")
+		}
+
+		if batchSize == 0 {
+			return fmt.Errorf("--batch-size must be greater than 0")
+		}
+
+		rotated, err := cardService.RotateKeys(fromKeyID, toKeyID, batchSize)
+		fmt.Printf("Rotated %d cards from key_id=%s\n", rotated, fromKeyID)
+		return err
+	},
+}
+
+func newCardService() (services.CardService, error) {
+	conn, err := db.Connect(viper.GetString("db_uri"))
+	if err != nil {
+		return nil, err
+	}
+
+	keyring, err := loadKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	baseRepo := repo.New(conn)
+	return services.NewCardService(
+		repo.NewUserRepo(baseRepo),
+		repo.NewCardRepo(baseRepo),
+		repo.NewAuditRepo(baseRepo),
+		keyring,
+	), nil
+}
+
+func init() {
+	keysRotateCmd.Flags().StringVar(&fromKeyID, "from", "", "key id to rotate from")
+	keysRotateCmd.Flags().StringVar(&toKeyID, "to", "", "key id to rotate to (default active key)")
+	keysRotateCmd.Flags().Uint64Var(&batchSize, "batch-size", DefaultRotationBatchSize, "cards per transaction")
+	keysRotateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only report how many cards use each key")
+	keysCmd.AddCommand(keysRotateCmd)
+}
@@ -34,6 +34,7 @@ func init() {
 
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(keysCmd)
 	rootCmd.AddCommand(testCmd)
 }
 
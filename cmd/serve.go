@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/viper"
 	"github.com/sultaniman/confetti/platform/db"
 	"github.com/sultaniman/confetti/platform/handlers"
+	"github.com/sultaniman/confetti/platform/jobs"
 	"github.com/sultaniman/confetti/platform/keys"
 	"time"
 )
@@ -28,17 +29,25 @@ var serveCmd = &cobra.Command{
 		db.SetMaxOpenConns(maxOpen)
 		db.SetConnMaxLifetime(time.Hour)
 
-		keyLoader := keys.GetLoader(viper.GetString("key_loader"))
-		key, err := keyLoader.Load(viper.GetString("key_path"))
+		keyring, err := loadKeyring()
 		if err != nil {
 			return err
 		}
 
-		handler, err := handlers.NewHandler(db, key)
+		signingKeyring, err := loadSigningKeyring(keyring)
 		if err != nil {
 			return err
 		}
 
+		handler, err := handlers.NewHandler(db, keyring, signingKeyring)
+		if err != nil {
+			return err
+		}
+
+		jobs.Every(cmd.Context(), "purge_deleted_users", viper.GetDuration("deletion_purge_interval"), handler.UserService.PurgeDeleted)
+		jobs.Every(cmd.Context(), "delete_expired_action_codes", viper.GetDuration("action_code_sweep_interval"), handler.UserService.DeleteExpiredActionCodes)
+		jobs.Every(cmd.Context(), "delete_stale_login_attempts", viper.GetDuration("deletion_purge_interval"), handler.LockoutService.DeleteStale)
+
 		app := handlers.App(handler)
 		return app.Listen(fmt.Sprintf(":%d", port))
 	},
@@ -49,8 +58,52 @@ func init() {
 	viper.SetDefault("database_max_open", 50)
 	viper.SetDefault("database_max_idle", 20)
 	viper.SetDefault("private_key", "")
+	viper.SetDefault("key_id", keys.DefaultKeyID)
+	viper.SetDefault("keyring_path", "")
+	viper.SetDefault("signing_key_id", "s1")
+	viper.SetDefault("signing_key_path", "")
+	viper.SetDefault("signing_algorithm", "") // RS256, ES256, ES384 or EdDSA, derived from key by default
+	viper.SetDefault("signing_keyring_path", "")
+	viper.SetDefault("signing_legacy_key", false)  // deprecated, signs tokens with the encryption key
 	viper.SetDefault("refresh_token_ttl", "4320h") // 180 days
 	viper.SetDefault("access_token_ttl", "1h")     // 1 hour
+	viper.SetDefault("admin_access_token_ttl", "10m")
+	viper.SetDefault("mfa_token_ttl", "5m")
+	viper.SetDefault("rate_limit_auth_token", "20/1m") // requests/period, empty disables
+	viper.SetDefault("rate_limit_register", "5/1h")
+	viper.SetDefault("rate_limit_reset_password", "5/1h")
+	viper.SetDefault("rate_limit_resend_confirmation", "3/1h")
+	viper.SetDefault("rate_limit_restore_account", "5/1h")
+	viper.SetDefault("rate_limit_magic_link", "5/1h")
+	viper.SetDefault("rate_limit_magic_link_token", "20/1m")
+	viper.SetDefault("rate_limit_mfa_token", "10/1m")
+	viper.SetDefault("rate_limit_step_up", "10/1m")
+	viper.SetDefault("rate_limit_webauthn_login_begin", "20/1m")
+	viper.SetDefault("rate_limit_webauthn_login_finish", "20/1m")
+	viper.SetDefault("rate_limit_webauthn_mfa_begin", "10/1m")
+	viper.SetDefault("rate_limit_webauthn_mfa_finish", "10/1m")
+	viper.SetDefault("rate_limit_oidc_authorize", "20/1m")
+	viper.SetDefault("rate_limit_oidc_callback", "20/1m")
+	viper.SetDefault("lockout_store", "postgres") // postgres or memory
+	viper.SetDefault("lockout_max_failures", 5)
+	viper.SetDefault("lockout_max_ip_failures", 50)
+	viper.SetDefault("lockout_window", "15m")
+	viper.SetDefault("lockout_duration", "15m")
+	viper.SetDefault("lockout_backoff_base", "1s")
+	viper.SetDefault("lockout_backoff_max", "1m")
+	viper.SetDefault("confirmation_code_ttl", "1h")
+	viper.SetDefault("password_reset_code_ttl", "15m")
+	viper.SetDefault("email_change_code_ttl", "1h")
+	viper.SetDefault("magic_link_code_ttl", "15m")
+	viper.SetDefault("action_code_sweep_interval", "1h")
+	viper.SetDefault("deletion_grace_period", "720h") // 30 days to restore deleted account
+	viper.SetDefault("deletion_purge_interval", "1h")
+	viper.SetDefault("step_up_ttl", "5m") // how recent authentication must be to decrypt cards
+	viper.SetDefault("totp_issuer", "Confetti")
+	viper.SetDefault("webauthn_rp_id", "") // defaults to base_url host
+	viper.SetDefault("webauthn_rp_name", "Confetti")
+	viper.SetDefault("webauthn_origins", "") // comma separated, defaults to base_url
+	viper.SetDefault("oidc_providers", "")   // comma separated provider names
 	viper.SetDefault("mailer", "dummy")
 	viper.SetDefault("from_email", "no-reply@secura.team")
 	viper.SetDefault("verbose", false)
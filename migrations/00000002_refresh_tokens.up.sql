@@ -0,0 +1,17 @@
+CREATE TABLE refresh_tokens
+(
+    jti        UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id    UUID NOT NULL,
+    issued_at  TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc'::text, CURRENT_TIMESTAMP),
+    expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITHOUT TIME ZONE NULL,
+    user_agent VARCHAR(512) NULL,
+    ip_address VARCHAR(64) NULL,
+
+    CONSTRAINT fk_refresh_tokens_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX ix_refresh_tokens_user_id ON refresh_tokens (user_id);
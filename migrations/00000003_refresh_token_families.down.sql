@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS ix_refresh_tokens_family_id;
+
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS replaced_by;
+
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS family_id;
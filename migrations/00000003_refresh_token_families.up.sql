@@ -0,0 +1,16 @@
+-- every login starts a new family of refresh tokens,
+-- each refresh rotates the token within the same family
+ALTER TABLE refresh_tokens
+    ADD COLUMN family_id UUID NULL;
+
+ALTER TABLE refresh_tokens
+    ADD COLUMN replaced_by UUID NULL;
+
+UPDATE refresh_tokens
+SET family_id = jti
+WHERE family_id IS NULL;
+
+ALTER TABLE refresh_tokens
+    ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX ix_refresh_tokens_family_id ON refresh_tokens (family_id);
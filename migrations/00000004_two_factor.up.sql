@@ -0,0 +1,31 @@
+-- TOTP secret is encrypted with server key same as card passphrases
+CREATE TABLE user_totp
+(
+    user_id          UUID PRIMARY KEY,
+    encrypted_secret VARCHAR(2048) NOT NULL,
+    key_id           VARCHAR(20)   NOT NULL,
+    last_used_step   BIGINT        NOT NULL DEFAULT 0,
+    confirmed_at     TIMESTAMP WITHOUT TIME ZONE NULL,
+    created_at       TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc'::text, CURRENT_TIMESTAMP),
+
+    CONSTRAINT fk_user_totp_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes
+(
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id    UUID        NOT NULL,
+    code_hash  VARCHAR(64) NOT NULL,
+    used_at    TIMESTAMP WITHOUT TIME ZONE NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc'::text, CURRENT_TIMESTAMP),
+
+    CONSTRAINT fk_recovery_codes_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX ix_recovery_codes_user_id ON recovery_codes (user_id);
@@ -0,0 +1,36 @@
+-- credential id and COSE public key are stored as base64url
+CREATE TABLE webauthn_credentials
+(
+    id            UUID PRIMARY KEY       DEFAULT uuid_generate_v4(),
+    user_id       UUID          NOT NULL,
+    name          VARCHAR(255) NULL,
+    credential_id VARCHAR(1024) NOT NULL,
+    public_key    TEXT          NOT NULL,
+    aaguid        VARCHAR(64) NULL,
+    sign_count    BIGINT        NOT NULL DEFAULT 0,
+    created_at    TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc'::text, CURRENT_TIMESTAMP),
+    last_used_at  TIMESTAMP WITHOUT TIME ZONE NULL,
+
+    CONSTRAINT fk_webauthn_credentials_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX ix_webauthn_credentials_credential_id ON webauthn_credentials (credential_id);
+CREATE INDEX ix_webauthn_credentials_user_id ON webauthn_credentials (user_id);
+
+-- pending ceremonies, challenge is removed once it is used
+CREATE TABLE webauthn_challenges
+(
+    challenge  VARCHAR(128) PRIMARY KEY,
+    user_id    UUID NULL,
+    ceremony   VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc'::text, CURRENT_TIMESTAMP),
+
+    CONSTRAINT fk_webauthn_challenges_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
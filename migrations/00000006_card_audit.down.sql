@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS tr_card_audit_log_immutable ON card_audit_log;
+DROP FUNCTION IF EXISTS card_audit_log_immutable();
+DROP TABLE IF EXISTS card_audit_log;
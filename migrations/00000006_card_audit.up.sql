@@ -0,0 +1,36 @@
+-- card_id and actor_id have no foreign keys so that history outlives deleted cards and users
+CREATE TABLE card_audit_log
+(
+    id         UUID PRIMARY KEY     DEFAULT uuid_generate_v4(),
+    actor_id   UUID        NOT NULL,
+    card_id    UUID        NOT NULL,
+    action     VARCHAR(20) NOT NULL,
+    request_id VARCHAR(64) NULL,
+    ip_address VARCHAR(64) NULL,
+    user_agent TEXT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc'::text, CURRENT_TIMESTAMP)
+);
+
+CREATE INDEX ix_card_audit_log_card_id ON card_audit_log (card_id, created_at DESC);
+CREATE INDEX ix_card_audit_log_actor_id ON card_audit_log (actor_id, created_at DESC);
+
+-- audit records are append only, client details may only be cleared once actor is deleted
+CREATE OR REPLACE FUNCTION card_audit_log_immutable() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.ip_address IS NULL AND NEW.user_agent IS NULL AND
+       (NEW.id, NEW.actor_id, NEW.card_id, NEW.action, NEW.request_id, NEW.created_at) IS NOT DISTINCT FROM
+       (OLD.id, OLD.actor_id, OLD.card_id, OLD.action, OLD.request_id, OLD.created_at) AND
+       NOT EXISTS(SELECT 1 FROM users WHERE id = OLD.actor_id) THEN
+        RETURN NEW;
+    END IF;
+
+    RAISE EXCEPTION 'card_audit_log records can not be modified';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tr_card_audit_log_immutable
+    BEFORE UPDATE OR DELETE
+    ON card_audit_log
+    FOR EACH ROW
+EXECUTE PROCEDURE card_audit_log_immutable();
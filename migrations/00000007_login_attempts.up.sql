@@ -0,0 +1,8 @@
+-- failed sign in attempts per account (email:...) and per client (ip:...)
+CREATE TABLE login_attempts
+(
+    key             VARCHAR(320) PRIMARY KEY,
+    failures        INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    locked_until    TIMESTAMP WITHOUT TIME ZONE NULL
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS ix_cards_user_id_title;
+DROP INDEX IF EXISTS ix_cards_user_id_updated_at;
+DROP INDEX IF EXISTS ix_cards_user_id_created_at;
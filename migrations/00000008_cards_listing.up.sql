@@ -0,0 +1,4 @@
+-- keyset pagination orders by (column, id) within user cards
+CREATE INDEX ix_cards_user_id_created_at ON cards (user_id, created_at, id);
+CREATE INDEX ix_cards_user_id_updated_at ON cards (user_id, updated_at, id);
+CREATE INDEX ix_cards_user_id_title ON cards (user_id, title, id);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS tr_admin_audit_log_immutable ON admin_audit_log;
+DROP FUNCTION IF EXISTS admin_audit_log_immutable();
+DROP TABLE IF EXISTS admin_audit_log;
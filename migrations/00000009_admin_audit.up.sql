@@ -0,0 +1,39 @@
+-- user_id has no foreign key so that history outlives deleted users
+CREATE TABLE admin_audit_log
+(
+    id         UUID PRIMARY KEY     DEFAULT uuid_generate_v4(),
+    admin_id   UUID NULL,
+    user_id    UUID        NOT NULL,
+    action     VARCHAR(40) NOT NULL,
+    request_id VARCHAR(64) NULL,
+    ip_address VARCHAR(64) NULL,
+    user_agent TEXT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc'::text, CURRENT_TIMESTAMP),
+
+    CONSTRAINT fk_admin_audit_log_admin
+        FOREIGN KEY (admin_id)
+            REFERENCES users (id)
+            ON DELETE SET NULL
+);
+
+CREATE INDEX ix_admin_audit_log_user_id ON admin_audit_log (user_id, created_at DESC);
+CREATE INDEX ix_admin_audit_log_created_at ON admin_audit_log (created_at DESC);
+
+-- audit records are append only, admin_id may only be cleared when admin is deleted
+CREATE OR REPLACE FUNCTION admin_audit_log_immutable() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF NEW.admin_id IS NULL AND OLD.admin_id IS NOT NULL AND
+       (NEW.id, NEW.user_id, NEW.action, NEW.created_at) = (OLD.id, OLD.user_id, OLD.action, OLD.created_at) THEN
+        RETURN NEW;
+    END IF;
+
+    RAISE EXCEPTION 'admin_audit_log records can not be modified';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tr_admin_audit_log_immutable
+    BEFORE UPDATE
+    ON admin_audit_log
+    FOR EACH ROW
+EXECUTE PROCEDURE admin_audit_log_immutable();
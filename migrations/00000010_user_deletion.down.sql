@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS ix_users_deletion_requested_at;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS inactive_reason;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS deletion_requested_at;
@@ -0,0 +1,14 @@
+-- set when user asks to delete account, account is purged after grace period
+ALTER TABLE users
+    ADD COLUMN deletion_requested_at TIMESTAMP WITHOUT TIME ZONE NULL;
+
+-- why account is inactive, only accounts disabled by deletion request can be restored by their owner
+ALTER TABLE users
+    ADD COLUMN inactive_reason VARCHAR(20) NULL;
+
+UPDATE users
+SET inactive_reason = 'deactivated'
+WHERE is_active = FALSE;
+
+CREATE INDEX ix_users_deletion_requested_at ON users (deletion_requested_at)
+    WHERE deletion_requested_at IS NOT NULL;
@@ -0,0 +1,15 @@
+CREATE TABLE email_changes
+(
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id    UUID         NOT NULL,
+    code       VARCHAR(40)  NOT NULL,
+    new_email  VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc'::text, CURRENT_TIMESTAMP),
+
+    CONSTRAINT fk_email_changes_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX ix_email_changes_code ON email_changes (code);
@@ -0,0 +1,33 @@
+DELETE FROM user_confirmations;
+DELETE FROM password_resets;
+DELETE FROM email_changes;
+
+DROP INDEX IF EXISTS ix_user_confirmations_expires_at;
+DROP INDEX IF EXISTS ix_password_resets_expires_at;
+DROP INDEX IF EXISTS ix_email_changes_expires_at;
+
+DROP INDEX IF EXISTS ix_user_confirmations_code_hash;
+DROP INDEX IF EXISTS ix_password_resets_code_hash;
+DROP INDEX IF EXISTS ix_email_changes_code_hash;
+
+ALTER TABLE user_confirmations
+    DROP COLUMN code_hash,
+    DROP COLUMN expires_at,
+    DROP COLUMN used_at,
+    ADD COLUMN code VARCHAR(40) NOT NULL;
+
+ALTER TABLE password_resets
+    DROP COLUMN code_hash,
+    DROP COLUMN expires_at,
+    DROP COLUMN used_at,
+    ADD COLUMN code VARCHAR(40) NOT NULL;
+
+ALTER TABLE email_changes
+    DROP COLUMN code_hash,
+    DROP COLUMN expires_at,
+    DROP COLUMN used_at,
+    ADD COLUMN code VARCHAR(40) NOT NULL;
+
+CREATE INDEX ix_user_confirmations_code ON user_confirmations (code);
+CREATE INDEX ix_password_resets_code ON password_resets (code);
+CREATE INDEX ix_email_changes_code ON email_changes (code);
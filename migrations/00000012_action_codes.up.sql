@@ -0,0 +1,35 @@
+-- codes were stored in plaintext and can not be hashed without knowing their type ttl,
+-- outstanding codes are dropped and users have to request new ones
+DELETE FROM user_confirmations;
+DELETE FROM password_resets;
+DELETE FROM email_changes;
+
+DROP INDEX IF EXISTS ix_user_confirmations_code;
+DROP INDEX IF EXISTS ix_password_resets_code;
+DROP INDEX IF EXISTS ix_email_changes_code;
+
+ALTER TABLE user_confirmations
+    DROP COLUMN code,
+    ADD COLUMN code_hash  VARCHAR(64) NOT NULL,
+    ADD COLUMN expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    ADD COLUMN used_at    TIMESTAMP WITHOUT TIME ZONE NULL;
+
+ALTER TABLE password_resets
+    DROP COLUMN code,
+    ADD COLUMN code_hash  VARCHAR(64) NOT NULL,
+    ADD COLUMN expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    ADD COLUMN used_at    TIMESTAMP WITHOUT TIME ZONE NULL;
+
+ALTER TABLE email_changes
+    DROP COLUMN code,
+    ADD COLUMN code_hash  VARCHAR(64) NOT NULL,
+    ADD COLUMN expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    ADD COLUMN used_at    TIMESTAMP WITHOUT TIME ZONE NULL;
+
+CREATE UNIQUE INDEX ix_user_confirmations_code_hash ON user_confirmations (code_hash);
+CREATE UNIQUE INDEX ix_password_resets_code_hash ON password_resets (code_hash);
+CREATE UNIQUE INDEX ix_email_changes_code_hash ON email_changes (code_hash);
+
+CREATE INDEX ix_user_confirmations_expires_at ON user_confirmations (expires_at);
+CREATE INDEX ix_password_resets_expires_at ON password_resets (expires_at);
+CREATE INDEX ix_email_changes_expires_at ON email_changes (expires_at);
@@ -0,0 +1,17 @@
+CREATE TABLE magic_links
+(
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id    UUID        NOT NULL,
+    code_hash  VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc'::text, CURRENT_TIMESTAMP),
+    expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    used_at    TIMESTAMP WITHOUT TIME ZONE NULL,
+
+    CONSTRAINT fk_magic_links_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX ix_magic_links_code_hash ON magic_links (code_hash);
+CREATE INDEX ix_magic_links_expires_at ON magic_links (expires_at);
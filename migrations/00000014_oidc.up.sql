@@ -0,0 +1,32 @@
+-- pending authorization requests, removed once callback is handled
+CREATE TABLE oidc_states
+(
+    state         VARCHAR(64) PRIMARY KEY,
+    provider      VARCHAR(56) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    nonce         VARCHAR(64) NOT NULL,
+    expires_at    TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    created_at    TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc'::text, CURRENT_TIMESTAMP)
+);
+
+CREATE INDEX ix_oidc_states_expires_at ON oidc_states (expires_at);
+
+-- accounts at external identity providers linked to users
+CREATE TABLE user_identities
+(
+    id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id       UUID         NOT NULL,
+    provider      VARCHAR(56)  NOT NULL,
+    subject       VARCHAR(255) NOT NULL,
+    email         VARCHAR(100) NULL,
+    created_at    TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc'::text, CURRENT_TIMESTAMP),
+    last_login_at TIMESTAMP WITHOUT TIME ZONE NULL,
+
+    CONSTRAINT fk_user_identities_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX ix_user_identities_provider_subject ON user_identities (provider, subject);
+CREATE INDEX ix_user_identities_user_id ON user_identities (user_id);
@@ -0,0 +1,22 @@
+CREATE TABLE personal_access_tokens
+(
+    id           UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id      UUID         NOT NULL,
+    name         VARCHAR(255) NOT NULL,
+    token_hash   VARCHAR(64)  NOT NULL,
+    -- beginning of the token to tell tokens apart, rest of it is only known to the owner
+    token_prefix VARCHAR(16)  NOT NULL,
+    -- space separated list of scopes
+    scopes       TEXT         NOT NULL,
+    expires_at   TIMESTAMP WITHOUT TIME ZONE NULL,
+    last_used_at TIMESTAMP WITHOUT TIME ZONE NULL,
+    created_at   TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc'::text, CURRENT_TIMESTAMP),
+
+    CONSTRAINT fk_personal_access_tokens_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX ix_personal_access_tokens_token_hash ON personal_access_tokens (token_hash);
+CREATE INDEX ix_personal_access_tokens_user_id ON personal_access_tokens (user_id);
@@ -0,0 +1,68 @@
+package entities
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type CardAction string
+
+const (
+	CardDecrypted CardAction = "decrypt"
+	CardCreated   CardAction = "create"
+	CardUpdated   CardAction = "update"
+	CardDeleted   CardAction = "delete"
+)
+
+type NewCardAuditRecord struct {
+	ActorId   uuid.UUID
+	CardId    uuid.UUID
+	Action    CardAction
+	RequestId string
+	IPAddress string
+	UserAgent string
+}
+
+type CardAuditRecord struct {
+	ID        uuid.UUID `db:"id"`
+	ActorId   uuid.UUID `db:"actor_id"`
+	CardId    uuid.UUID `db:"card_id"`
+	Action    string    `db:"action"`
+	RequestId *string   `db:"request_id"`
+	IPAddress *string   `db:"ip_address"`
+	UserAgent *string   `db:"user_agent"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type AdminAction string
+
+const (
+	UserDeactivated   AdminAction = "deactivate"
+	UserActivated     AdminAction = "activate"
+	AdminGranted      AdminAction = "grant_admin"
+	AdminRevoked      AdminAction = "revoke_admin"
+	UserForceConfirm  AdminAction = "confirm"
+	PasswordResetSent AdminAction = "password_reset"
+	LockoutCleared    AdminAction = "clear_lockout"
+	UserDeleted       AdminAction = "delete"
+)
+
+type NewAdminAuditRecord struct {
+	AdminId   uuid.UUID
+	UserId    uuid.UUID
+	Action    AdminAction
+	RequestId string
+	IPAddress string
+	UserAgent string
+}
+
+type AdminAuditRecord struct {
+	ID        uuid.UUID  `db:"id"`
+	AdminId   *uuid.UUID `db:"admin_id"`
+	UserId    uuid.UUID  `db:"user_id"`
+	Action    string     `db:"action"`
+	RequestId *string    `db:"request_id"`
+	IPAddress *string    `db:"ip_address"`
+	UserAgent *string    `db:"user_agent"`
+	CreatedAt time.Time  `db:"created_at"`
+}
@@ -23,7 +23,17 @@ type Card struct {
 	Title         string    `db:"title"`
 	EncryptedData string    `db:"encrypted_data"`
 	EncryptedKey  string    `db:"encrypted_key"`
-	KeyID         string    `db:"key_id"` // system key id
+	KeyID         *string   `db:"key_id"` // system key id, NULL for legacy cards
 	CreatedAt     time.Time `db:"created_at"`
 	UpdatedAt     time.Time `db:"updated_at"`
 }
+
+type KeyUsage struct {
+	KeyID string `db:"key_id"`
+	Cards int    `db:"cards"`
+}
+
+type KeyUpdate struct {
+	EncryptedKey string
+	KeyID        string
+}
@@ -0,0 +1,29 @@
+package entities
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type NewTOTP struct {
+	UserId          uuid.UUID
+	EncryptedSecret string
+	KeyID           string
+}
+
+type TOTP struct {
+	UserId          uuid.UUID  `db:"user_id"`
+	EncryptedSecret string     `db:"encrypted_secret"`
+	KeyID           string     `db:"key_id"` // system key id
+	LastUsedStep    int64      `db:"last_used_step"`
+	ConfirmedAt     *time.Time `db:"confirmed_at"`
+	CreatedAt       time.Time  `db:"created_at"`
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID  `db:"id"`
+	UserId    uuid.UUID  `db:"user_id"`
+	CodeHash  string     `db:"code_hash"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
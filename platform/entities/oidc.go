@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type NewOIDCState struct {
+	State        string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	ExpiresAt    time.Time
+}
+
+type OIDCState struct {
+	State        string    `db:"state"`
+	Provider     string    `db:"provider"`
+	CodeVerifier string    `db:"code_verifier"`
+	Nonce        string    `db:"nonce"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+type NewUserIdentity struct {
+	UserId   uuid.UUID
+	Provider string
+	Subject  string
+	Email    string
+}
+
+type UserIdentity struct {
+	ID          uuid.UUID  `db:"id"`
+	UserId      uuid.UUID  `db:"user_id"`
+	Provider    string     `db:"provider"`
+	Subject     string     `db:"subject"`
+	Email       *string    `db:"email"`
+	CreatedAt   time.Time  `db:"created_at"`
+	LastLoginAt *time.Time `db:"last_login_at"`
+}
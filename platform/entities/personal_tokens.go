@@ -0,0 +1,27 @@
+package entities
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type NewPersonalToken struct {
+	UserId      uuid.UUID
+	Name        string
+	TokenHash   string
+	TokenPrefix string
+	Scopes      string
+	ExpiresAt   *time.Time
+}
+
+type PersonalToken struct {
+	ID          uuid.UUID  `db:"id"`
+	UserId      uuid.UUID  `db:"user_id"`
+	Name        string     `db:"name"`
+	TokenHash   string     `db:"token_hash"`
+	TokenPrefix string     `db:"token_prefix"`
+	Scopes      string     `db:"scopes"`
+	ExpiresAt   *time.Time `db:"expires_at"`
+	LastUsedAt  *time.Time `db:"last_used_at"`
+	CreatedAt   time.Time  `db:"created_at"`
+}
@@ -0,0 +1,37 @@
+package entities
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type NewRefreshToken struct {
+	UserId    uuid.UUID
+	FamilyId  *uuid.UUID // new family is started if empty
+	ExpiresAt time.Time
+	UserAgent string
+	IPAddress string
+}
+
+type RefreshToken struct {
+	JTI        uuid.UUID  `db:"jti"`
+	UserId     uuid.UUID  `db:"user_id"`
+	FamilyId   uuid.UUID  `db:"family_id"`
+	ReplacedBy *uuid.UUID `db:"replaced_by"`
+	IssuedAt   time.Time  `db:"issued_at"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+	UserAgent  *string    `db:"user_agent"`
+	IPAddress  *string    `db:"ip_address"`
+}
+
+// Session is the latest active refresh token of a token family
+type Session struct {
+	FamilyId   uuid.UUID `db:"family_id"`
+	UserId     uuid.UUID `db:"user_id"`
+	StartedAt  time.Time `db:"started_at"`
+	LastUsedAt time.Time `db:"issued_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
+	UserAgent  *string   `db:"user_agent"`
+	IPAddress  *string   `db:"ip_address"`
+}
@@ -11,6 +11,17 @@ type ActionCodeType string
 const (
 	UserConfirmations ActionCodeType = "user_confirmations"
 	PasswordResets    ActionCodeType = "password_resets"
+	EmailChanges      ActionCodeType = "email_changes"
+	MagicLinks        ActionCodeType = "magic_links"
+)
+
+type InactiveReason string
+
+const (
+	// DeactivatedByAdmin is set when admin deactivates the account
+	DeactivatedByAdmin InactiveReason = "deactivated"
+	// DeletionRequested is set when user asks to delete the account
+	DeletionRequested InactiveReason = "deletion_requested"
 )
 
 type NewUser struct {
@@ -42,21 +53,40 @@ type User struct {
 	Provider    string          `db:"provider"`
 	CreatedAt   time.Time       `db:"created_at"`
 	UpdatedAt   time.Time       `db:"updated_at"`
+	// DeletionRequestedAt is set while account awaits purge
+	DeletionRequestedAt *time.Time `db:"deletion_requested_at"`
+	InactiveReason      *string    `db:"inactive_reason"`
 }
 
+// ActionCodeTypes lists tables which keep action codes
+var ActionCodeTypes = []ActionCodeType{UserConfirmations, PasswordResets, EmailChanges, MagicLinks}
+
+// ActionCode only keeps hash of the code which was sent to the user
 type ActionCode struct {
-	ID        uuid.UUID `db:"id"`
-	UserId    uuid.UUID `db:"user_id"`
-	Code      string    `db:"code"`
-	CreatedAt time.Time `db:"created_at"`
+	ID        uuid.UUID  `db:"id"`
+	UserId    uuid.UUID  `db:"user_id"`
+	CodeHash  string     `db:"code_hash"`
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	// NewEmail is only set for email changes
+	NewEmail *string `db:"new_email"`
 }
 
 type ActionCodeRequest struct {
-	Type  string
-	Email string
+	Type     ActionCodeType
+	Email    string
+	CodeHash string
+	NewEmail string
+	TTL      time.Duration
+	// AuditRecord is written together with the code when admin issues it
+	AuditRecord *NewAdminAuditRecord
 }
 
+// ActionCodeCheck matches unused and not expired code,
+// when UserId is set code must also belong to the user.
 type ActionCodeCheck struct {
-	Code string
-	Type ActionCodeType
+	CodeHash string
+	Type     ActionCodeType
+	UserId   *uuid.UUID
 }
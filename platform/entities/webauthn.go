@@ -0,0 +1,50 @@
+package entities
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type WebAuthnCeremony string
+
+const (
+	WebAuthnRegistration WebAuthnCeremony = "registration"
+	WebAuthnLogin        WebAuthnCeremony = "login"
+	WebAuthnMFA          WebAuthnCeremony = "mfa"
+)
+
+type NewWebAuthnChallenge struct {
+	Challenge string
+	UserId    *uuid.UUID
+	Ceremony  WebAuthnCeremony
+	ExpiresAt time.Time
+}
+
+type WebAuthnChallenge struct {
+	Challenge string     `db:"challenge"`
+	UserId    *uuid.UUID `db:"user_id"`
+	Ceremony  string     `db:"ceremony"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+type NewWebAuthnCredential struct {
+	UserId       uuid.UUID
+	Name         string
+	CredentialId string
+	PublicKey    string
+	AAGUID       string
+	SignCount    uint32
+}
+
+type WebAuthnCredential struct {
+	ID           uuid.UUID  `db:"id"`
+	UserId       uuid.UUID  `db:"user_id"`
+	Name         *string    `db:"name"`
+	CredentialId string     `db:"credential_id"` // base64url
+	PublicKey    string     `db:"public_key"`    // base64url COSE key
+	AAGUID       *string    `db:"aaguid"`
+	SignCount    int64      `db:"sign_count"`
+	CreatedAt    time.Time  `db:"created_at"`
+	LastUsedAt   *time.Time `db:"last_used_at"`
+}
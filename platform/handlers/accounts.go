@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/sultaniman/confetti/platform/http"
 )
 
 // Register godoc
@@ -52,18 +51,13 @@ func (h *Handler) ResetPasswordRequest(ctx *fiber.Ctx) error {
 // @Router /accounts/reset-password/{code} [post]
 func (h *Handler) ResetPassword(ctx *fiber.Ctx) error {
 	code := ctx.Params("code")
-	passwordResetCode, err := h.UserService.GetResetPasswordCode(code)
-	if err != nil {
-		return http.BadRequestWithMessage("Invalid password reset code")
-	}
-
 	newPasswordRequest, err := h.Params.NewPasswordPayload(ctx)
 	if err != nil {
 		return err
 	}
 
 	// TODO: check if password is the same
-	err = h.UserService.ResetPassword(passwordResetCode.UserId, newPasswordRequest.Password)
+	err = h.UserService.ResetPasswordWithCode(code, newPasswordRequest.Password)
 	if err != nil {
 		return err
 	}
@@ -114,3 +108,223 @@ func (h *Handler) ResendConfirmation(ctx *fiber.Ctx) error {
 
 	return ctx.SendStatus(fiber.StatusNoContent)
 }
+
+// GetAccount godoc
+// @Summary Get current account
+// @Description Get profile of signed in user
+// @Tags accounts
+// @Produce json
+// @Success 200 {object} schema.UserResponse
+// @Router /accounts/me [get]
+func (h *Handler) GetAccount(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.UserService.Get(*userId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(user)
+}
+
+// UpdateAccount godoc
+// @Summary Update current account
+// @Description Update full name and settings of signed in user
+// @Tags accounts
+// @Produce json
+// @Success 202 {object} schema.UserResponse
+// @Router /accounts/me [put]
+func (h *Handler) UpdateAccount(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	updateUserPayload, err := h.Params.UpdateUserPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.UserService.Update(*userId, updateUserPayload)
+	if err != nil {
+		return err
+	}
+
+	return ctx.
+		Status(fiber.StatusAccepted).
+		JSON(user)
+}
+
+// UpdateAccountEmail godoc
+// @Summary Update email of current account
+// @Description Sends confirmation link to the new email and notice to the current one,
+// @Description email is changed once the link is followed. Current password is required,
+// @Description users without password must have signed in recently.
+// @Tags accounts
+// @Produce json
+// @Failure 401 {object} shared.HTTPError Recent authentication is required
+// @Failure 403 {object} shared.HTTPError Invalid password
+// @Failure 409 {object} shared.HTTPError Email already exists
+// @Success 202 {object} schema.UserResponse
+// @Router /accounts/me/email [put]
+func (h *Handler) UpdateAccountEmail(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	updateUserEmailPayload, err := h.Params.UpdateUserEmailPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.UserService.UpdateEmail(*userId, updateUserEmailPayload, h.Params.GetAuthTimeFromLocals(ctx))
+	if err != nil {
+		return err
+	}
+
+	return ctx.
+		Status(fiber.StatusAccepted).
+		JSON(user)
+}
+
+// ConfirmEmailChange godoc
+// @Summary Confirm new email of current account
+// @Description Commits pending email change using code from the link sent to the new address
+// @Tags accounts
+// @Produce json
+// @Failure 404 {object} shared.HTTPError Email change code not found
+// @Failure 409 {object} shared.HTTPError Email change code has expired
+// @Success 200 {object} schema.UserResponse
+// @Router /accounts/me/email/confirm/{code} [get]
+func (h *Handler) ConfirmEmailChange(ctx *fiber.Ctx) error {
+	code := ctx.Params("code")
+
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.UserService.ConfirmEmailChange(*userId, code)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(user)
+}
+
+// UpdateAccountPassword godoc
+// @Summary Update password of current account
+// @Description Update password of signed in user, all sessions are revoked
+// @Tags accounts
+// @Produce json
+// @Failure 403 {object} shared.HTTPError Invalid password
+// @Success 202 {object} schema.UserResponse
+// @Router /accounts/me/password [put]
+func (h *Handler) UpdateAccountPassword(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	updateUserPasswordPayload, err := h.Params.UpdateUserPasswordPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.UserService.UpdatePassword(*userId, updateUserPasswordPayload, h.Params.GetAuthTimeFromLocals(ctx))
+	if err != nil {
+		return err
+	}
+
+	return ctx.
+		Status(fiber.StatusAccepted).
+		JSON(user)
+}
+
+// DeleteAccount godoc
+// @Summary Delete current account
+// @Description Disables account and schedules it for deletion after grace period,
+// @Description during grace period account can be restored.
+// @Tags accounts
+// @Produce json
+// @Failure 401 {object} shared.HTTPError Recent authentication is required
+// @Failure 403 {object} shared.HTTPError Invalid password
+// @Failure 409 {object} shared.HTTPError Deletion already requested
+// @Success 202 {object} schema.UserResponse
+// @Router /accounts/me [delete]
+func (h *Handler) DeleteAccount(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	deletePayload, err := h.Params.DeleteAccountPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.UserService.RequestDeletion(*userId, deletePayload, h.Params.GetAuthTimeFromLocals(ctx))
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(user)
+}
+
+// RestoreAccount godoc
+// @Summary Restore account scheduled for deletion
+// @Description Cancels pending deletion using e-mail and password or passkey, account becomes active again.
+// @Description With two-factor authentication challenge token is returned first which is sent back
+// @Description together with verification code or passkey.
+// @Tags accounts
+// @Produce json
+// @Failure 401 {object} shared.HTTPError Wrong e-mail or password
+// @Failure 403 {object} shared.HTTPError Account was deactivated
+// @Failure 409 {object} shared.HTTPError Deletion was not requested
+// @Failure 429 {object} shared.HTTPError Too many failed attempts
+// @Success 200 {object} schema.RestoreAccountResponse
+// @Router /accounts/restore [post]
+func (h *Handler) RestoreAccount(ctx *fiber.Ctx) error {
+	restorePayload, err := h.Params.RestoreAccountPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	restoreResponse, err := h.AuthService.RestoreAccount(ctx, restorePayload)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(restoreResponse)
+}
+
+// AccountAudit godoc
+// @Summary List card audit records of current user
+// @Description List card accesses made by current user, newest first
+// @Tags accounts
+// @Produce json
+// @Param limit query int false "Number of records (default 100, max 1000)"
+// @Success 200 {object} []schema.CardAuditResponse
+// @Router /accounts/audit [get]
+func (h *Handler) AccountAudit(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	limit, err := h.Params.AuditLimit(ctx)
+	if err != nil {
+		return err
+	}
+
+	records, err := h.CardService.UserAudit(*userId, limit)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(records)
+}
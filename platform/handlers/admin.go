@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sultaniman/confetti/platform/schema"
+)
+
+type adminUserAction func(userId uuid.UUID, auditContext *schema.AuditContext) (*schema.UserResponse, error)
+
+// DeactivateUser godoc
+// @Summary Deactivate user
+// @Description Blocks sign in and revokes all sessions of user
+// @Tags users
+// @Produce json
+// @Failure 404 {object} shared.HTTPError User not found
+// @Success 200 {object} schema.UserResponse
+// @Router /admin/users/{user_id}/deactivate [post]
+func (h *Handler) DeactivateUser(ctx *fiber.Ctx) error {
+	return h.performUserAction(ctx, h.AdminService.Deactivate)
+}
+
+// ActivateUser godoc
+// @Summary Reactivate user
+// @Description Allows deactivated user to sign in again
+// @Tags users
+// @Produce json
+// @Failure 404 {object} shared.HTTPError User not found
+// @Success 200 {object} schema.UserResponse
+// @Router /admin/users/{user_id}/activate [post]
+func (h *Handler) ActivateUser(ctx *fiber.Ctx) error {
+	return h.performUserAction(ctx, h.AdminService.Activate)
+}
+
+// GrantAdmin godoc
+// @Summary Grant admin role
+// @Description Grant admin role to user
+// @Tags users
+// @Produce json
+// @Failure 404 {object} shared.HTTPError User not found
+// @Success 200 {object} schema.UserResponse
+// @Router /admin/users/{user_id}/admin [post]
+func (h *Handler) GrantAdmin(ctx *fiber.Ctx) error {
+	return h.performUserAction(ctx, h.AdminService.GrantAdmin)
+}
+
+// RevokeAdmin godoc
+// @Summary Revoke admin role
+// @Description Revoke admin role and all sessions of user
+// @Tags users
+// @Produce json
+// @Failure 404 {object} shared.HTTPError User not found
+// @Success 200 {object} schema.UserResponse
+// @Router /admin/users/{user_id}/admin [delete]
+func (h *Handler) RevokeAdmin(ctx *fiber.Ctx) error {
+	return h.performUserAction(ctx, h.AdminService.RevokeAdmin)
+}
+
+// ForceConfirmUser godoc
+// @Summary Confirm user
+// @Description Confirm user account without confirmation code
+// @Tags users
+// @Produce json
+// @Failure 404 {object} shared.HTTPError User not found
+// @Success 200 {object} schema.UserResponse
+// @Router /admin/users/{user_id}/confirm [post]
+func (h *Handler) ForceConfirmUser(ctx *fiber.Ctx) error {
+	return h.performUserAction(ctx, h.AdminService.Confirm)
+}
+
+// SendPasswordReset godoc
+// @Summary Send password reset e-mail
+// @Description Send password reset e-mail on behalf of user
+// @Tags users
+// @Produce json
+// @Failure 404 {object} shared.HTTPError User not found
+// @Success 204 {string} nil e-mail sent
+// @Router /admin/users/{user_id}/reset-password [post]
+func (h *Handler) SendPasswordReset(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUUIDParam(ctx, "user_id")
+	if err != nil {
+		return err
+	}
+
+	auditContext, err := h.Params.AuditContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = h.AdminService.SendPasswordReset(*userId, auditContext)
+	if err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// UserAdminAudit godoc
+// @Summary List admin actions performed on user
+// @Description List admin actions performed on user, newest first
+// @Tags users
+// @Produce json
+// @Param limit query int false "Number of records (default 100, max 1000)"
+// @Success 200 {object} []schema.AdminAuditResponse
+// @Router /admin/users/{user_id}/audit [get]
+func (h *Handler) UserAdminAudit(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUUIDParam(ctx, "user_id")
+	if err != nil {
+		return err
+	}
+
+	limit, err := h.Params.AuditLimit(ctx)
+	if err != nil {
+		return err
+	}
+
+	records, err := h.AdminService.Audit(userId, limit)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(records)
+}
+
+// AdminAudit godoc
+// @Summary List admin actions
+// @Description List admin actions performed on all users, newest first
+// @Tags users
+// @Produce json
+// @Param limit query int false "Number of records (default 100, max 1000)"
+// @Success 200 {object} []schema.AdminAuditResponse
+// @Router /admin/audit [get]
+func (h *Handler) AdminAudit(ctx *fiber.Ctx) error {
+	limit, err := h.Params.AuditLimit(ctx)
+	if err != nil {
+		return err
+	}
+
+	records, err := h.AdminService.Audit(nil, limit)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(records)
+}
+
+func (h *Handler) performUserAction(ctx *fiber.Ctx, action adminUserAction) error {
+	userId, err := h.Params.GetUUIDParam(ctx, "user_id")
+	if err != nil {
+		return err
+	}
+
+	auditContext, err := h.Params.AuditContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := action(*userId, auditContext)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(user)
+}
@@ -5,7 +5,10 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
 	"github.com/sultaniman/confetti/platform/middleware"
+	"github.com/sultaniman/confetti/platform/services"
 	"github.com/sultaniman/confetti/platform/shared"
 )
 
@@ -26,7 +29,16 @@ func App(handler *Handler) *fiber.App {
 	authMiddleware := middleware.AuthMiddleware(
 		"Authorization",
 		true,
-		handler.JWXService.JWKS(),
+		handler.JWXService.JWKS,
+		nil,
+	)
+
+	// only card routes accept personal access tokens
+	cardsAuthMiddleware := middleware.AuthMiddleware(
+		"Authorization",
+		true,
+		handler.JWXService.JWKS,
+		handler.PersonalTokenService,
 	)
 
 	system := app.Group("/system")
@@ -34,37 +46,132 @@ func App(handler *Handler) *fiber.App {
 
 	admin := app.Group("/admin")
 	admin.Use(authMiddleware)
+	admin.Use(middleware.RequireScope(services.ScopeAdmin))
+	admin.Use(middleware.AdminMiddleware())
 
 	users := admin.Group("/users")
+	users.Get("/", handler.ListUsers)
 	users.Post("/", handler.CreateUser)
 	users.Get("/:user_id", handler.GetUser)
 	users.Put("/:user_id", handler.UpdateUser)
 	users.Put("/:user_id/email", handler.UpdateEmail)
 	users.Put("/:user_id/password", handler.UpdatePassword)
 	users.Delete("/:user_id", handler.DeleteUser)
+	users.Delete("/:user_id/lockout", handler.ClearLockout)
+	users.Post("/:user_id/deactivate", handler.DeactivateUser)
+	users.Post("/:user_id/activate", handler.ActivateUser)
+	users.Post("/:user_id/admin", handler.GrantAdmin)
+	users.Delete("/:user_id/admin", handler.RevokeAdmin)
+	users.Post("/:user_id/confirm", handler.ForceConfirmUser)
+	users.Post("/:user_id/reset-password", handler.SendPasswordReset)
+	users.Get("/:user_id/audit", handler.UserAdminAudit)
+	admin.Get("/audit", handler.AdminAudit)
 
 	cards := app.Group("/cards")
-	cards.Use(authMiddleware)
-	cards.Post("/new", handler.GenerateCard)
-	cards.Get("/", handler.ListCards)
-	cards.Post("/", handler.CreateCard)
-	cards.Get("/:card_id", handler.GetCard)
-	cards.Delete("/:card_id", handler.DeleteCard)
-	cards.Put("/:card_id", handler.UpdateCard)
-	cards.Get("/:card_id/decrypt", handler.DecryptCard)
+	cards.Use(cardsAuthMiddleware)
+	readScope := middleware.RequireScope(services.ScopeCardsRead)
+	writeScope := middleware.RequireScope(services.ScopeCardsWrite)
+	cards.Post("/new", writeScope, handler.GenerateCard)
+	cards.Get("/", readScope, handler.ListCards)
+	cards.Post("/", writeScope, handler.CreateCard)
+	cards.Get("/:card_id", readScope, handler.GetCard)
+	cards.Delete("/:card_id", writeScope, handler.DeleteCard)
+	cards.Put("/:card_id", writeScope, handler.UpdateCard)
+	cards.Get(
+		"/:card_id/decrypt",
+		middleware.RequireScope(services.ScopeCardsDecrypt),
+		middleware.RecentAuthMiddleware(viper.GetDuration("step_up_ttl")),
+		handler.DecryptCard,
+	)
+	cards.Get("/:card_id/audit", readScope, handler.CardAudit)
 
 	accounts := app.Group("/accounts")
-	accounts.Post("/register", handler.Register)
-	accounts.Get("/confirm/:code", authMiddleware, handler.Confirm)
-	accounts.Post("/resend-confirmation", authMiddleware, handler.ResendConfirmation)
-	accounts.Post("/reset-password", handler.ResetPasswordRequest)
+	accountScope := middleware.RequireScope(services.ScopeAccount)
+	recentAuth := middleware.RecentAuthMiddleware(viper.GetDuration("step_up_ttl"))
+	accounts.Post("/register", rateLimit("register", middleware.KeyByIP, middleware.KeyByEmail), handler.Register)
+	accounts.Get("/confirm/:code", authMiddleware, accountScope, handler.Confirm)
+	accounts.Post(
+		"/resend-confirmation",
+		authMiddleware,
+		accountScope,
+		rateLimit("resend_confirmation", middleware.KeyByUserId),
+		handler.ResendConfirmation,
+	)
+	accounts.Post(
+		"/reset-password",
+		rateLimit("reset_password", middleware.KeyByIP, middleware.KeyByEmail),
+		handler.ResetPasswordRequest,
+	)
 	accounts.Post("/reset-password/:code", handler.ResetPassword)
+	accounts.Get("/me", authMiddleware, accountScope, handler.GetAccount)
+	accounts.Put("/me", authMiddleware, accountScope, handler.UpdateAccount)
+	accounts.Put("/me/email", authMiddleware, accountScope, handler.UpdateAccountEmail)
+	accounts.Get("/me/email/confirm/:code", authMiddleware, accountScope, handler.ConfirmEmailChange)
+	accounts.Put("/me/password", authMiddleware, accountScope, handler.UpdateAccountPassword)
+	accounts.Delete("/me", authMiddleware, accountScope, handler.DeleteAccount)
+	accounts.Post("/restore", rateLimit("restore_account", middleware.KeyByIP, middleware.KeyByEmail), handler.RestoreAccount)
+	accounts.Get("/audit", authMiddleware, accountScope, handler.AccountAudit)
+	accounts.Get("/sessions", authMiddleware, accountScope, handler.ListSessions)
+	accounts.Delete("/sessions", authMiddleware, accountScope, handler.RevokeAllSessions)
+	accounts.Delete("/sessions/:session_id", authMiddleware, accountScope, handler.RevokeSession)
+	accounts.Post("/2fa/totp", authMiddleware, accountScope, handler.EnrollTOTP)
+	accounts.Post("/2fa/totp/verify", authMiddleware, accountScope, handler.ConfirmTOTP)
+	accounts.Delete("/2fa/totp", authMiddleware, accountScope, handler.DisableTOTP)
+	accounts.Post("/2fa/recovery-codes", authMiddleware, accountScope, handler.RegenerateRecoveryCodes)
+	accounts.Get("/tokens", authMiddleware, accountScope, handler.ListPersonalTokens)
+	accounts.Post(
+		"/tokens",
+		authMiddleware,
+		accountScope,
+		recentAuth,
+		handler.CreatePersonalToken,
+	)
+	accounts.Delete("/tokens/:token_id", authMiddleware, accountScope, handler.RevokePersonalToken)
+	accounts.Post("/webauthn/register/begin", authMiddleware, accountScope, recentAuth, handler.BeginWebAuthnRegistration)
+	accounts.Post("/webauthn/register/finish", authMiddleware, accountScope, recentAuth, handler.FinishWebAuthnRegistration)
+	accounts.Get("/webauthn/credentials", authMiddleware, accountScope, handler.ListWebAuthnCredentials)
+	accounts.Delete("/webauthn/credentials/:credential_id", authMiddleware, accountScope, recentAuth, handler.DeleteWebAuthnCredential)
 
 	auth := app.Group("/auth")
 	auth.Get("/jwks", handler.JWKS)
-	auth.Post("/token", handler.AuthTokenFlow)
+	auth.Post("/token", rateLimit("auth_token", middleware.KeyByIP), handler.AuthTokenFlow)
+	auth.Post("/token/mfa", rateLimit("mfa_token", middleware.KeyByIP), handler.MFATokenFlow)
+	auth.Post(
+		"/token/elevate",
+		authMiddleware,
+		middleware.RequireScope(services.ScopeAccount),
+		rateLimit("step_up", middleware.KeyByIP, middleware.KeyByUserId),
+		handler.StepUpToken,
+	)
+	auth.Post("/token/narrow", authMiddleware, handler.NarrowToken)
+	auth.Post("/magic-link", rateLimit("magic_link", middleware.KeyByIP, middleware.KeyByEmail), handler.MagicLinkRequest)
+	auth.Post("/magic-link/:code", rateLimit("magic_link_token", middleware.KeyByIP), handler.MagicLinkTokenFlow)
+	auth.Post("/webauthn/login/begin", rateLimit("webauthn_login_begin", middleware.KeyByIP), handler.BeginWebAuthnLogin)
+	auth.Post("/webauthn/login/finish", rateLimit("webauthn_login_finish", middleware.KeyByIP), handler.WebAuthnLoginFlow)
+	auth.Post("/webauthn/mfa/begin", rateLimit("webauthn_mfa_begin", middleware.KeyByIP), handler.BeginWebAuthnMFA)
+	auth.Post("/webauthn/mfa/finish", rateLimit("webauthn_mfa_finish", middleware.KeyByIP), handler.WebAuthnMFAFlow)
+	auth.Get("/oidc/providers", handler.ListOIDCProviders)
+	auth.Get("/oidc/:provider/authorize", rateLimit("oidc_authorize", middleware.KeyByIP), handler.OIDCAuthorize)
+	auth.Get("/oidc/:provider/callback", rateLimit("oidc_callback", middleware.KeyByIP), handler.OIDCCallback)
 	auth.Post("/token/refresh", handler.RefreshToken)
 	auth.Delete("/token", handler.LogOut)
 
 	return app
 }
+
+// rateLimit builds limiter configured by rate_limit_<name> as "requests/period"
+func rateLimit(name string, keys ...middleware.RateLimitKeyFunc) fiber.Handler {
+	limit, err := middleware.ParseRateLimit(viper.GetString("rate_limit_" + name))
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str("rate_limit", name).
+			Msg("Invalid rate limit configuration")
+	}
+
+	return middleware.RateLimitMiddleware(middleware.RateLimitConfig{
+		Name:  name,
+		Limit: limit,
+		Keys:  keys,
+	})
+}
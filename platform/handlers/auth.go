@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"strings"
 )
 
 // AuthTokenFlow godoc
@@ -10,6 +11,7 @@ import (
 // @Tags auth
 // @Produce json
 // @Failure 403 {object} shared.HTTPError Invalid auth details
+// @Failure 429 {object} shared.HTTPError Too many failed attempts or account is locked
 // @Success 200 {object} schema.TokenResponse
 // @Router /token [post]
 func (h *Handler) AuthTokenFlow(ctx *fiber.Ctx) error {
@@ -26,6 +28,120 @@ func (h *Handler) AuthTokenFlow(ctx *fiber.Ctx) error {
 	return ctx.JSON(tokenResponse)
 }
 
+// MFATokenFlow godoc
+// @Summary Complete authentication with two-factor verification code
+// @Description Exchange challenge token and TOTP or recovery code for access tokens
+// @Tags auth
+// @Produce json
+// @Failure 401 {object} shared.HTTPError Invalid verification code
+// @Success 200 {object} schema.TokenResponse
+// @Router /token/mfa [post]
+func (h *Handler) MFATokenFlow(ctx *fiber.Ctx) error {
+	mfaPayload, err := h.Params.MFALoginPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	tokenResponse, err := h.AuthService.MFAAuthFlow(ctx, mfaPayload)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(tokenResponse)
+}
+
+// StepUpToken godoc
+// @Summary Re-authenticate to access sensitive endpoints
+// @Description Issues short-lived access token after password or verification code is re-entered,
+// @Description it is required to decrypt cards.
+// @Tags auth
+// @Produce json
+// @Failure 401 {object} shared.HTTPError Invalid verification code
+// @Failure 403 {object} shared.HTTPError Invalid password
+// @Failure 429 {object} shared.HTTPError Too many failed attempts
+// @Success 200 {object} schema.TokenResponse
+// @Router /auth/token/elevate [post]
+func (h *Handler) StepUpToken(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	stepUpPayload, err := h.Params.StepUpPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	tokenResponse, err := h.AuthService.StepUp(ctx, *userId, stepUpPayload)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(tokenResponse)
+}
+
+// NarrowToken godoc
+// @Summary Issue access token with fewer scopes
+// @Description Issues access token limited to given scopes, token expires together with the current one
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param payload body schema.NarrowTokenRequest true "Scopes of the new token"
+// @Failure 400 {object} shared.HTTPError Scopes are missing
+// @Failure 403 {object} shared.HTTPError Scope is not granted to current token
+// @Success 200 {object} schema.TokenResponse
+// @Router /auth/token/narrow [post]
+func (h *Handler) NarrowToken(ctx *fiber.Ctx) error {
+	narrowTokenPayload, err := h.Params.NarrowTokenPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	accessToken := strings.TrimPrefix(ctx.Get(fiber.HeaderAuthorization), "Bearer ")
+	tokenResponse, err := h.JWXService.NarrowAccessToken(accessToken, narrowTokenPayload.Scopes)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(tokenResponse)
+}
+
+// MagicLinkRequest godoc
+// @Summary Send one-time sign in link
+// @Description Sends sign in link to e-mail, response does not tell if the account exists
+// @Tags auth
+// @Produce json
+// @Success 204 {string} nil link was sent
+// @Router /auth/magic-link [post]
+func (h *Handler) MagicLinkRequest(ctx *fiber.Ctx) error {
+	magicLinkPayload, err := h.Params.MagicLinkPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	_ = h.AuthService.MagicLinkRequest(ctx, magicLinkPayload)
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// MagicLinkTokenFlow godoc
+// @Summary Authenticate user with magic link and issue access tokens
+// @Description Exchanges one-time sign in link code for access tokens or two-factor challenge
+// @Tags auth
+// @Produce json
+// @Failure 401 {object} shared.HTTPError Invalid or expired sign in link
+// @Failure 403 {object} shared.HTTPError User is not active
+// @Failure 429 {object} shared.HTTPError Account is locked
+// @Success 200 {object} schema.TokenResponse
+// @Router /auth/magic-link/{code} [post]
+func (h *Handler) MagicLinkTokenFlow(ctx *fiber.Ctx) error {
+	tokenResponse, err := h.AuthService.MagicLinkAuthFlow(ctx, ctx.Params("code"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(tokenResponse)
+}
+
 // RefreshToken godoc
 // @Summary Refresh access token
 // @Description Refresh access token
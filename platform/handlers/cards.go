@@ -38,12 +38,12 @@ func (h *Handler) CreateCard(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	auditContext, err := h.Params.AuditContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	card, err := h.CardService.Create(*userId, newCardRequest)
+	card, err := h.CardService.Create(auditContext.ActorId, newCardRequest, auditContext)
 	if err != nil {
 		return err
 	}
@@ -55,18 +55,33 @@ func (h *Handler) CreateCard(ctx *fiber.Ctx) error {
 
 // ListCards godoc
 // @Summary List cards
-// @Description List cards
+// @Description List cards page by page, pass NextCursor as cursor to get the next page
 // @Tags cards
 // @Produce json
-// @Success 200 {object} []schema.CardResponse
-// @Router / [post]
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Cursor from previous page"
+// @Param title query string false "Case-insensitive title search"
+// @Param title_match query string false "contains (default) or prefix"
+// @Param created_after query string false "RFC 3339 date, inclusive"
+// @Param created_before query string false "RFC 3339 date, exclusive"
+// @Param updated_after query string false "RFC 3339 date, inclusive"
+// @Param updated_before query string false "RFC 3339 date, exclusive"
+// @Param sort query string false "created_at (default), updated_at or title"
+// @Param order query string false "desc (default) or asc"
+// @Success 200 {object} schema.CardListResponse
+// @Router / [get]
 func (h *Handler) ListCards(ctx *fiber.Ctx) error {
 	userId, err := h.Params.GetUserIdFromLocals(ctx)
 	if err != nil {
 		return err
 	}
 
-	cards, err := h.CardService.List(*userId)
+	listRequest, err := h.Params.CardListParams(ctx)
+	if err != nil {
+		return err
+	}
+
+	cards, err := h.CardService.List(*userId, listRequest)
 	if err != nil {
 		return err
 	}
@@ -115,7 +130,12 @@ func (h *Handler) UpdateCard(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	err = h.CardService.Update(claim.CardId, updateCardRequest)
+	auditContext, err := h.Params.AuditContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = h.CardService.Update(claim.CardId, updateCardRequest, auditContext)
 	if err != nil {
 		return err
 	}
@@ -136,7 +156,12 @@ func (h *Handler) DeleteCard(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	err = h.CardService.Delete(claim.CardId)
+	auditContext, err := h.Params.AuditContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = h.CardService.Delete(claim.CardId, auditContext)
 	if err != nil {
 		return err
 	}
@@ -146,9 +171,10 @@ func (h *Handler) DeleteCard(ctx *fiber.Ctx) error {
 
 // DecryptCard godoc
 // @Summary Decrypt card by id
-// @Description Decrypt card by id
+// @Description Decrypt card by id, requires recent authentication (see /auth/token/elevate)
 // @Tags cards
 // @Produce json
+// @Failure 401 {object} shared.HTTPError Recent authentication is required
 // @Success 200 {object} schema.PlainCardResponse
 // @Router /{id}/decrypt [get]
 func (h *Handler) DecryptCard(ctx *fiber.Ctx) error {
@@ -157,10 +183,42 @@ func (h *Handler) DecryptCard(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	plainCard, err := h.CardService.Decrypt(claim.CardId)
+	auditContext, err := h.Params.AuditContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	plainCard, err := h.CardService.Decrypt(claim.CardId, auditContext)
 	if err != nil {
 		return err
 	}
 
 	return ctx.JSON(plainCard)
 }
+
+// CardAudit godoc
+// @Summary List audit records of card
+// @Description List who and when created, updated, deleted or decrypted the card, newest first
+// @Tags cards
+// @Produce json
+// @Param limit query int false "Number of records (default 100, max 1000)"
+// @Success 200 {object} []schema.CardAuditResponse
+// @Router /{id}/audit [get]
+func (h *Handler) CardAudit(ctx *fiber.Ctx) error {
+	claim, err := h.Params.EnsureCardClaim(ctx)
+	if err != nil {
+		return err
+	}
+
+	limit, err := h.Params.AuditLimit(ctx)
+	if err != nil {
+		return err
+	}
+
+	records, err := h.CardService.CardAudit(claim.CardId, limit)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(records)
+}
@@ -1,41 +1,56 @@
 package handlers
 
 import (
-	"crypto/rsa"
-	sq "github.com/Masterminds/squirrel"
+	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+	"github.com/sultaniman/confetti/platform/keys"
 	"github.com/sultaniman/confetti/platform/mailer"
+	"github.com/sultaniman/confetti/platform/oidc"
 	"github.com/sultaniman/confetti/platform/repo"
 	"github.com/sultaniman/confetti/platform/services"
+	"github.com/sultaniman/confetti/platform/webauthn"
+	"net/url"
+	"strings"
 )
 
 type Handler struct {
-	BaseRepo    *repo.Repo
-	UserRepo    repo.UserRepo
-	UserService services.UserService
-	CardService services.CardService
-	AuthService services.AuthService
-	JWXService  *services.JWXService
-	Params      *ParamHandler
+	BaseRepo             *repo.Repo
+	UserRepo             repo.UserRepo
+	UserService          services.UserService
+	CardService          services.CardService
+	AuthService          services.AuthService
+	MFAService           services.MFAService
+	LockoutService       services.LockoutService
+	AdminService         services.AdminService
+	WebAuthnService      services.WebAuthnService
+	OIDCService          services.OIDCService
+	SessionService       services.SessionService
+	PersonalTokenService services.PersonalTokenService
+	JWXService           *services.JWXService
+	Params               *ParamHandler
 }
 
-func NewHandler(db *sqlx.DB, key *rsa.PrivateKey) (*Handler, error) {
-	psql := sq.
-		StatementBuilder.
-		PlaceholderFormat(sq.Dollar).
-		RunWith(db)
-
-	baseRepo := &repo.Repo{
-		DB: db,
-		Q:  &psql,
-	}
+func NewHandler(db *sqlx.DB, keyring *keys.Keyring, signingKeyring *keys.SigningKeyring) (*Handler, error) {
+	baseRepo := repo.New(db)
 
 	mailerHandler := mailer.GetMailer()
 	userRepo := repo.NewUserRepo(baseRepo)
 	cardRepo := repo.NewCardRepo(baseRepo)
-	userService := services.NewUserService(userRepo, mailerHandler)
-	cardService := services.NewCardService(userRepo, cardRepo, key)
-	jwxService, err := services.NewJWXService(key)
+	tokenRepo := repo.NewTokenRepo(baseRepo)
+	mfaRepo := repo.NewMFARepo(baseRepo)
+	userService := services.NewUserService(userRepo, tokenRepo, mailerHandler)
+	cardService := services.NewCardService(userRepo, cardRepo, repo.NewAuditRepo(baseRepo), keyring)
+	mfaService := services.NewMFAService(mfaRepo, userRepo, keyring)
+	webAuthnService := services.NewWebAuthnService(relyingParty(), repo.NewWebAuthnRepo(baseRepo), userRepo, mfaService)
+	oidcService := services.NewOIDCService(oidcProviders(), repo.NewOIDCRepo(baseRepo), userRepo, userService)
+	lockoutService := services.NewLockoutService(
+		services.LockoutPolicyFromConfig(),
+		loginAttemptRepo(baseRepo),
+		userRepo,
+		mailerHandler,
+	)
+	jwxService, err := services.NewJWXService(signingKeyring, tokenRepo)
 	if err != nil {
 		return nil, err
 	}
@@ -45,11 +60,107 @@ func NewHandler(db *sqlx.DB, key *rsa.PrivateKey) (*Handler, error) {
 		UserRepo:    userRepo,
 		UserService: userService,
 		CardService: cardService,
-		AuthService: services.NewAuthService(userService, jwxService, mailerHandler),
-		JWXService:  jwxService,
+		AuthService: services.NewAuthService(
+			userService,
+			mfaService,
+			webAuthnService,
+			oidcService,
+			lockoutService,
+			jwxService,
+			mailerHandler,
+		),
+		MFAService:      mfaService,
+		WebAuthnService: webAuthnService,
+		OIDCService:     oidcService,
+		LockoutService:  lockoutService,
+		AdminService: services.NewAdminService(
+			userService,
+			lockoutService,
+			userRepo,
+			tokenRepo,
+			repo.NewAdminAuditRepo(baseRepo),
+			mailerHandler,
+		),
+		SessionService: services.NewSessionService(tokenRepo, jwxService),
+		PersonalTokenService: services.NewPersonalTokenService(
+			repo.NewPersonalTokenRepo(baseRepo),
+			userRepo,
+		),
+		JWXService: jwxService,
 		Params: &ParamHandler{
 			UserService: userService,
 			CardService: cardService,
 		},
 	}, nil
 }
+
+// loginAttemptRepo picks where failed sign in attempts are counted,
+// in-memory counters are not shared between instances.
+func loginAttemptRepo(baseRepo *repo.Repo) repo.LoginAttemptRepo {
+	if viper.GetString("lockout_store") == "memory" {
+		return repo.NewMemoryLoginAttemptRepo(baseRepo)
+	}
+
+	return repo.NewLoginAttemptRepo(baseRepo)
+}
+
+// relyingParty describes this service to WebAuthn authenticators, by default
+// relying party id and allowed origin are derived from base_url.
+func relyingParty() *webauthn.RelyingParty {
+	baseURL := viper.GetString("base_url")
+	rpID := viper.GetString("webauthn_rp_id")
+	if rpID == "" {
+		if parsed, err := url.Parse(baseURL); err == nil {
+			rpID = parsed.Hostname()
+		}
+	}
+
+	var origins []string
+	for _, origin := range strings.Split(viper.GetString("webauthn_origins"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, strings.TrimRight(origin, "/"))
+		}
+	}
+
+	if len(origins) == 0 {
+		origins = append(origins, strings.TrimRight(baseURL, "/"))
+	}
+
+	return &webauthn.RelyingParty{
+		ID:      rpID,
+		Name:    viper.GetString("webauthn_rp_name"),
+		Origins: origins,
+	}
+}
+
+// oidcProviders configures providers listed in oidc_providers, each provider
+// is configured with oidc_<name>_issuer, _client_id, _client_secret, _scopes and _redirect_url.
+func oidcProviders() map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider)
+	for _, name := range strings.Split(viper.GetString("oidc_providers"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		key := func(option string) string {
+			return fmt.Sprintf("oidc_%s_%s", name, option)
+		}
+
+		redirectURL := viper.GetString(key("redirect_url"))
+		if redirectURL == "" {
+			redirectURL = fmt.Sprintf("%s/auth/oidc/%s/callback", strings.TrimRight(viper.GetString("base_url"), "/"), name)
+		}
+
+		providers[name] = oidc.NewProvider(oidc.Config{
+			Name:         name,
+			Issuer:       viper.GetString(key("issuer")),
+			ClientID:     viper.GetString(key("client_id")),
+			ClientSecret: viper.GetString(key("client_secret")),
+			RedirectURL:  redirectURL,
+			Scopes:       strings.Fields(viper.GetString(key("scopes"))),
+		}, nil)
+	}
+
+	return providers
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// EnrollTOTP godoc
+// @Summary Start TOTP two-factor authentication enrollment
+// @Description Generates secret and otpauth URI which can be shown as QR code
+// @Tags accounts
+// @Produce json
+// @Failure 409 {object} shared.HTTPError Two-factor authentication is already enabled
+// @Success 200 {object} schema.TOTPEnrollmentResponse
+// @Router /accounts/2fa/totp [post]
+func (h *Handler) EnrollTOTP(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	enrollment, err := h.MFAService.EnrollTOTP(*userId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(enrollment)
+}
+
+// ConfirmTOTP godoc
+// @Summary Confirm TOTP enrollment
+// @Description Confirm TOTP enrollment with a code from authenticator app and receive recovery codes
+// @Tags accounts
+// @Produce json
+// @Failure 401 {object} shared.HTTPError Invalid verification code
+// @Success 200 {object} schema.RecoveryCodesResponse
+// @Router /accounts/2fa/totp/verify [post]
+func (h *Handler) ConfirmTOTP(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	codePayload, err := h.Params.TOTPCodePayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	recoveryCodes, err := h.MFAService.ConfirmTOTP(*userId, codePayload.Code)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(recoveryCodes)
+}
+
+// DisableTOTP godoc
+// @Summary Disable TOTP two-factor authentication
+// @Description Disable TOTP two-factor authentication using password and verification code,
+// @Description users without password need recent sign in instead of password.
+// @Tags accounts
+// @Produce json
+// @Success 204 {string} nil two-factor authentication disabled
+// @Router /accounts/2fa/totp [delete]
+func (h *Handler) DisableTOTP(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	disablePayload, err := h.Params.DisableTOTPPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = h.MFAService.DisableTOTP(*userId, disablePayload, h.Params.GetAuthTimeFromLocals(ctx))
+	if err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes, requires a valid TOTP code
+// @Tags accounts
+// @Produce json
+// @Success 200 {object} schema.RecoveryCodesResponse
+// @Router /accounts/2fa/recovery-codes [post]
+func (h *Handler) RegenerateRecoveryCodes(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	codePayload, err := h.Params.TOTPCodePayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	recoveryCodes, err := h.MFAService.RegenerateRecoveryCodes(*userId, codePayload.Code)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(recoveryCodes)
+}
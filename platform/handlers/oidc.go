@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sultaniman/confetti/platform/schema"
+	"github.com/sultaniman/confetti/platform/services"
+)
+
+// ListOIDCProviders godoc
+// @Summary List identity providers
+// @Description List names of configured external identity providers
+// @Tags auth
+// @Produce json
+// @Success 200 {object} schema.OIDCProvidersResponse
+// @Router /auth/oidc/providers [get]
+func (h *Handler) ListOIDCProviders(ctx *fiber.Ctx) error {
+	return ctx.JSON(&schema.OIDCProvidersResponse{
+		Providers: h.OIDCService.Providers(),
+	})
+}
+
+// OIDCAuthorize godoc
+// @Summary Sign in with identity provider
+// @Description Redirects to identity provider sign in page using authorization code flow with PKCE
+// @Tags auth
+// @Param provider path string true "Provider name"
+// @Failure 404 {object} shared.HTTPError Identity provider not found
+// @Success 302 {string} nil redirect to identity provider
+// @Router /auth/oidc/{provider}/authorize [get]
+func (h *Handler) OIDCAuthorize(ctx *fiber.Ctx) error {
+	authURL, stateCookie, err := h.OIDCService.Authorize(ctx.Context(), ctx.Params("provider"))
+	if err != nil {
+		return err
+	}
+
+	ctx.Cookie(stateCookie)
+	return ctx.Redirect(authURL, fiber.StatusFound)
+}
+
+// OIDCCallback godoc
+// @Summary Complete sign in with identity provider
+// @Description Exchanges authorization code for access tokens or two-factor challenge,
+// @Description accounts are linked by verified e-mail and created when they do not exist.
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Failure 401 {object} shared.HTTPError Sign in failed
+// @Failure 403 {object} shared.HTTPError User is not active or e-mail is not confirmed
+// @Success 200 {object} schema.TokenResponse
+// @Router /auth/oidc/{provider}/callback [get]
+func (h *Handler) OIDCCallback(ctx *fiber.Ctx) error {
+	callback, err := h.Params.OIDCCallbackParams(ctx)
+	if err != nil {
+		return err
+	}
+
+	// state can be used only once, cookie is not needed any more
+	ctx.ClearCookie(services.OIDCStateCookieName)
+
+	tokenResponse, err := h.AuthService.OIDCAuthFlow(ctx, ctx.Params("provider"), callback)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(tokenResponse)
+}
@@ -1,12 +1,26 @@
 package handlers
 
 import (
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/sultaniman/confetti/platform/http"
+	"github.com/sultaniman/confetti/platform/repo"
 	"github.com/sultaniman/confetti/platform/schema"
 	"github.com/sultaniman/confetti/platform/services"
 	"github.com/sultaniman/confetti/platform/shared"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultAuditLimit = 100
+	MaxAuditLimit     = 1000
+	DefaultCardsLimit = 20
+	MaxCardsLimit     = 100
+	DefaultUsersLimit = 50
+	MaxUsersLimit     = 500
 )
 
 type ParamHandler struct {
@@ -55,6 +69,62 @@ func (p *ParamHandler) GetUserIdFromLocals(c *fiber.Ctx) (*uuid.UUID, error) {
 	return &userID, nil
 }
 
+// GetAuthTimeFromLocals returns when user signed in, zero time
+// is returned for personal access tokens which do not carry it.
+func (p *ParamHandler) GetAuthTimeFromLocals(c *fiber.Ctx) time.Time {
+	authTime, _ := c.Locals("auth_time").(time.Time)
+	return authTime
+}
+
+// UserListParams reads pagination and filter query parameters for admin listing
+func (p *ParamHandler) UserListParams(c *fiber.Ctx) (*schema.UserListRequest, error) {
+	listRequest := &schema.UserListRequest{
+		Search:   strings.TrimSpace(c.Query("search")),
+		Provider: c.Query("provider"),
+		Limit:    DefaultUsersLimit,
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		value, err := strconv.ParseUint(limit, 10, 64)
+		if err != nil || value == 0 || value > MaxUsersLimit {
+			return nil, http.BadRequestWithMessage(fmt.Sprintf("limit should be between 1 and %d", MaxUsersLimit))
+		}
+
+		listRequest.Limit = value
+	}
+
+	if offset := c.Query("offset"); offset != "" {
+		value, err := strconv.ParseUint(offset, 10, 64)
+		if err != nil {
+			return nil, http.BadRequestWithMessage("offset should be a positive number")
+		}
+
+		listRequest.Offset = value
+	}
+
+	boolParams := map[string]**bool{
+		"is_active":    &listRequest.IsActive,
+		"is_confirmed": &listRequest.IsConfirmed,
+		"is_admin":     &listRequest.IsAdmin,
+	}
+
+	for paramName, target := range boolParams {
+		value := c.Query(paramName)
+		if value == "" {
+			continue
+		}
+
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, http.BadRequestWithMessage(fmt.Sprintf("%s should be true or false", paramName))
+		}
+
+		*target = &flag
+	}
+
+	return listRequest, nil
+}
+
 func (p *ParamHandler) CreateUserPayload(c *fiber.Ctx) (*schema.NewUserRequest, error) {
 	newUser := new(schema.NewUserRequest)
 	if err := c.BodyParser(newUser); err != nil {
@@ -120,6 +190,19 @@ func (p *ParamHandler) LoginPayload(ctx *fiber.Ctx) (*schema.LoginRequest, error
 	return loginRequestPayload, nil
 }
 
+func (p *ParamHandler) RestoreAccountPayload(ctx *fiber.Ctx) (*schema.RestoreAccountRequest, error) {
+	restoreRequest := &schema.RestoreAccountRequest{}
+	if err := ctx.BodyParser(restoreRequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return restoreRequest, nil
+}
+
 func (p *ParamHandler) RegisterPayload(ctx *fiber.Ctx) (*schema.RegisterRequest, error) {
 	registerRequestPayload := &schema.RegisterRequest{}
 	if err := ctx.BodyParser(registerRequestPayload); err != nil {
@@ -146,6 +229,38 @@ func (p *ParamHandler) ResetPasswordRequestPayload(ctx *fiber.Ctx) (*schema.Rese
 	return resetPasswordRequest, nil
 }
 
+func (p *ParamHandler) MagicLinkPayload(ctx *fiber.Ctx) (*schema.MagicLinkRequest, error) {
+	magicLinkRequest := &schema.MagicLinkRequest{}
+	if err := ctx.BodyParser(magicLinkRequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return magicLinkRequest, nil
+}
+
+// OIDCCallbackParams reads authorization response, errors returned by provider are not forwarded
+func (p *ParamHandler) OIDCCallbackParams(ctx *fiber.Ctx) (*schema.OIDCCallbackRequest, error) {
+	if ctx.Query("error") != "" {
+		return nil, http.UnauthorizedError("Sign in with identity provider was not completed")
+	}
+
+	callback := &schema.OIDCCallbackRequest{
+		Code:      ctx.Query("code"),
+		State:     ctx.Query("state"),
+		StateHash: ctx.Cookies(services.OIDCStateCookieName),
+	}
+
+	if callback.Code == "" || callback.State == "" {
+		return nil, http.BadRequestWithMessage("Please provide code and state")
+	}
+
+	return callback, nil
+}
+
 func (p *ParamHandler) NewPasswordPayload(ctx *fiber.Ctx) (*schema.NewPasswordRequest, error) {
 	newPasswordRequest := &schema.NewPasswordRequest{}
 	if err := ctx.BodyParser(newPasswordRequest); err != nil {
@@ -159,8 +274,168 @@ func (p *ParamHandler) NewPasswordPayload(ctx *fiber.Ctx) (*schema.NewPasswordRe
 	return newPasswordRequest, nil
 }
 
+func (p *ParamHandler) MFALoginPayload(ctx *fiber.Ctx) (*schema.MFALoginRequest, error) {
+	mfaLoginRequest := &schema.MFALoginRequest{}
+	if err := ctx.BodyParser(mfaLoginRequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return mfaLoginRequest, nil
+}
+
+func (p *ParamHandler) TOTPCodePayload(ctx *fiber.Ctx) (*schema.TOTPCodeRequest, error) {
+	totpCodeRequest := &schema.TOTPCodeRequest{}
+	if err := ctx.BodyParser(totpCodeRequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return totpCodeRequest, nil
+}
+
+func (p *ParamHandler) DisableTOTPPayload(ctx *fiber.Ctx) (*schema.DisableTOTPRequest, error) {
+	disableTOTPRequest := &schema.DisableTOTPRequest{}
+	if err := ctx.BodyParser(disableTOTPRequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return disableTOTPRequest, nil
+}
+
+func (p *ParamHandler) NewPersonalTokenPayload(ctx *fiber.Ctx) (*schema.NewPersonalTokenRequest, error) {
+	tokenRequest := &schema.NewPersonalTokenRequest{}
+	if err := ctx.BodyParser(tokenRequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return tokenRequest, nil
+}
+
 // Card params
 
+func (p *ParamHandler) DeleteAccountPayload(ctx *fiber.Ctx) (*schema.DeleteAccountRequest, error) {
+	deleteAccountRequest := &schema.DeleteAccountRequest{}
+	if err := ctx.BodyParser(deleteAccountRequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return deleteAccountRequest, nil
+}
+
+func (p *ParamHandler) StepUpPayload(ctx *fiber.Ctx) (*schema.StepUpRequest, error) {
+	stepUpRequest := &schema.StepUpRequest{}
+	if err := ctx.BodyParser(stepUpRequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return stepUpRequest, nil
+}
+
+func (p *ParamHandler) NarrowTokenPayload(ctx *fiber.Ctx) (*schema.NarrowTokenRequest, error) {
+	narrowTokenRequest := &schema.NarrowTokenRequest{}
+	if err := ctx.BodyParser(narrowTokenRequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return narrowTokenRequest, nil
+}
+
+func (p *ParamHandler) WebAuthnRegistrationPayload(ctx *fiber.Ctx) (*schema.WebAuthnRegistrationRequest, error) {
+	webAuthnRegistrationRequest := &schema.WebAuthnRegistrationRequest{}
+	if err := ctx.BodyParser(webAuthnRegistrationRequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return webAuthnRegistrationRequest, nil
+}
+
+func (p *ParamHandler) DeleteWebAuthnCredentialPayload(ctx *fiber.Ctx) (*schema.DeleteWebAuthnCredentialRequest, error) {
+	deleteRequest := &schema.DeleteWebAuthnCredentialRequest{}
+	if len(ctx.Body()) == 0 {
+		return deleteRequest, nil
+	}
+
+	if err := ctx.BodyParser(deleteRequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return deleteRequest, nil
+}
+
+func (p *ParamHandler) WebAuthnLoginPayload(ctx *fiber.Ctx) (*schema.WebAuthnLoginRequest, error) {
+	webAuthnLoginRequest := &schema.WebAuthnLoginRequest{}
+	if err := ctx.BodyParser(webAuthnLoginRequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return webAuthnLoginRequest, nil
+}
+
+func (p *ParamHandler) WebAuthnMFABeginPayload(ctx *fiber.Ctx) (*schema.WebAuthnMFABeginRequest, error) {
+	webAuthnMFABeginRequest := &schema.WebAuthnMFABeginRequest{}
+	if err := ctx.BodyParser(webAuthnMFABeginRequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return webAuthnMFABeginRequest, nil
+}
+
+func (p *ParamHandler) WebAuthnMFAPayload(ctx *fiber.Ctx) (*schema.WebAuthnMFARequest, error) {
+	webAuthnMFARequest := &schema.WebAuthnMFARequest{}
+	if err := ctx.BodyParser(webAuthnMFARequest); err != nil {
+		return nil, &shared.ServiceError{
+			Response:   err,
+			StatusCode: fiber.StatusBadRequest,
+			ErrorCode:  shared.BadRequest,
+		}
+	}
+
+	return webAuthnMFARequest, nil
+}
+
 func (p *ParamHandler) CardOptionsPayload(c *fiber.Ctx) (*schema.CardOptions, error) {
 	cardOptions := new(schema.CardOptions)
 	if err := c.BodyParser(cardOptions); err != nil {
@@ -200,6 +475,102 @@ func (p *ParamHandler) UpdateCardPayload(c *fiber.Ctx) (*schema.UpdateCardReques
 	return updatePayload, nil
 }
 
+// AuditContext describes current request for audit records
+func (p *ParamHandler) AuditContext(c *fiber.Ctx) (*schema.AuditContext, error) {
+	userId, err := p.GetUserIdFromLocals(c)
+	if err != nil {
+		return nil, err
+	}
+
+	requestId, _ := c.Locals("requestid").(string)
+	return &schema.AuditContext{
+		ActorId:   *userId,
+		RequestId: requestId,
+		IPAddress: c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+	}, nil
+}
+
+// AuditLimit reads optional limit query parameter
+func (p *ParamHandler) AuditLimit(c *fiber.Ctx) (uint64, error) {
+	limit := c.Query("limit")
+	if limit == "" {
+		return DefaultAuditLimit, nil
+	}
+
+	value, err := strconv.ParseUint(limit, 10, 64)
+	if err != nil || value == 0 || value > MaxAuditLimit {
+		return 0, http.BadRequestWithMessage(fmt.Sprintf("limit should be between 1 and %d", MaxAuditLimit))
+	}
+
+	return value, nil
+}
+
+// CardListParams reads pagination, filtering and sorting query parameters,
+// dates are expected in RFC 3339 format.
+func (p *ParamHandler) CardListParams(c *fiber.Ctx) (*schema.CardListRequest, error) {
+	listRequest := &schema.CardListRequest{
+		Limit:  DefaultCardsLimit,
+		Cursor: c.Query("cursor"),
+		Title:  strings.TrimSpace(c.Query("title")),
+		Sort:   c.Query("sort", repo.SortByCreatedAt),
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		value, err := strconv.ParseUint(limit, 10, 64)
+		if err != nil || value == 0 || value > MaxCardsLimit {
+			return nil, http.BadRequestWithMessage(fmt.Sprintf("limit should be between 1 and %d", MaxCardsLimit))
+		}
+
+		listRequest.Limit = value
+	}
+
+	switch c.Query("title_match", "contains") {
+	case "contains":
+	case "prefix":
+		listRequest.TitlePrefix = true
+	default:
+		return nil, http.BadRequestWithMessage("title_match should be contains or prefix")
+	}
+
+	switch listRequest.Sort {
+	case repo.SortByCreatedAt, repo.SortByUpdatedAt, repo.SortByTitle:
+	default:
+		return nil, http.BadRequestWithMessage("sort should be created_at, updated_at or title")
+	}
+
+	switch c.Query("order", "desc") {
+	case "desc":
+	case "asc":
+		listRequest.Ascending = true
+	default:
+		return nil, http.BadRequestWithMessage("order should be asc or desc")
+	}
+
+	dateParams := map[string]**time.Time{
+		"created_after":  &listRequest.CreatedAfter,
+		"created_before": &listRequest.CreatedBefore,
+		"updated_after":  &listRequest.UpdatedAfter,
+		"updated_before": &listRequest.UpdatedBefore,
+	}
+
+	for paramName, target := range dateParams {
+		value := c.Query(paramName)
+		if value == "" {
+			continue
+		}
+
+		date, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, http.BadRequestWithMessage(fmt.Sprintf("%s should be RFC 3339 date", paramName))
+		}
+
+		*target = &date
+	}
+
+	return listRequest, nil
+}
+
 func (p *ParamHandler) EnsureCardClaim(c *fiber.Ctx) (*schema.CardClaim, error) {
 	cardId, err := p.GetUUIDParam(c, "card_id")
 	if err != nil {
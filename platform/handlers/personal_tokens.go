@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreatePersonalToken godoc
+// @Summary Create personal access token
+// @Description Create personal access token, token is only returned once
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Param payload body schema.NewPersonalTokenRequest true "Token name, scopes and optional expiration"
+// @Failure 400 {object} shared.HTTPError Invalid name, scope or expiration
+// @Failure 401 {object} shared.HTTPError Reauthentication required
+// @Success 201 {object} schema.NewPersonalTokenResponse
+// @Router /accounts/tokens [post]
+func (h *Handler) CreatePersonalToken(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	tokenRequest, err := h.Params.NewPersonalTokenPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	token, err := h.PersonalTokenService.Create(*userId, tokenRequest)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(token)
+}
+
+// ListPersonalTokens godoc
+// @Summary List personal access tokens of current user
+// @Description List personal access tokens of current user
+// @Tags accounts
+// @Produce json
+// @Success 200 {object} []schema.PersonalTokenResponse
+// @Router /accounts/tokens [get]
+func (h *Handler) ListPersonalTokens(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := h.PersonalTokenService.List(*userId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(tokens)
+}
+
+// RevokePersonalToken godoc
+// @Summary Revoke personal access token
+// @Description Revoke personal access token
+// @Tags accounts
+// @Produce json
+// @Failure 404 {object} shared.HTTPError Token not found
+// @Success 204 {string} nil token revoked
+// @Router /accounts/tokens/{token_id} [delete]
+func (h *Handler) RevokePersonalToken(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	tokenId, err := h.Params.GetUUIDParam(ctx, "token_id")
+	if err != nil {
+		return err
+	}
+
+	err = h.PersonalTokenService.Revoke(*userId, *tokenId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
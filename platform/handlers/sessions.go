@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sultaniman/confetti/platform/services"
+)
+
+// ListSessions godoc
+// @Summary List active sessions of current user
+// @Description List active sessions of current user
+// @Tags accounts
+// @Produce json
+// @Success 200 {object} []schema.SessionResponse
+// @Router /accounts/sessions [get]
+func (h *Handler) ListSessions(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := h.SessionService.List(*userId, ctx.Cookies(services.RefreshTokenCookieName, ""))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(sessions)
+}
+
+// RevokeSession godoc
+// @Summary Revoke session of current user
+// @Description Revoke session of current user
+// @Tags accounts
+// @Produce json
+// @Failure 404 {object} shared.HTTPError Session not found
+// @Success 204 {string} nil session revoked
+// @Router /accounts/sessions/{session_id} [delete]
+func (h *Handler) RevokeSession(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	sessionId, err := h.Params.GetUUIDParam(ctx, "session_id")
+	if err != nil {
+		return err
+	}
+
+	err = h.SessionService.Revoke(*userId, *sessionId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// RevokeAllSessions godoc
+// @Summary Sign out from all devices
+// @Description Sign out from all devices
+// @Tags accounts
+// @Produce json
+// @Success 204 {string} nil all sessions revoked
+// @Router /accounts/sessions [delete]
+func (h *Handler) RevokeAllSessions(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = h.SessionService.RevokeAll(*userId)
+	if err != nil {
+		return err
+	}
+
+	ctx.ClearCookie(services.RefreshTokenCookieName)
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
@@ -2,8 +2,37 @@ package handlers
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"time"
 )
 
+// ListUsers godoc
+// @Summary List users
+// @Description List users with pagination, search and filters
+// @Tags users
+// @Produce json
+// @Param search query string false "Beginning of e-mail or full name"
+// @Param is_active query bool false "Filter by active status"
+// @Param is_confirmed query bool false "Filter by confirmation status"
+// @Param is_admin query bool false "Filter by admin role"
+// @Param provider query string false "Filter by provider"
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Number of users to skip"
+// @Success 200 {object} schema.UsersResponse
+// @Router /admin/users [get]
+func (h *Handler) ListUsers(ctx *fiber.Ctx) error {
+	listRequest, err := h.Params.UserListParams(ctx)
+	if err != nil {
+		return err
+	}
+
+	users, err := h.UserService.List(listRequest)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(users)
+}
+
 // GetUser godoc
 // @Summary Get user by ID
 // @Description Get user by ID
@@ -90,7 +119,7 @@ func (h *Handler) UpdateEmail(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	user, err = h.UserService.UpdateEmail(user.ID, updateUserEmailPayload)
+	user, err = h.UserService.UpdateEmail(user.ID, updateUserEmailPayload, time.Time{})
 	if err != nil {
 		return err
 	}
@@ -118,7 +147,7 @@ func (h *Handler) UpdatePassword(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	user, err = h.UserService.UpdatePassword(user.ID, updateUserPasswordPayload)
+	user, err = h.UserService.UpdatePassword(user.ID, updateUserPasswordPayload, time.Time{})
 	if err != nil {
 		return err
 	}
@@ -130,16 +159,40 @@ func (h *Handler) UpdatePassword(ctx *fiber.Ctx) error {
 
 // DeleteUser godoc
 // @Summary Delete user
-// @Description Delete user
+// @Description Permanently deletes user with all cards and sessions, no grace period applies
 // @Tags users
 // @Produce json
+// @Failure 400 {object} shared.HTTPError Admin can not delete themselves
+// @Failure 404 {object} shared.HTTPError User not found
 // @Success 200 {object} schema.UserResponse
-// @Router /user [delete]
+// @Router /admin/users/{user_id} [delete]
 func (h *Handler) DeleteUser(ctx *fiber.Ctx) error {
-	user, err := h.Params.GetUser(ctx)
+	return h.performUserAction(ctx, h.AdminService.Delete)
+}
+
+// ClearLockout godoc
+// @Summary Clear sign in lockout of user
+// @Description Resets failed sign in attempts so that user can sign in immediately
+// @Tags users
+// @Produce json
+// @Failure 404 {object} shared.HTTPError User not found
+// @Success 204 {string} nil lockout cleared
+// @Router /admin/users/{user_id}/lockout [delete]
+func (h *Handler) ClearLockout(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUUIDParam(ctx, "user_id")
 	if err != nil {
 		return err
 	}
 
-	return ctx.JSON(user)
+	auditContext, err := h.Params.AuditContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = h.AdminService.ClearLockout(*userId, auditContext)
+	if err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
 }
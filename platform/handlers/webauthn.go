@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// BeginWebAuthnRegistration godoc
+// @Summary Start passkey registration
+// @Description Returns options for navigator.credentials.create
+// @Tags accounts
+// @Produce json
+// @Failure 401 {object} shared.HTTPError Recent authentication is required
+// @Success 200 {object} webauthn.CreationOptions
+// @Router /accounts/webauthn/register/begin [post]
+func (h *Handler) BeginWebAuthnRegistration(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	options, err := h.WebAuthnService.BeginRegistration(*userId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(options)
+}
+
+// FinishWebAuthnRegistration godoc
+// @Summary Finish passkey registration
+// @Description Verifies attestation returned by authenticator and saves passkey, requires recent
+// @Description authentication and verification code when two-factor authentication is enabled.
+// @Tags accounts
+// @Produce json
+// @Failure 401 {object} shared.HTTPError Passkey verification failed
+// @Failure 409 {object} shared.HTTPError Passkey is already registered
+// @Success 201 {object} schema.WebAuthnCredentialResponse
+// @Router /accounts/webauthn/register/finish [post]
+func (h *Handler) FinishWebAuthnRegistration(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	registrationPayload, err := h.Params.WebAuthnRegistrationPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	credential, err := h.WebAuthnService.FinishRegistration(*userId, registrationPayload)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(credential)
+}
+
+// ListWebAuthnCredentials godoc
+// @Summary List passkeys of current user
+// @Description List passkeys of current user
+// @Tags accounts
+// @Produce json
+// @Success 200 {object} []schema.WebAuthnCredentialResponse
+// @Router /accounts/webauthn/credentials [get]
+func (h *Handler) ListWebAuthnCredentials(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	credentials, err := h.WebAuthnService.ListCredentials(*userId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(credentials)
+}
+
+// DeleteWebAuthnCredential godoc
+// @Summary Delete passkey of current user
+// @Description Delete passkey of current user, requires recent authentication
+// @Description and verification code when two-factor authentication is enabled.
+// @Tags accounts
+// @Produce json
+// @Failure 401 {object} shared.HTTPError Recent authentication is required
+// @Failure 404 {object} shared.HTTPError Passkey not found
+// @Success 204 {string} nil passkey deleted
+// @Router /accounts/webauthn/credentials/{credential_id} [delete]
+func (h *Handler) DeleteWebAuthnCredential(ctx *fiber.Ctx) error {
+	userId, err := h.Params.GetUserIdFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	credentialId, err := h.Params.GetUUIDParam(ctx, "credential_id")
+	if err != nil {
+		return err
+	}
+
+	deletePayload, err := h.Params.DeleteWebAuthnCredentialPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = h.WebAuthnService.DeleteCredential(*userId, *credentialId, deletePayload.Code)
+	if err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// BeginWebAuthnLogin godoc
+// @Summary Start passwordless login with passkey
+// @Description Returns options for navigator.credentials.get
+// @Tags auth
+// @Produce json
+// @Success 200 {object} webauthn.RequestOptions
+// @Router /auth/webauthn/login/begin [post]
+func (h *Handler) BeginWebAuthnLogin(ctx *fiber.Ctx) error {
+	options, err := h.AuthService.WebAuthnLoginBegin()
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(options)
+}
+
+// WebAuthnLoginFlow godoc
+// @Summary Complete passwordless login with passkey
+// @Description Exchanges passkey assertion for access and refresh tokens
+// @Tags auth
+// @Produce json
+// @Failure 401 {object} shared.HTTPError Passkey verification failed
+// @Success 200 {object} schema.TokenResponse
+// @Router /auth/webauthn/login/finish [post]
+func (h *Handler) WebAuthnLoginFlow(ctx *fiber.Ctx) error {
+	loginPayload, err := h.Params.WebAuthnLoginPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	tokenResponse, err := h.AuthService.WebAuthnLoginFlow(ctx, loginPayload)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(tokenResponse)
+}
+
+// BeginWebAuthnMFA godoc
+// @Summary Start passkey two-factor verification
+// @Description Returns options for navigator.credentials.get for the user of challenge token
+// @Tags auth
+// @Produce json
+// @Success 200 {object} webauthn.RequestOptions
+// @Router /auth/webauthn/mfa/begin [post]
+func (h *Handler) BeginWebAuthnMFA(ctx *fiber.Ctx) error {
+	mfaPayload, err := h.Params.WebAuthnMFABeginPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	options, err := h.AuthService.WebAuthnMFABegin(mfaPayload)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(options)
+}
+
+// WebAuthnMFAFlow godoc
+// @Summary Complete authentication with passkey as second factor
+// @Description Exchanges challenge token and passkey assertion for access and refresh tokens
+// @Tags auth
+// @Produce json
+// @Failure 401 {object} shared.HTTPError Passkey verification failed
+// @Success 200 {object} schema.TokenResponse
+// @Router /auth/webauthn/mfa/finish [post]
+func (h *Handler) WebAuthnMFAFlow(ctx *fiber.Ctx) error {
+	mfaPayload, err := h.Params.WebAuthnMFAPayload(ctx)
+	if err != nil {
+		return err
+	}
+
+	tokenResponse, err := h.AuthService.WebAuthnMFAFlow(ctx, mfaPayload)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(tokenResponse)
+}
@@ -118,6 +118,33 @@ func InactiveUserError() *shared.ServiceError {
 	}
 }
 
+func ReauthenticationRequiredError() *shared.ServiceError {
+	return &shared.ServiceError{
+		Response:             "Recent authentication is required",
+		StatusCode:           fiber.StatusUnauthorized,
+		ErrorCode:            shared.ReauthenticationRequired,
+		UseResponseAsMessage: shared.Bool(true),
+	}
+}
+
+func TooManyRequestsError(message string) *shared.ServiceError {
+	return &shared.ServiceError{
+		Response:             message,
+		StatusCode:           fiber.StatusTooManyRequests,
+		ErrorCode:            shared.TooManyRequests,
+		UseResponseAsMessage: shared.Bool(true),
+	}
+}
+
+func AccountLockedError() *shared.ServiceError {
+	return &shared.ServiceError{
+		Response:             "Account is temporarily locked due to too many failed attempts",
+		StatusCode:           fiber.StatusTooManyRequests,
+		ErrorCode:            shared.AccountLocked,
+		UseResponseAsMessage: shared.Bool(true),
+	}
+}
+
 func InsecurePasswordError() *shared.ServiceError {
 	return &shared.ServiceError{
 		Response:             "Insecure password",
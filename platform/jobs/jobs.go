@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+type JobFunc func() error
+
+// Every runs job in background once per interval until context is done,
+// failed runs are logged and retried on the next tick. Non-positive interval disables job.
+func Every(ctx context.Context, name string, interval time.Duration, job JobFunc) {
+	if interval <= 0 {
+		log.Info().Str("job", name).Msg("Job is disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			run(name, job)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func run(name string, job JobFunc) {
+	startedAt := time.Now()
+	if err := job(); err != nil {
+		log.Error().
+			Err(err).
+			Str("job", name).
+			Msg("Job failed")
+		return
+	}
+
+	log.Debug().
+		Str("job", name).
+		Dur("duration", time.Since(startedAt)).
+		Msg("Job finished")
+}
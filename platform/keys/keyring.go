@@ -0,0 +1,195 @@
+package keys
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"os"
+	"strings"
+	"time"
+)
+
+const DefaultKeyID = "v1"
+
+var (
+	ErrKeyNotFound = errors.New("encryption key not found")
+	ErrKeyRevoked  = errors.New("encryption key is revoked")
+	ErrKeyExpired  = errors.New("encryption key is no longer valid")
+)
+
+// KeySpec describes where to load a key from and its lifecycle
+type KeySpec struct {
+	KeyID        string     `json:"key_id"`
+	KeyName      string     `json:"key_name"`
+	Path         string     `json:"path"`
+	Revoked      bool       `json:"revoked"`
+	InvalidAfter *time.Time `json:"invalid_after"`
+	// Algorithm is only used by signing keys, by default it is derived from the key
+	Algorithm string `json:"algorithm,omitempty"`
+}
+
+// KeyringManifest lists all server keys, the active one is
+// used to encrypt and the rest are kept to decrypt existing data.
+type KeyringManifest struct {
+	ActiveKeyID string    `json:"active_key_id"`
+	Keys        []KeySpec `json:"keys"`
+}
+
+// Keyring holds the active key and all retired keys
+type Keyring struct {
+	ActiveKeyID string
+	Keys        KeySet
+}
+
+// SingleKeyManifest builds manifest with a single active key
+func SingleKeyManifest(keyID string, path string) *KeyringManifest {
+	return &KeyringManifest{
+		ActiveKeyID: keyID,
+		Keys: []KeySpec{
+			{
+				KeyID:   keyID,
+				KeyName: keyID,
+				Path:    path,
+			},
+		},
+	}
+}
+
+// ReadManifest reads keyring manifest from filesystem
+func ReadManifest(path string) (*KeyringManifest, error) {
+	path = strings.TrimPrefix(path, "file://")
+	rawManifest, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := new(KeyringManifest)
+	if err = json.Unmarshal(rawManifest, manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+func LoadKeyring(loader KeyLoader, manifest *KeyringManifest) (*Keyring, error) {
+	keyring := &Keyring{
+		ActiveKeyID: manifest.ActiveKeyID,
+	}
+
+	for _, spec := range manifest.Keys {
+		if _, found := keyring.Keys.Get(spec.KeyID); found {
+			return nil, fmt.Errorf("duplicate key id %s", spec.KeyID)
+		}
+
+		privateKey, err := loader.Load(spec.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		keyring.Keys = append(keyring.Keys, EncryptionKey{
+			PrivateKey:   privateKey,
+			KeyName:      spec.KeyName,
+			KeyID:        spec.KeyID,
+			Revoked:      spec.Revoked,
+			InvalidAfter: spec.InvalidAfter,
+		})
+
+		log.Info().
+			Str("key_id", spec.KeyID).
+			Bool("revoked", spec.Revoked).
+			Msg("Loaded encryption key")
+	}
+
+	if _, err := keyring.Active(); err != nil {
+		return nil, fmt.Errorf("active key %s: %w", manifest.ActiveKeyID, err)
+	}
+
+	return keyring, nil
+}
+
+// Validate checks if key can be used at the given moment
+func (k *EncryptionKey) Validate(now time.Time) error {
+	if k.Revoked {
+		return ErrKeyRevoked
+	}
+
+	if k.InvalidAfter != nil && now.After(*k.InvalidAfter) {
+		return ErrKeyExpired
+	}
+
+	return nil
+}
+
+func (s KeySet) Get(keyID string) (*EncryptionKey, bool) {
+	for i := range s {
+		if s[i].KeyID == keyID {
+			return &s[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// Active returns the key which is used to encrypt new data, it must be valid
+func (k *Keyring) Active() (*EncryptionKey, error) {
+	return k.EncryptionKey(k.ActiveKeyID)
+}
+
+// Get returns key for a given key id, revoked and expired keys are returned
+// as well since data encrypted with them still has to be decrypted.
+func (k *Keyring) Get(keyID string) (*EncryptionKey, error) {
+	key, found := k.Keys.Get(keyID)
+	if !found {
+		return nil, ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+// EncryptionKey returns key for a given key id if it can be used to encrypt
+func (k *Keyring) EncryptionKey(keyID string) (*EncryptionKey, error) {
+	key, err := k.Get(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = key.Validate(time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Encrypt wraps plaintext with the active key using RSA-OAEP
+// and returns ciphertext together with the id of the key used.
+func (k *Keyring) Encrypt(plaintext []byte) ([]byte, string, error) {
+	key, err := k.Active()
+	if err != nil {
+		return nil, "", err
+	}
+
+	ciphertext, err := EncryptWithKey(key, plaintext)
+	return ciphertext, key.KeyID, err
+}
+
+// Decrypt unwraps ciphertext with the key it was encrypted with, key validity is not checked
+func (k *Keyring) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	key, err := k.Get(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecryptWithKey(key, ciphertext)
+}
+
+func EncryptWithKey(key *EncryptionKey, plaintext []byte) ([]byte, error) {
+	return rsa.EncryptOAEP(sha512.New(), rand.Reader, &key.PrivateKey.PublicKey, plaintext, nil)
+}
+
+func DecryptWithKey(key *EncryptionKey, ciphertext []byte) ([]byte, error) {
+	return rsa.DecryptOAEP(sha512.New(), rand.Reader, key.PrivateKey, ciphertext, nil)
+}
@@ -1,6 +1,7 @@
 package keys
 
 import (
+	"crypto"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -17,8 +18,11 @@ import (
 	"time"
 )
 
+// KeyLoader reads PEM encoded keys, encryption keys are always RSA
+// while signing keys can also be ECDSA or Ed25519.
 type KeyLoader interface {
 	Load(path string) (*rsa.PrivateKey, error)
+	LoadSigner(path string) (crypto.Signer, error)
 }
 
 type EncryptionKey struct {
@@ -39,6 +43,24 @@ func NewRemoteLoader() KeyLoader {
 }
 
 func (r *RemoteLoader) Load(path string) (*rsa.PrivateKey, error) {
+	rawKey, err := r.download(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeRSAKey(rawKey)
+}
+
+func (r *RemoteLoader) LoadSigner(path string) (crypto.Signer, error) {
+	rawKey, err := r.download(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeSigningKey(rawKey)
+}
+
+func (r *RemoteLoader) download(path string) ([]byte, error) {
 	key := viper.GetString("spaces_key")
 	secret := viper.GetString("spaces_secret")
 	s3Config := &aws.Config{
@@ -72,8 +94,7 @@ func (r *RemoteLoader) Load(path string) (*rsa.PrivateKey, error) {
 		return nil, err
 	}
 
-	rsaKey, err := decodeRSAKey(result.Bytes())
-	return rsaKey, err
+	return result.Bytes(), nil
 }
 
 // FSLoader Filesystem key loader
@@ -84,6 +105,24 @@ func NewFSLoader() KeyLoader {
 }
 
 func (s *FSLoader) Load(path string) (*rsa.PrivateKey, error) {
+	rawKey, err := s.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeRSAKey(rawKey)
+}
+
+func (s *FSLoader) LoadSigner(path string) (crypto.Signer, error) {
+	rawKey, err := s.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeSigningKey(rawKey)
+}
+
+func (s *FSLoader) readFile(path string) ([]byte, error) {
 	if strings.HasPrefix(path, "file://") {
 		path = strings.TrimPrefix(path, "file://")
 	}
@@ -98,7 +137,7 @@ func (s *FSLoader) Load(path string) (*rsa.PrivateKey, error) {
 		return nil, err
 	}
 
-	return decodeRSAKey(rawKey)
+	return rawKey, nil
 }
 
 func GetLoader(loaderName string) KeyLoader {
@@ -113,3 +152,32 @@ func decodeRSAKey(rawKey []byte) (*rsa.PrivateKey, error) {
 	privateKeyPem, _ := pem.Decode(rawKey)
 	return x509.ParsePKCS1PrivateKey(privateKeyPem.Bytes)
 }
+
+// decodeSigningKey reads PKCS #1 RSA, SEC 1 EC or PKCS #8 RSA, ECDSA and Ed25519 private keys
+func decodeSigningKey(rawKey []byte) (crypto.Signer, error) {
+	privateKeyPem, _ := pem.Decode(rawKey)
+	if privateKeyPem == nil {
+		return nil, ErrInvalidKey
+	}
+
+	switch privateKeyPem.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(privateKeyPem.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(privateKeyPem.Bytes)
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(privateKeyPem.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, ok := privateKey.(crypto.Signer)
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+
+		return signer, nil
+	}
+
+	return nil, fmt.Errorf("%w: unsupported PEM block %s", ErrInvalidKey, privateKeyPem.Type)
+}
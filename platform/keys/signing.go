@@ -0,0 +1,177 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/rs/zerolog/log"
+	"strings"
+	"time"
+)
+
+// LegacySigningKeyID is the key id of tokens which were signed with the encryption key
+const LegacySigningKeyID = "default"
+
+var (
+	ErrInvalidKey           = errors.New("invalid private key")
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+)
+
+// SigningAlgorithms can be used to sign tokens, RS256 requires RSA key, ES256 and ES384
+// require EC key on P-256 and P-384 curve and EdDSA requires Ed25519 key.
+var SigningAlgorithms = []jwa.SignatureAlgorithm{jwa.RS256, jwa.ES256, jwa.ES384, jwa.EdDSA}
+
+// SigningKey signs access, refresh and challenge tokens, it is kept
+// apart from encryption keys so that both can be rotated independently.
+type SigningKey struct {
+	PrivateKey   crypto.Signer
+	Algorithm    jwa.SignatureAlgorithm
+	KeyID        string
+	Revoked      bool
+	InvalidAfter *time.Time
+}
+
+// SigningKeyring holds the active signing key and keys which are still published
+// in JWKS, retired keys should stay until tokens signed with them expire.
+type SigningKeyring struct {
+	ActiveKeyID string
+	Keys        []SigningKey
+}
+
+// LegacySigningKeyring signs tokens with the encryption key as it was done
+// before signing keys were introduced, it is only used when enabled with signing_legacy_key.
+func LegacySigningKeyring(key *EncryptionKey) *SigningKeyring {
+	return &SigningKeyring{
+		ActiveKeyID: LegacySigningKeyID,
+		Keys: []SigningKey{
+			{
+				PrivateKey: key.PrivateKey,
+				Algorithm:  jwa.RS256,
+				KeyID:      LegacySigningKeyID,
+			},
+		},
+	}
+}
+
+func LoadSigningKeyring(loader KeyLoader, manifest *KeyringManifest) (*SigningKeyring, error) {
+	keyring := &SigningKeyring{
+		ActiveKeyID: manifest.ActiveKeyID,
+	}
+
+	for _, spec := range manifest.Keys {
+		if _, found := keyring.get(spec.KeyID); found {
+			return nil, fmt.Errorf("duplicate signing key id %s", spec.KeyID)
+		}
+
+		privateKey, err := loader.LoadSigner(spec.Path)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", spec.KeyID, err)
+		}
+
+		algorithm, err := SigningAlgorithm(privateKey, spec.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", spec.KeyID, err)
+		}
+
+		keyring.Keys = append(keyring.Keys, SigningKey{
+			PrivateKey:   privateKey,
+			Algorithm:    algorithm,
+			KeyID:        spec.KeyID,
+			Revoked:      spec.Revoked,
+			InvalidAfter: spec.InvalidAfter,
+		})
+
+		log.Info().
+			Str("key_id", spec.KeyID).
+			Str("algorithm", algorithm.String()).
+			Bool("revoked", spec.Revoked).
+			Msg("Loaded signing key")
+	}
+
+	if _, err := keyring.Active(); err != nil {
+		return nil, fmt.Errorf("active signing key %s: %w", manifest.ActiveKeyID, err)
+	}
+
+	return keyring, nil
+}
+
+// SigningAlgorithm checks that algorithm can be used with the key,
+// when algorithm is not given it is derived from the key type and curve.
+func SigningAlgorithm(privateKey crypto.Signer, algorithm string) (jwa.SignatureAlgorithm, error) {
+	var keyAlgorithm jwa.SignatureAlgorithm
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		keyAlgorithm = jwa.RS256
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			keyAlgorithm = jwa.ES256
+		case elliptic.P384():
+			keyAlgorithm = jwa.ES384
+		default:
+			return "", fmt.Errorf("%w: unsupported curve %s", ErrInvalidKey, key.Curve.Params().Name)
+		}
+	case ed25519.PrivateKey:
+		keyAlgorithm = jwa.EdDSA
+	default:
+		return "", fmt.Errorf("%w: unsupported key type %T", ErrInvalidKey, privateKey)
+	}
+
+	if algorithm == "" {
+		return keyAlgorithm, nil
+	}
+
+	for _, supported := range SigningAlgorithms {
+		if strings.EqualFold(supported.String(), algorithm) {
+			if supported != keyAlgorithm {
+				return "", fmt.Errorf("%w: %s requires another key type, key is suitable for %s", ErrUnsupportedAlgorithm, supported, keyAlgorithm)
+			}
+
+			return supported, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+}
+
+// Validate checks if key can be used at the given moment
+func (k *SigningKey) Validate(now time.Time) error {
+	if k.Revoked {
+		return ErrKeyRevoked
+	}
+
+	if k.InvalidAfter != nil && now.After(*k.InvalidAfter) {
+		return ErrKeyExpired
+	}
+
+	return nil
+}
+
+// Active returns the key which signs new tokens
+func (k *SigningKeyring) Active() (*SigningKey, error) {
+	key, found := k.get(k.ActiveKeyID)
+	if !found {
+		return nil, ErrKeyNotFound
+	}
+
+	if err := key.Validate(time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (k *SigningKeyring) get(keyID string) (*SigningKey, bool) {
+	for i := range k.Keys {
+		if k.Keys[i].KeyID == keyID {
+			return &k.Keys[i], true
+		}
+	}
+
+	return nil, false
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/spf13/viper"
+	"time"
 )
 
 type dummyMailer struct{}
@@ -32,6 +33,57 @@ func NewDummyMailer() Mailer {
 	return &dummyMailer{}
 }
 
+func (d *dummyMailer) SendLockoutNotice(toEmail string, lockedUntil time.Time) error {
+	msg := lockoutNoticeText(lockedUntil)
+	return d.Send(&EmailMessage{
+		Subject:  "Your account was temporarily locked",
+		ToEmail:  toEmail,
+		TextBody: msg,
+		HTMLBody: msg,
+	})
+}
+
+func (d *dummyMailer) SendAccountDeleted(toEmail string) error {
+	return d.Send(&EmailMessage{
+		Subject:  "Your account was deleted",
+		ToEmail:  toEmail,
+		TextBody: accountDeletedText,
+		HTMLBody: accountDeletedText,
+	})
+}
+
+func (d *dummyMailer) SendEmailChangeCode(toEmail string, code string) error {
+	link := fmt.Sprintf("https://%s/confirm-email/%s", viper.GetString("app_host"), code)
+	msg := fmt.Sprintf("Please click the following link to confirm your new e-mail: %s", link)
+	return d.Send(&EmailMessage{
+		Subject:  "Confirm your new e-mail",
+		ToEmail:  toEmail,
+		TextBody: msg,
+		HTMLBody: msg,
+	})
+}
+
+func (d *dummyMailer) SendEmailChangeNotice(toEmail string, newEmail string) error {
+	msg := emailChangeNoticeText(newEmail)
+	return d.Send(&EmailMessage{
+		Subject:  "Your account e-mail is being changed",
+		ToEmail:  toEmail,
+		TextBody: msg,
+		HTMLBody: msg,
+	})
+}
+
+func (d *dummyMailer) SendMagicLink(toEmail string, code string) error {
+	link := fmt.Sprintf("https://%s/magic-link/%s", viper.GetString("app_host"), code)
+	msg := fmt.Sprintf("Please click the following link to sign in, it can be used only once: %s", link)
+	return d.Send(&EmailMessage{
+		Subject:  "Your sign in link",
+		ToEmail:  toEmail,
+		TextBody: msg,
+		HTMLBody: msg,
+	})
+}
+
 func (d *dummyMailer) Send(message *EmailMessage) error {
 	fmt.Println("[Dummy Mailer] start")
 	spew.Dump(message)
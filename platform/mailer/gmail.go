@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/spf13/viper"
 	"net/smtp"
+	"time"
 )
 
 type gmailMailer struct {
@@ -27,6 +28,57 @@ func (g *gmailMailer) SendPasswordResetCode(toEmail, code string) error {
 	panic("implement me")
 }
 
+func (g *gmailMailer) SendLockoutNotice(toEmail string, lockedUntil time.Time) error {
+	msg := lockoutNoticeText(lockedUntil)
+	return g.Send(&EmailMessage{
+		Subject:  "Your account was temporarily locked",
+		ToEmail:  toEmail,
+		TextBody: msg,
+		HTMLBody: msg,
+	})
+}
+
+func (g *gmailMailer) SendAccountDeleted(toEmail string) error {
+	return g.Send(&EmailMessage{
+		Subject:  "Your account was deleted",
+		ToEmail:  toEmail,
+		TextBody: accountDeletedText,
+		HTMLBody: accountDeletedText,
+	})
+}
+
+func (g *gmailMailer) SendEmailChangeCode(toEmail string, code string) error {
+	link := fmt.Sprintf("%s/%s", viper.GetString("confirm_email_url"), code)
+	msg := fmt.Sprintf("Please click the following link to confirm your new e-mail: %s", link)
+	return g.Send(&EmailMessage{
+		Subject:  "Confirm your new e-mail",
+		ToEmail:  toEmail,
+		TextBody: msg,
+		HTMLBody: msg,
+	})
+}
+
+func (g *gmailMailer) SendEmailChangeNotice(toEmail string, newEmail string) error {
+	msg := emailChangeNoticeText(newEmail)
+	return g.Send(&EmailMessage{
+		Subject:  "Your account e-mail is being changed",
+		ToEmail:  toEmail,
+		TextBody: msg,
+		HTMLBody: msg,
+	})
+}
+
+func (g *gmailMailer) SendMagicLink(toEmail string, code string) error {
+	link := fmt.Sprintf("%s/%s", viper.GetString("magic_link_url"), code)
+	msg := fmt.Sprintf("Please click the following link to sign in, it can be used only once: %s", link)
+	return g.Send(&EmailMessage{
+		Subject:  "Your sign in link",
+		ToEmail:  toEmail,
+		TextBody: msg,
+		HTMLBody: msg,
+	})
+}
+
 func (g *gmailMailer) Send(message *EmailMessage) error {
 	fmt.Println("[Gmail Mailer] start")
 
@@ -1,7 +1,9 @@
 package mailer
 
 import (
+	"fmt"
 	"github.com/spf13/viper"
+	"time"
 )
 
 type EmailMessage struct {
@@ -15,6 +17,30 @@ type Mailer interface {
 	Send(message *EmailMessage) error
 	SendConfirmationCode(toEmail string, code string) error
 	SendPasswordResetCode(toEmail, code string) error
+	SendLockoutNotice(toEmail string, lockedUntil time.Time) error
+	SendAccountDeleted(toEmail string) error
+	SendEmailChangeCode(toEmail string, code string) error
+	SendEmailChangeNotice(toEmail string, newEmail string) error
+	SendMagicLink(toEmail string, code string) error
+}
+
+func lockoutNoticeText(lockedUntil time.Time) string {
+	return fmt.Sprintf(
+		"Your account was temporarily locked until %s after too many failed sign in attempts. "+
+			"If it was not you, please reset your password.",
+		lockedUntil.UTC().Format(time.RFC1123),
+	)
+}
+
+const accountDeletedText = "Your account and all of its cards were permanently deleted. " +
+	"If you did not request it, please contact support."
+
+func emailChangeNoticeText(newEmail string) string {
+	return fmt.Sprintf(
+		"A change of your account e-mail to %s was requested, it takes effect once the new address is confirmed. "+
+			"If it was not you, please reset your password.",
+		newEmail,
+	)
 }
 
 func GetMailer() Mailer {
@@ -5,6 +5,7 @@ import (
 	"github.com/mailjet/mailjet-apiv3-go"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
+	"time"
 )
 
 type mjMailer struct {
@@ -28,6 +29,57 @@ func (g *mjMailer) SendPasswordResetCode(toEmail, code string) error {
 	panic("implement me")
 }
 
+func (g *mjMailer) SendLockoutNotice(toEmail string, lockedUntil time.Time) error {
+	msg := lockoutNoticeText(lockedUntil)
+	return g.Send(&EmailMessage{
+		Subject:  "Your account was temporarily locked",
+		ToEmail:  toEmail,
+		TextBody: msg,
+		HTMLBody: msg,
+	})
+}
+
+func (g *mjMailer) SendAccountDeleted(toEmail string) error {
+	return g.Send(&EmailMessage{
+		Subject:  "Your account was deleted",
+		ToEmail:  toEmail,
+		TextBody: accountDeletedText,
+		HTMLBody: accountDeletedText,
+	})
+}
+
+func (g *mjMailer) SendEmailChangeCode(toEmail string, code string) error {
+	link := fmt.Sprintf("%s/%s", viper.GetString("confirm_email_url"), code)
+	msg := fmt.Sprintf("Please click the following link to confirm your new e-mail: %s", link)
+	return g.Send(&EmailMessage{
+		Subject:  "Confirm your new e-mail",
+		ToEmail:  toEmail,
+		TextBody: msg,
+		HTMLBody: msg,
+	})
+}
+
+func (g *mjMailer) SendEmailChangeNotice(toEmail string, newEmail string) error {
+	msg := emailChangeNoticeText(newEmail)
+	return g.Send(&EmailMessage{
+		Subject:  "Your account e-mail is being changed",
+		ToEmail:  toEmail,
+		TextBody: msg,
+		HTMLBody: msg,
+	})
+}
+
+func (g *mjMailer) SendMagicLink(toEmail string, code string) error {
+	link := fmt.Sprintf("%s/%s", viper.GetString("magic_link_url"), code)
+	msg := fmt.Sprintf("Please click the following link to sign in, it can be used only once: %s", link)
+	return g.Send(&EmailMessage{
+		Subject:  "Your sign in link",
+		ToEmail:  toEmail,
+		TextBody: msg,
+		HTMLBody: msg,
+	})
+}
+
 func (g *mjMailer) Send(message *EmailMessage) error {
 	log.Info().Msg("[MJ] Sending message start")
 	mailjetClient := mailjet.NewMailjetClient(g.apiKey, g.apiSecret)
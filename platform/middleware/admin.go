@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sultaniman/confetti/platform/http"
+)
+
+// AdminMiddleware allows only admins, it must be mounted after AuthMiddleware
+// together with admin scope. Role is taken from access token claims so admin
+// tokens are short-lived and revoking the role takes effect once they expire.
+func AdminMiddleware() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if isAdmin, _ := ctx.Locals("is_admin").(bool); !isAdmin {
+			return http.ForbiddenError("Admin access required")
+		}
+
+		return ctx.Next()
+	}
+}
@@ -5,13 +5,17 @@ import (
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jwt"
 	"github.com/sultaniman/confetti/platform/http"
+	"github.com/sultaniman/confetti/platform/services"
 	"github.com/sultaniman/confetti/platform/shared"
+	"strings"
 	"time"
 )
 
 const authScheme = "Bearer"
 
-func AuthMiddleware(authHeader string, authRequired bool, jwks *jwk.Set) fiber.Handler {
+// AuthMiddleware verifies bearer access tokens against current JWKS, when personalTokens is given
+// personal access tokens are accepted as well and their scopes are kept in locals.
+func AuthMiddleware(authHeader string, authRequired bool, jwks func() jwk.Set, personalTokens services.PersonalTokenService) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		auth := ctx.Get(authHeader)
 		if len(auth) <= len(authScheme) || auth[:len(authScheme)] != authScheme {
@@ -23,7 +27,23 @@ func AuthMiddleware(authHeader string, authRequired bool, jwks *jwk.Set) fiber.H
 		}
 
 		tokenStr := auth[len(authScheme)+1:]
-		payload, err := jwt.Parse([]byte(tokenStr), jwt.WithKeySet(*jwks))
+		if strings.HasPrefix(tokenStr, services.PersonalTokenPrefix) {
+			if personalTokens == nil {
+				return http.ForbiddenError("Personal access tokens are not accepted here")
+			}
+
+			claims, err := personalTokens.Verify(tokenStr)
+			if err != nil {
+				return err
+			}
+
+			ctx.Locals("user_id", claims.UserId.String())
+			ctx.Locals("personal_token_id", claims.TokenId.String())
+			ctx.Locals("scopes", claims.Scopes)
+			return ctx.Next()
+		}
+
+		payload, err := jwt.Parse([]byte(tokenStr), jwt.WithKeySet(jwks()))
 		if err != nil {
 			return &shared.ServiceError{
 				Response:             "failed to verify token",
@@ -42,12 +62,34 @@ func AuthMiddleware(authHeader string, authRequired bool, jwks *jwk.Set) fiber.H
 			}
 		}
 
+		// refresh and challenge tokens are signed with the same key, access tokens issued
+		// before token_use was introduced lack it as well and are rejected until refreshed
+		if tokenUse, _ := payload.Get(services.TokenUseClaim); tokenUse != services.AccessTokenUse {
+			return http.ForbiddenError("Invalid token")
+		}
+
 		subject := payload.Subject()
 		if subject == "" {
 			return http.ForbiddenError("Invalid token")
 		}
 
 		ctx.Locals("user_id", subject)
+		if isAdmin, found := payload.Get(services.IsAdminClaim); found {
+			ctx.Locals("is_admin", isAdmin)
+		}
+
+		if authTime, found := payload.Get(services.AuthTimeClaim); found {
+			if seconds, ok := authTime.(float64); ok {
+				ctx.Locals("auth_time", time.Unix(int64(seconds), 0))
+			}
+		}
+
+		if amr, found := payload.Get(services.AMRClaim); found {
+			ctx.Locals("amr", amr)
+		}
+
+		ctx.Locals("scopes", services.TokenScopes(payload))
+
 		return ctx.Next()
 	}
 }
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sultaniman/confetti/platform/http"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit allows Requests per Period with bursts up to Burst requests
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// ParseRateLimit parses limits like "10/1m", empty string or zero requests disable limiting
+func ParseRateLimit(spec string) (*RateLimit, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	parts := strings.SplitN(spec, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid rate limit %q, expected requests/period", spec)
+	}
+
+	requests, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || requests < 0 {
+		return nil, fmt.Errorf("invalid rate limit %q: bad number of requests", spec)
+	}
+
+	period, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || period <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %q: bad period", spec)
+	}
+
+	if requests == 0 {
+		return nil, nil
+	}
+
+	return &RateLimit{
+		Requests: requests,
+		Period:   period,
+		Burst:    requests,
+	}, nil
+}
+
+// RateLimitStore keeps token buckets, Take consumes a token and
+// returns how long to wait if the bucket is empty.
+type RateLimitStore interface {
+	Take(key string, limit *RateLimit, now time.Time) (bool, time.Duration)
+}
+
+// RateLimitKeyFunc returns bucket key for request, empty key skips the bucket
+type RateLimitKeyFunc func(ctx *fiber.Ctx) string
+
+func KeyByIP(ctx *fiber.Ctx) string {
+	return "ip:" + ctx.IP()
+}
+
+// KeyByEmail uses Email from JSON or form payload
+func KeyByEmail(ctx *fiber.Ctx) string {
+	payload := struct {
+		Email string
+	}{}
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ""
+	}
+
+	email := strings.ToLower(strings.TrimSpace(payload.Email))
+	if email == "" {
+		return ""
+	}
+
+	return "email:" + email
+}
+
+// KeyByUserId uses authenticated user, it must be mounted after AuthMiddleware
+func KeyByUserId(ctx *fiber.Ctx) string {
+	userId, found := ctx.Locals("user_id").(string)
+	if !found {
+		return ""
+	}
+
+	return "user:" + userId
+}
+
+type RateLimitConfig struct {
+	// Name separates buckets of different limiters
+	Name  string
+	Limit *RateLimit
+	Keys  []RateLimitKeyFunc
+	Store RateLimitStore
+}
+
+// RateLimitMiddleware rejects request once any of its buckets is empty
+func RateLimitMiddleware(config RateLimitConfig) fiber.Handler {
+	if config.Limit == nil {
+		return func(ctx *fiber.Ctx) error {
+			return ctx.Next()
+		}
+	}
+
+	if len(config.Keys) == 0 {
+		config.Keys = []RateLimitKeyFunc{KeyByIP}
+	}
+
+	if config.Store == nil {
+		config.Store = NewMemoryRateLimitStore()
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		now := time.Now()
+		for _, keyFunc := range config.Keys {
+			key := keyFunc(ctx)
+			if key == "" {
+				continue
+			}
+
+			allowed, retryAfter := config.Store.Take(config.Name+":"+key, config.Limit, now)
+			if !allowed {
+				ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				return http.TooManyRequestsError("Too many requests, please try again later")
+			}
+		}
+
+		return ctx.Next()
+	}
+}
+
+// memoryPruneInterval is how often refilled buckets are dropped
+const memoryPruneInterval = time.Minute
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// refilledAt is when the bucket is full again and can be dropped
+	refilledAt time.Time
+}
+
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewMemoryRateLimitStore keeps buckets in process memory so
+// every instance of the service enforces limits on its own,
+// refilled buckets are dropped in background every memoryPruneInterval.
+func NewMemoryRateLimitStore() RateLimitStore {
+	store := &memoryRateLimitStore{
+		buckets: map[string]*tokenBucket{},
+	}
+
+	go func() {
+		for now := range time.Tick(memoryPruneInterval) {
+			store.prune(now)
+		}
+	}()
+
+	return store
+}
+
+func (s *memoryRateLimitStore) Take(key string, limit *RateLimit, now time.Time) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refillRate := float64(limit.Requests) / limit.Period.Seconds()
+	bucket, found := s.buckets[key]
+	if !found {
+		bucket = &tokenBucket{
+			tokens:    float64(limit.Burst),
+			updatedAt: now,
+		}
+		s.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+elapsed*refillRate)
+	bucket.updatedAt = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / refillRate * float64(time.Second))
+	}
+
+	bucket.tokens--
+	bucket.refilledAt = now.Add(time.Duration((float64(limit.Burst) - bucket.tokens) / refillRate * float64(time.Second)))
+	return true, 0
+}
+
+// prune drops buckets which would have been refilled by now
+func (s *memoryRateLimitStore) prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, bucket := range s.buckets {
+		if !now.Before(bucket.refilledAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sultaniman/confetti/platform/http"
+)
+
+// RequireScope allows only tokens granted all of given scopes, it must be mounted
+// after AuthMiddleware which sets scopes of both access and personal access tokens.
+func RequireScope(scopes ...string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		granted, found := ctx.Locals("scopes").([]string)
+		if !found {
+			return http.ForbiddenError("Please authenticate")
+		}
+
+		for _, scope := range scopes {
+			if !containsScope(granted, scope) {
+				return http.ForbiddenError("Token is missing scope " + scope)
+			}
+		}
+
+		return ctx.Next()
+	}
+}
+
+func containsScope(granted []string, scope string) bool {
+	for _, s := range granted {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
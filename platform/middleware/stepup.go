@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sultaniman/confetti/platform/http"
+	"time"
+)
+
+// RecentAuthMiddleware allows only tokens of users who authenticated within maxAge,
+// it must be mounted after AuthMiddleware. Fresh token is obtained either by
+// logging in again or by re-entering password or TOTP at /auth/token/elevate.
+// Personal access tokens carry no authentication time so they are always rejected.
+func RecentAuthMiddleware(maxAge time.Duration) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		authTime, found := ctx.Locals("auth_time").(time.Time)
+		if !found || time.Since(authTime) > maxAge {
+			return http.ReauthenticationRequiredError()
+		}
+
+		return ctx.Next()
+	}
+}
@@ -0,0 +1,109 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: attempts.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	entities "github.com/sultaniman/confetti/platform/entities"
+)
+
+// MockLoginAttemptRepo is a mock of LoginAttemptRepo interface.
+type MockLoginAttemptRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginAttemptRepoMockRecorder
+}
+
+// MockLoginAttemptRepoMockRecorder is the mock recorder for MockLoginAttemptRepo.
+type MockLoginAttemptRepoMockRecorder struct {
+	mock *MockLoginAttemptRepo
+}
+
+// NewMockLoginAttemptRepo creates a new mock instance.
+func NewMockLoginAttemptRepo(ctrl *gomock.Controller) *MockLoginAttemptRepo {
+	mock := &MockLoginAttemptRepo{ctrl: ctrl}
+	mock.recorder = &MockLoginAttemptRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginAttemptRepo) EXPECT() *MockLoginAttemptRepoMockRecorder {
+	return m.recorder
+}
+
+// DeleteStale mocks base method.
+func (m *MockLoginAttemptRepo) DeleteStale(now time.Time, window time.Duration) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteStale", now, window)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteStale indicates an expected call of DeleteStale.
+func (mr *MockLoginAttemptRepoMockRecorder) DeleteStale(now, window interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteStale", reflect.TypeOf((*MockLoginAttemptRepo)(nil).DeleteStale), now, window)
+}
+
+// Get mocks base method.
+func (m *MockLoginAttemptRepo) Get(key string) (*entities.LoginAttempts, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", key)
+	ret0, _ := ret[0].(*entities.LoginAttempts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockLoginAttemptRepoMockRecorder) Get(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockLoginAttemptRepo)(nil).Get), key)
+}
+
+// Lock mocks base method.
+func (m *MockLoginAttemptRepo) Lock(key string, until time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lock", key, until)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Lock indicates an expected call of Lock.
+func (mr *MockLoginAttemptRepoMockRecorder) Lock(key, until interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lock", reflect.TypeOf((*MockLoginAttemptRepo)(nil).Lock), key, until)
+}
+
+// RegisterFailure mocks base method.
+func (m *MockLoginAttemptRepo) RegisterFailure(key string, now time.Time, window time.Duration) (*entities.LoginAttempts, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterFailure", key, now, window)
+	ret0, _ := ret[0].(*entities.LoginAttempts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegisterFailure indicates an expected call of RegisterFailure.
+func (mr *MockLoginAttemptRepoMockRecorder) RegisterFailure(key, now, window interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterFailure", reflect.TypeOf((*MockLoginAttemptRepo)(nil).RegisterFailure), key, now, window)
+}
+
+// Reset mocks base method.
+func (m *MockLoginAttemptRepo) Reset(key string, auditRecord *entities.NewAdminAuditRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", key, auditRecord)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockLoginAttemptRepoMockRecorder) Reset(key, auditRecord interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockLoginAttemptRepo)(nil).Reset), key, auditRecord)
+}
@@ -0,0 +1,119 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: audit.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	entities "github.com/sultaniman/confetti/platform/entities"
+	repo "github.com/sultaniman/confetti/platform/repo"
+)
+
+// MockAuditRepo is a mock of AuditRepo interface.
+type MockAuditRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditRepoMockRecorder
+}
+
+// MockAuditRepoMockRecorder is the mock recorder for MockAuditRepo.
+type MockAuditRepoMockRecorder struct {
+	mock *MockAuditRepo
+}
+
+// NewMockAuditRepo creates a new mock instance.
+func NewMockAuditRepo(ctrl *gomock.Controller) *MockAuditRepo {
+	mock := &MockAuditRepo{ctrl: ctrl}
+	mock.recorder = &MockAuditRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditRepo) EXPECT() *MockAuditRepoMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockAuditRepo) Create(record *entities.NewCardAuditRecord) (*entities.CardAuditRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", record)
+	ret0, _ := ret[0].(*entities.CardAuditRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockAuditRepoMockRecorder) Create(record interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAuditRepo)(nil).Create), record)
+}
+
+// List mocks base method.
+func (m *MockAuditRepo) List(filterSpec *repo.AuditFilterSpec) ([]entities.CardAuditRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", filterSpec)
+	ret0, _ := ret[0].([]entities.CardAuditRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAuditRepoMockRecorder) List(filterSpec interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAuditRepo)(nil).List), filterSpec)
+}
+
+// MockAdminAuditRepo is a mock of AdminAuditRepo interface.
+type MockAdminAuditRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdminAuditRepoMockRecorder
+}
+
+// MockAdminAuditRepoMockRecorder is the mock recorder for MockAdminAuditRepo.
+type MockAdminAuditRepoMockRecorder struct {
+	mock *MockAdminAuditRepo
+}
+
+// NewMockAdminAuditRepo creates a new mock instance.
+func NewMockAdminAuditRepo(ctrl *gomock.Controller) *MockAdminAuditRepo {
+	mock := &MockAdminAuditRepo{ctrl: ctrl}
+	mock.recorder = &MockAdminAuditRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdminAuditRepo) EXPECT() *MockAdminAuditRepoMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockAdminAuditRepo) Create(record *entities.NewAdminAuditRecord) (*entities.AdminAuditRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", record)
+	ret0, _ := ret[0].(*entities.AdminAuditRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockAdminAuditRepoMockRecorder) Create(record interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAdminAuditRepo)(nil).Create), record)
+}
+
+// List mocks base method.
+func (m *MockAdminAuditRepo) List(filterSpec *repo.AdminAuditFilterSpec) ([]entities.AdminAuditRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", filterSpec)
+	ret0, _ := ret[0].([]entities.AdminAuditRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAdminAuditRepoMockRecorder) List(filterSpec interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAdminAuditRepo)(nil).List), filterSpec)
+}
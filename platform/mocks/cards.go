@@ -50,33 +50,48 @@ func (mr *MockCardRepoMockRecorder) ClaimExists(cardId, userId interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimExists", reflect.TypeOf((*MockCardRepo)(nil).ClaimExists), cardId, userId)
 }
 
+// Count mocks base method.
+func (m *MockCardRepo) Count(filterSpec *repo.FilterSpec) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", filterSpec)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockCardRepoMockRecorder) Count(filterSpec interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockCardRepo)(nil).Count), filterSpec)
+}
+
 // Create mocks base method.
-func (m *MockCardRepo) Create(card *entities.NewCard) (*entities.Card, error) {
+func (m *MockCardRepo) Create(card *entities.NewCard, auditRecord *entities.NewCardAuditRecord) (*entities.Card, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", card)
+	ret := m.ctrl.Call(m, "Create", card, auditRecord)
 	ret0, _ := ret[0].(*entities.Card)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockCardRepoMockRecorder) Create(card interface{}) *gomock.Call {
+func (mr *MockCardRepoMockRecorder) Create(card, auditRecord interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockCardRepo)(nil).Create), card)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockCardRepo)(nil).Create), card, auditRecord)
 }
 
 // Delete mocks base method.
-func (m *MockCardRepo) Delete(id uuid.UUID) error {
+func (m *MockCardRepo) Delete(id uuid.UUID, auditRecord *entities.NewCardAuditRecord) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", id)
+	ret := m.ctrl.Call(m, "Delete", id, auditRecord)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockCardRepoMockRecorder) Delete(id interface{}) *gomock.Call {
+func (mr *MockCardRepoMockRecorder) Delete(id, auditRecord interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockCardRepo)(nil).Delete), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockCardRepo)(nil).Delete), id, auditRecord)
 }
 
 // Get mocks base method.
@@ -94,6 +109,21 @@ func (mr *MockCardRepoMockRecorder) Get(id interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockCardRepo)(nil).Get), id)
 }
 
+// KeyUsage mocks base method.
+func (m *MockCardRepo) KeyUsage() ([]entities.KeyUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "KeyUsage")
+	ret0, _ := ret[0].([]entities.KeyUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// KeyUsage indicates an expected call of KeyUsage.
+func (mr *MockCardRepoMockRecorder) KeyUsage() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KeyUsage", reflect.TypeOf((*MockCardRepo)(nil).KeyUsage))
+}
+
 // List mocks base method.
 func (m *MockCardRepo) List(filterSpec *repo.FilterSpec) ([]entities.Card, error) {
 	m.ctrl.T.Helper()
@@ -109,17 +139,32 @@ func (mr *MockCardRepoMockRecorder) List(filterSpec interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockCardRepo)(nil).List), filterSpec)
 }
 
+// RewrapKeys mocks base method.
+func (m *MockCardRepo) RewrapKeys(fromKeyID string, batchSize uint64, rewrap repo.RewrapFunc) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RewrapKeys", fromKeyID, batchSize, rewrap)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RewrapKeys indicates an expected call of RewrapKeys.
+func (mr *MockCardRepoMockRecorder) RewrapKeys(fromKeyID, batchSize, rewrap interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RewrapKeys", reflect.TypeOf((*MockCardRepo)(nil).RewrapKeys), fromKeyID, batchSize, rewrap)
+}
+
 // Update mocks base method.
-func (m *MockCardRepo) Update(cardId uuid.UUID, newTitle string) (*entities.Card, error) {
+func (m *MockCardRepo) Update(cardId uuid.UUID, newTitle string, auditRecord *entities.NewCardAuditRecord) (*entities.Card, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", cardId, newTitle)
+	ret := m.ctrl.Call(m, "Update", cardId, newTitle, auditRecord)
 	ret0, _ := ret[0].(*entities.Card)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Update indicates an expected call of Update.
-func (mr *MockCardRepoMockRecorder) Update(cardId, newTitle interface{}) *gomock.Call {
+func (mr *MockCardRepoMockRecorder) Update(cardId, newTitle, auditRecord interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockCardRepo)(nil).Update), cardId, newTitle)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockCardRepo)(nil).Update), cardId, newTitle, auditRecord)
 }
@@ -0,0 +1,138 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: mfa.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
+	entities "github.com/sultaniman/confetti/platform/entities"
+)
+
+// MockMFARepo is a mock of MFARepo interface.
+type MockMFARepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockMFARepoMockRecorder
+}
+
+// MockMFARepoMockRecorder is the mock recorder for MockMFARepo.
+type MockMFARepoMockRecorder struct {
+	mock *MockMFARepo
+}
+
+// NewMockMFARepo creates a new mock instance.
+func NewMockMFARepo(ctrl *gomock.Controller) *MockMFARepo {
+	mock := &MockMFARepo{ctrl: ctrl}
+	mock.recorder = &MockMFARepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMFARepo) EXPECT() *MockMFARepoMockRecorder {
+	return m.recorder
+}
+
+// ConfirmTOTP mocks base method.
+func (m *MockMFARepo) ConfirmTOTP(userId uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTP", userId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmTOTP indicates an expected call of ConfirmTOTP.
+func (mr *MockMFARepoMockRecorder) ConfirmTOTP(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTP", reflect.TypeOf((*MockMFARepo)(nil).ConfirmTOTP), userId)
+}
+
+// DeleteTOTP mocks base method.
+func (m *MockMFARepo) DeleteTOTP(userId uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTOTP", userId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTOTP indicates an expected call of DeleteTOTP.
+func (mr *MockMFARepoMockRecorder) DeleteTOTP(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTOTP", reflect.TypeOf((*MockMFARepo)(nil).DeleteTOTP), userId)
+}
+
+// GetTOTP mocks base method.
+func (m *MockMFARepo) GetTOTP(userId uuid.UUID) (*entities.TOTP, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTOTP", userId)
+	ret0, _ := ret[0].(*entities.TOTP)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTOTP indicates an expected call of GetTOTP.
+func (mr *MockMFARepoMockRecorder) GetTOTP(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTOTP", reflect.TypeOf((*MockMFARepo)(nil).GetTOTP), userId)
+}
+
+// ReplaceRecoveryCodes mocks base method.
+func (m *MockMFARepo) ReplaceRecoveryCodes(userId uuid.UUID, codeHashes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceRecoveryCodes", userId, codeHashes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceRecoveryCodes indicates an expected call of ReplaceRecoveryCodes.
+func (mr *MockMFARepoMockRecorder) ReplaceRecoveryCodes(userId, codeHashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceRecoveryCodes", reflect.TypeOf((*MockMFARepo)(nil).ReplaceRecoveryCodes), userId, codeHashes)
+}
+
+// SaveTOTP mocks base method.
+func (m *MockMFARepo) SaveTOTP(totp *entities.NewTOTP) (*entities.TOTP, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveTOTP", totp)
+	ret0, _ := ret[0].(*entities.TOTP)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveTOTP indicates an expected call of SaveTOTP.
+func (mr *MockMFARepoMockRecorder) SaveTOTP(totp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTOTP", reflect.TypeOf((*MockMFARepo)(nil).SaveTOTP), totp)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockMFARepo) UseRecoveryCode(userId uuid.UUID, codeHash string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", userId, codeHash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockMFARepoMockRecorder) UseRecoveryCode(userId, codeHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockMFARepo)(nil).UseRecoveryCode), userId, codeHash)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockMFARepo) UseTOTPStep(userId uuid.UUID, step int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", userId, step)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockMFARepoMockRecorder) UseTOTPStep(userId, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockMFARepo)(nil).UseTOTPStep), userId, step)
+}
@@ -0,0 +1,109 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: oidc.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
+	entities "github.com/sultaniman/confetti/platform/entities"
+)
+
+// MockOIDCRepo is a mock of OIDCRepo interface.
+type MockOIDCRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockOIDCRepoMockRecorder
+}
+
+// MockOIDCRepoMockRecorder is the mock recorder for MockOIDCRepo.
+type MockOIDCRepoMockRecorder struct {
+	mock *MockOIDCRepo
+}
+
+// NewMockOIDCRepo creates a new mock instance.
+func NewMockOIDCRepo(ctrl *gomock.Controller) *MockOIDCRepo {
+	mock := &MockOIDCRepo{ctrl: ctrl}
+	mock.recorder = &MockOIDCRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOIDCRepo) EXPECT() *MockOIDCRepoMockRecorder {
+	return m.recorder
+}
+
+// ConsumeState mocks base method.
+func (m *MockOIDCRepo) ConsumeState(state, provider string) (*entities.OIDCState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeState", state, provider)
+	ret0, _ := ret[0].(*entities.OIDCState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeState indicates an expected call of ConsumeState.
+func (mr *MockOIDCRepoMockRecorder) ConsumeState(state, provider interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeState", reflect.TypeOf((*MockOIDCRepo)(nil).ConsumeState), state, provider)
+}
+
+// CreateIdentity mocks base method.
+func (m *MockOIDCRepo) CreateIdentity(identity *entities.NewUserIdentity) (*entities.UserIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIdentity", identity)
+	ret0, _ := ret[0].(*entities.UserIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateIdentity indicates an expected call of CreateIdentity.
+func (mr *MockOIDCRepoMockRecorder) CreateIdentity(identity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIdentity", reflect.TypeOf((*MockOIDCRepo)(nil).CreateIdentity), identity)
+}
+
+// CreateState mocks base method.
+func (m *MockOIDCRepo) CreateState(state *entities.NewOIDCState) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateState", state)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateState indicates an expected call of CreateState.
+func (mr *MockOIDCRepoMockRecorder) CreateState(state interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateState", reflect.TypeOf((*MockOIDCRepo)(nil).CreateState), state)
+}
+
+// GetIdentity mocks base method.
+func (m *MockOIDCRepo) GetIdentity(provider, subject string) (*entities.UserIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdentity", provider, subject)
+	ret0, _ := ret[0].(*entities.UserIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdentity indicates an expected call of GetIdentity.
+func (mr *MockOIDCRepoMockRecorder) GetIdentity(provider, subject interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdentity", reflect.TypeOf((*MockOIDCRepo)(nil).GetIdentity), provider, subject)
+}
+
+// TouchIdentity mocks base method.
+func (m *MockOIDCRepo) TouchIdentity(id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchIdentity", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchIdentity indicates an expected call of TouchIdentity.
+func (mr *MockOIDCRepoMockRecorder) TouchIdentity(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchIdentity", reflect.TypeOf((*MockOIDCRepo)(nil).TouchIdentity), id)
+}
@@ -0,0 +1,111 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: personal_tokens.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
+	entities "github.com/sultaniman/confetti/platform/entities"
+)
+
+// MockPersonalTokenRepo is a mock of PersonalTokenRepo interface.
+type MockPersonalTokenRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockPersonalTokenRepoMockRecorder
+}
+
+// MockPersonalTokenRepoMockRecorder is the mock recorder for MockPersonalTokenRepo.
+type MockPersonalTokenRepoMockRecorder struct {
+	mock *MockPersonalTokenRepo
+}
+
+// NewMockPersonalTokenRepo creates a new mock instance.
+func NewMockPersonalTokenRepo(ctrl *gomock.Controller) *MockPersonalTokenRepo {
+	mock := &MockPersonalTokenRepo{ctrl: ctrl}
+	mock.recorder = &MockPersonalTokenRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPersonalTokenRepo) EXPECT() *MockPersonalTokenRepoMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockPersonalTokenRepo) Create(token *entities.NewPersonalToken) (*entities.PersonalToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", token)
+	ret0, _ := ret[0].(*entities.PersonalToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockPersonalTokenRepoMockRecorder) Create(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockPersonalTokenRepo)(nil).Create), token)
+}
+
+// Delete mocks base method.
+func (m *MockPersonalTokenRepo) Delete(userId, id uuid.UUID) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", userId, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockPersonalTokenRepoMockRecorder) Delete(userId, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockPersonalTokenRepo)(nil).Delete), userId, id)
+}
+
+// GetByHash mocks base method.
+func (m *MockPersonalTokenRepo) GetByHash(tokenHash string) (*entities.PersonalToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByHash", tokenHash)
+	ret0, _ := ret[0].(*entities.PersonalToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByHash indicates an expected call of GetByHash.
+func (mr *MockPersonalTokenRepoMockRecorder) GetByHash(tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByHash", reflect.TypeOf((*MockPersonalTokenRepo)(nil).GetByHash), tokenHash)
+}
+
+// List mocks base method.
+func (m *MockPersonalTokenRepo) List(userId uuid.UUID) ([]entities.PersonalToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", userId)
+	ret0, _ := ret[0].([]entities.PersonalToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockPersonalTokenRepoMockRecorder) List(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockPersonalTokenRepo)(nil).List), userId)
+}
+
+// Touch mocks base method.
+func (m *MockPersonalTokenRepo) Touch(id uuid.UUID, usedAt time.Time, interval time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Touch", id, usedAt, interval)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Touch indicates an expected call of Touch.
+func (mr *MockPersonalTokenRepoMockRecorder) Touch(id, usedAt, interval interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Touch", reflect.TypeOf((*MockPersonalTokenRepo)(nil).Touch), id, usedAt, interval)
+}
@@ -0,0 +1,153 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: tokens.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
+	entities "github.com/sultaniman/confetti/platform/entities"
+)
+
+// MockTokenRepo is a mock of TokenRepo interface.
+type MockTokenRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenRepoMockRecorder
+}
+
+// MockTokenRepoMockRecorder is the mock recorder for MockTokenRepo.
+type MockTokenRepoMockRecorder struct {
+	mock *MockTokenRepo
+}
+
+// NewMockTokenRepo creates a new mock instance.
+func NewMockTokenRepo(ctrl *gomock.Controller) *MockTokenRepo {
+	mock := &MockTokenRepo{ctrl: ctrl}
+	mock.recorder = &MockTokenRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTokenRepo) EXPECT() *MockTokenRepoMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockTokenRepo) Create(token *entities.NewRefreshToken) (*entities.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", token)
+	ret0, _ := ret[0].(*entities.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockTokenRepoMockRecorder) Create(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockTokenRepo)(nil).Create), token)
+}
+
+// Get mocks base method.
+func (m *MockTokenRepo) Get(jti uuid.UUID) (*entities.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", jti)
+	ret0, _ := ret[0].(*entities.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockTokenRepoMockRecorder) Get(jti interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockTokenRepo)(nil).Get), jti)
+}
+
+// ListSessions mocks base method.
+func (m *MockTokenRepo) ListSessions(userId uuid.UUID) ([]entities.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSessions", userId)
+	ret0, _ := ret[0].([]entities.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSessions indicates an expected call of ListSessions.
+func (mr *MockTokenRepoMockRecorder) ListSessions(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSessions", reflect.TypeOf((*MockTokenRepo)(nil).ListSessions), userId)
+}
+
+// Revoke mocks base method.
+func (m *MockTokenRepo) Revoke(jti uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", jti)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockTokenRepoMockRecorder) Revoke(jti interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockTokenRepo)(nil).Revoke), jti)
+}
+
+// RevokeAll mocks base method.
+func (m *MockTokenRepo) RevokeAll(userId uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAll", userId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAll indicates an expected call of RevokeAll.
+func (mr *MockTokenRepoMockRecorder) RevokeAll(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAll", reflect.TypeOf((*MockTokenRepo)(nil).RevokeAll), userId)
+}
+
+// RevokeFamily mocks base method.
+func (m *MockTokenRepo) RevokeFamily(familyId uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeFamily", familyId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeFamily indicates an expected call of RevokeFamily.
+func (mr *MockTokenRepoMockRecorder) RevokeFamily(familyId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeFamily", reflect.TypeOf((*MockTokenRepo)(nil).RevokeFamily), familyId)
+}
+
+// RevokeSession mocks base method.
+func (m *MockTokenRepo) RevokeSession(userId, familyId uuid.UUID) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", userId, familyId)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockTokenRepoMockRecorder) RevokeSession(userId, familyId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockTokenRepo)(nil).RevokeSession), userId, familyId)
+}
+
+// Rotate mocks base method.
+func (m *MockTokenRepo) Rotate(jti uuid.UUID, token *entities.NewRefreshToken) (*entities.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rotate", jti, token)
+	ret0, _ := ret[0].(*entities.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rotate indicates an expected call of Rotate.
+func (mr *MockTokenRepoMockRecorder) Rotate(jti, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rotate", reflect.TypeOf((*MockTokenRepo)(nil).Rotate), jti, token)
+}
@@ -6,10 +6,12 @@ package mocks
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	uuid "github.com/google/uuid"
 	entities "github.com/sultaniman/confetti/platform/entities"
+	repo "github.com/sultaniman/confetti/platform/repo"
 )
 
 // MockUserRepo is a mock of UserRepo interface.
@@ -35,19 +37,94 @@ func (m *MockUserRepo) EXPECT() *MockUserRepoMockRecorder {
 	return m.recorder
 }
 
+// AnonymizeDeletedAudit mocks base method.
+func (m *MockUserRepo) AnonymizeDeletedAudit() (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnonymizeDeletedAudit")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AnonymizeDeletedAudit indicates an expected call of AnonymizeDeletedAudit.
+func (mr *MockUserRepoMockRecorder) AnonymizeDeletedAudit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizeDeletedAudit", reflect.TypeOf((*MockUserRepo)(nil).AnonymizeDeletedAudit))
+}
+
+// CancelDeletion mocks base method.
+func (m *MockUserRepo) CancelDeletion(userId uuid.UUID) (*entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelDeletion", userId)
+	ret0, _ := ret[0].(*entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelDeletion indicates an expected call of CancelDeletion.
+func (mr *MockUserRepoMockRecorder) CancelDeletion(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelDeletion", reflect.TypeOf((*MockUserRepo)(nil).CancelDeletion), userId)
+}
+
+// CommitEmailChange mocks base method.
+func (m *MockUserRepo) CommitEmailChange(userId uuid.UUID, newEmail string) (*entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CommitEmailChange", userId, newEmail)
+	ret0, _ := ret[0].(*entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CommitEmailChange indicates an expected call of CommitEmailChange.
+func (mr *MockUserRepoMockRecorder) CommitEmailChange(userId, newEmail interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommitEmailChange", reflect.TypeOf((*MockUserRepo)(nil).CommitEmailChange), userId, newEmail)
+}
+
 // ConfirmUser mocks base method.
-func (m *MockUserRepo) ConfirmUser(userId uuid.UUID) (*entities.User, error) {
+func (m *MockUserRepo) ConfirmUser(userId uuid.UUID, auditRecord *entities.NewAdminAuditRecord) (*entities.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ConfirmUser", userId)
+	ret := m.ctrl.Call(m, "ConfirmUser", userId, auditRecord)
 	ret0, _ := ret[0].(*entities.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ConfirmUser indicates an expected call of ConfirmUser.
-func (mr *MockUserRepoMockRecorder) ConfirmUser(userId interface{}) *gomock.Call {
+func (mr *MockUserRepoMockRecorder) ConfirmUser(userId, auditRecord interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmUser", reflect.TypeOf((*MockUserRepo)(nil).ConfirmUser), userId, auditRecord)
+}
+
+// ConsumeActionCode mocks base method.
+func (m *MockUserRepo) ConsumeActionCode(actionCodeCheck *entities.ActionCodeCheck) (*entities.ActionCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeActionCode", actionCodeCheck)
+	ret0, _ := ret[0].(*entities.ActionCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeActionCode indicates an expected call of ConsumeActionCode.
+func (mr *MockUserRepoMockRecorder) ConsumeActionCode(actionCodeCheck interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmUser", reflect.TypeOf((*MockUserRepo)(nil).ConfirmUser), userId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeActionCode", reflect.TypeOf((*MockUserRepo)(nil).ConsumeActionCode), actionCodeCheck)
+}
+
+// Count mocks base method.
+func (m *MockUserRepo) Count(filterSpec *repo.UserFilterSpec) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", filterSpec)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockUserRepoMockRecorder) Count(filterSpec interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockUserRepo)(nil).Count), filterSpec)
 }
 
 // Create mocks base method.
@@ -81,18 +158,33 @@ func (mr *MockUserRepoMockRecorder) CreateActionCode(actionCodeRequest interface
 }
 
 // Delete mocks base method.
-func (m *MockUserRepo) Delete(id uuid.UUID) (*entities.User, error) {
+func (m *MockUserRepo) Delete(id uuid.UUID, auditRecord *entities.NewAdminAuditRecord) (*entities.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", id)
+	ret := m.ctrl.Call(m, "Delete", id, auditRecord)
 	ret0, _ := ret[0].(*entities.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockUserRepoMockRecorder) Delete(id interface{}) *gomock.Call {
+func (mr *MockUserRepoMockRecorder) Delete(id, auditRecord interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserRepo)(nil).Delete), id, auditRecord)
+}
+
+// DeleteExpiredActionCodes mocks base method.
+func (m *MockUserRepo) DeleteExpiredActionCodes(now time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredActionCodes", now)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredActionCodes indicates an expected call of DeleteExpiredActionCodes.
+func (mr *MockUserRepoMockRecorder) DeleteExpiredActionCodes(now interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserRepo)(nil).Delete), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredActionCodes", reflect.TypeOf((*MockUserRepo)(nil).DeleteExpiredActionCodes), now)
 }
 
 // EmailExists mocks base method.
@@ -168,6 +260,81 @@ func (mr *MockUserRepoMockRecorder) GetByEmail(email interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByEmail", reflect.TypeOf((*MockUserRepo)(nil).GetByEmail), email)
 }
 
+// List mocks base method.
+func (m *MockUserRepo) List(filterSpec *repo.UserFilterSpec) ([]entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", filterSpec)
+	ret0, _ := ret[0].([]entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockUserRepoMockRecorder) List(filterSpec interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockUserRepo)(nil).List), filterSpec)
+}
+
+// PurgeDeletionDue mocks base method.
+func (m *MockUserRepo) PurgeDeletionDue(requestedBefore time.Time, limit uint64) ([]entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletionDue", requestedBefore, limit)
+	ret0, _ := ret[0].([]entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletionDue indicates an expected call of PurgeDeletionDue.
+func (mr *MockUserRepoMockRecorder) PurgeDeletionDue(requestedBefore, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletionDue", reflect.TypeOf((*MockUserRepo)(nil).PurgeDeletionDue), requestedBefore, limit)
+}
+
+// ScheduleDeletion mocks base method.
+func (m *MockUserRepo) ScheduleDeletion(userId uuid.UUID, requestedAt time.Time) (*entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleDeletion", userId, requestedAt)
+	ret0, _ := ret[0].(*entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ScheduleDeletion indicates an expected call of ScheduleDeletion.
+func (mr *MockUserRepoMockRecorder) ScheduleDeletion(userId, requestedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleDeletion", reflect.TypeOf((*MockUserRepo)(nil).ScheduleDeletion), userId, requestedAt)
+}
+
+// SetActive mocks base method.
+func (m *MockUserRepo) SetActive(userId uuid.UUID, isActive bool, auditRecord *entities.NewAdminAuditRecord) (*entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetActive", userId, isActive, auditRecord)
+	ret0, _ := ret[0].(*entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetActive indicates an expected call of SetActive.
+func (mr *MockUserRepoMockRecorder) SetActive(userId, isActive, auditRecord interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetActive", reflect.TypeOf((*MockUserRepo)(nil).SetActive), userId, isActive, auditRecord)
+}
+
+// SetAdmin mocks base method.
+func (m *MockUserRepo) SetAdmin(userId uuid.UUID, isAdmin bool, auditRecord *entities.NewAdminAuditRecord) (*entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAdmin", userId, isAdmin, auditRecord)
+	ret0, _ := ret[0].(*entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetAdmin indicates an expected call of SetAdmin.
+func (mr *MockUserRepoMockRecorder) SetAdmin(userId, isAdmin, auditRecord interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAdmin", reflect.TypeOf((*MockUserRepo)(nil).SetAdmin), userId, isAdmin, auditRecord)
+}
+
 // Update mocks base method.
 func (m *MockUserRepo) Update(userId uuid.UUID, user *entities.UpdateUser) (*entities.User, error) {
 	m.ctrl.T.Helper()
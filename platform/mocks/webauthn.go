@@ -0,0 +1,153 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webauthn.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
+	entities "github.com/sultaniman/confetti/platform/entities"
+)
+
+// MockWebAuthnRepo is a mock of WebAuthnRepo interface.
+type MockWebAuthnRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebAuthnRepoMockRecorder
+}
+
+// MockWebAuthnRepoMockRecorder is the mock recorder for MockWebAuthnRepo.
+type MockWebAuthnRepoMockRecorder struct {
+	mock *MockWebAuthnRepo
+}
+
+// NewMockWebAuthnRepo creates a new mock instance.
+func NewMockWebAuthnRepo(ctrl *gomock.Controller) *MockWebAuthnRepo {
+	mock := &MockWebAuthnRepo{ctrl: ctrl}
+	mock.recorder = &MockWebAuthnRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebAuthnRepo) EXPECT() *MockWebAuthnRepoMockRecorder {
+	return m.recorder
+}
+
+// ConsumeChallenge mocks base method.
+func (m *MockWebAuthnRepo) ConsumeChallenge(challenge string, ceremony entities.WebAuthnCeremony) (*entities.WebAuthnChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeChallenge", challenge, ceremony)
+	ret0, _ := ret[0].(*entities.WebAuthnChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeChallenge indicates an expected call of ConsumeChallenge.
+func (mr *MockWebAuthnRepoMockRecorder) ConsumeChallenge(challenge, ceremony interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeChallenge", reflect.TypeOf((*MockWebAuthnRepo)(nil).ConsumeChallenge), challenge, ceremony)
+}
+
+// CreateChallenge mocks base method.
+func (m *MockWebAuthnRepo) CreateChallenge(challenge *entities.NewWebAuthnChallenge) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateChallenge", challenge)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateChallenge indicates an expected call of CreateChallenge.
+func (mr *MockWebAuthnRepoMockRecorder) CreateChallenge(challenge interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateChallenge", reflect.TypeOf((*MockWebAuthnRepo)(nil).CreateChallenge), challenge)
+}
+
+// CreateCredential mocks base method.
+func (m *MockWebAuthnRepo) CreateCredential(credential *entities.NewWebAuthnCredential) (*entities.WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCredential", credential)
+	ret0, _ := ret[0].(*entities.WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCredential indicates an expected call of CreateCredential.
+func (mr *MockWebAuthnRepoMockRecorder) CreateCredential(credential interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCredential", reflect.TypeOf((*MockWebAuthnRepo)(nil).CreateCredential), credential)
+}
+
+// DeleteCredential mocks base method.
+func (m *MockWebAuthnRepo) DeleteCredential(userId, id uuid.UUID) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCredential", userId, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteCredential indicates an expected call of DeleteCredential.
+func (mr *MockWebAuthnRepoMockRecorder) DeleteCredential(userId, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCredential", reflect.TypeOf((*MockWebAuthnRepo)(nil).DeleteCredential), userId, id)
+}
+
+// GetCredential mocks base method.
+func (m *MockWebAuthnRepo) GetCredential(credentialId string) (*entities.WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCredential", credentialId)
+	ret0, _ := ret[0].(*entities.WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCredential indicates an expected call of GetCredential.
+func (mr *MockWebAuthnRepoMockRecorder) GetCredential(credentialId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCredential", reflect.TypeOf((*MockWebAuthnRepo)(nil).GetCredential), credentialId)
+}
+
+// HasCredentials mocks base method.
+func (m *MockWebAuthnRepo) HasCredentials(userId uuid.UUID) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasCredentials", userId)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// HasCredentials indicates an expected call of HasCredentials.
+func (mr *MockWebAuthnRepoMockRecorder) HasCredentials(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasCredentials", reflect.TypeOf((*MockWebAuthnRepo)(nil).HasCredentials), userId)
+}
+
+// ListCredentials mocks base method.
+func (m *MockWebAuthnRepo) ListCredentials(userId uuid.UUID) ([]entities.WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCredentials", userId)
+	ret0, _ := ret[0].([]entities.WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCredentials indicates an expected call of ListCredentials.
+func (mr *MockWebAuthnRepoMockRecorder) ListCredentials(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCredentials", reflect.TypeOf((*MockWebAuthnRepo)(nil).ListCredentials), userId)
+}
+
+// UpdateSignCount mocks base method.
+func (m *MockWebAuthnRepo) UpdateSignCount(id uuid.UUID, signCount uint32) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSignCount", id, signCount)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSignCount indicates an expected call of UpdateSignCount.
+func (mr *MockWebAuthnRepoMockRecorder) UpdateSignCount(id, signCount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSignCount", reflect.TypeOf((*MockWebAuthnRepo)(nil).UpdateSignCount), id, signCount)
+}
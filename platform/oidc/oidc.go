@@ -0,0 +1,281 @@
+// Package oidc implements relying party side of OpenID Connect authorization code flow
+// with PKCE, only confidential clients using client_secret_post are supported.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DiscoveryPath = "/.well-known/openid-configuration"
+	DefaultScopes = "openid email profile"
+
+	// ClockSkew is tolerated when validating id token timestamps
+	ClockSkew = time.Minute
+
+	randomSize = 32
+)
+
+var (
+	ErrDiscovery      = errors.New("unable to discover provider configuration")
+	ErrIssuerMismatch = errors.New("issuer does not match provider configuration")
+	ErrExchange       = errors.New("unable to exchange authorization code")
+	ErrMissingIDToken = errors.New("token response does not contain id token")
+	ErrInvalidIDToken = errors.New("invalid id token")
+	ErrInvalidNonce   = errors.New("id token nonce does not match")
+)
+
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Discovery is a subset of provider metadata which is needed for code flow
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// Claims are id token claims used to find or create local user
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider fetches discovery document on first use so that
+// unavailable provider does not prevent server from starting.
+type Provider struct {
+	Config     Config
+	HTTPClient *http.Client
+
+	mu        sync.Mutex
+	discovery *Discovery
+}
+
+func NewProvider(config Config, httpClient *http.Client) *Provider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = strings.Fields(DefaultScopes)
+	}
+
+	return &Provider{
+		Config:     config,
+		HTTPClient: httpClient,
+	}
+}
+
+func (p *Provider) Discover(ctx context.Context) (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	discoveryURL := strings.TrimRight(p.Config.Issuer, "/") + DiscoveryPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status %d", ErrDiscovery, resp.StatusCode)
+	}
+
+	discovery := new(Discovery)
+	if err = json.NewDecoder(resp.Body).Decode(discovery); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+
+	if strings.TrimRight(discovery.Issuer, "/") != strings.TrimRight(p.Config.Issuer, "/") {
+		return nil, ErrIssuerMismatch
+	}
+
+	p.discovery = discovery
+	return discovery, nil
+}
+
+// AuthCodeURL returns authorization endpoint url with S256 code challenge
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, nonce string, codeVerifier string) (string, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.Config.ClientID)
+	query.Set("redirect_uri", p.Config.RedirectURL)
+	query.Set("scope", strings.Join(p.Config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	return authURL.String(), nil
+}
+
+// Exchange redeems authorization code at token endpoint
+func (p *Provider) Exchange(ctx context.Context, code string, codeVerifier string) (*TokenResponse, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.Config.RedirectURL)
+	form.Set("client_id", p.Config.ClientID)
+	form.Set("client_secret", p.Config.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status %d", ErrExchange, resp.StatusCode)
+	}
+
+	tokenResponse := new(TokenResponse)
+	if err = json.Unmarshal(body, tokenResponse); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+
+	if tokenResponse.IDToken == "" {
+		return nil, ErrMissingIDToken
+	}
+
+	return tokenResponse, nil
+}
+
+// VerifyIDToken checks signature against provider keys, issuer,
+// audience, expiration and nonce which was sent in authorization request.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken string, nonce string) (*Claims, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keySet, err := jwk.Fetch(ctx, discovery.JWKSURI, jwk.WithHTTPClient(p.HTTPClient))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to fetch keys: %v", ErrInvalidIDToken, err)
+	}
+
+	token, err := jwt.Parse(
+		[]byte(rawIDToken),
+		jwt.WithKeySet(keySet),
+		jwt.UseDefaultKey(true),
+		jwt.InferAlgorithmFromKey(true),
+		jwt.WithValidate(true),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.Config.ClientID),
+		jwt.WithAcceptableSkew(ClockSkew),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if tokenNonce, _ := token.Get("nonce"); tokenNonce != nonce {
+		return nil, ErrInvalidNonce
+	}
+
+	if token.Subject() == "" {
+		return nil, fmt.Errorf("%w: subject is missing", ErrInvalidIDToken)
+	}
+
+	claims := &Claims{Subject: token.Subject()}
+	if email, ok := token.PrivateClaims()["email"].(string); ok {
+		claims.Email = email
+	}
+
+	if name, ok := token.PrivateClaims()["name"].(string); ok {
+		claims.Name = name
+	}
+
+	// some providers send email_verified as a string
+	switch verified := token.PrivateClaims()["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = verified
+	case string:
+		claims.EmailVerified = verified == "true"
+	}
+
+	return claims, nil
+}
+
+// RandomString returns url safe random value for state, nonce and code verifier
+func RandomString() (string, error) {
+	raw := make([]byte, randomSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// CodeChallenge derives S256 code challenge from code verifier (RFC 7636)
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
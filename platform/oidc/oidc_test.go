@@ -0,0 +1,487 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/sultaniman/confetti/platform/entities"
+	"github.com/sultaniman/confetti/platform/mocks"
+	"github.com/sultaniman/confetti/platform/oidc"
+	"github.com/sultaniman/confetti/platform/schema"
+	"github.com/sultaniman/confetti/platform/services"
+	"github.com/sultaniman/confetti/platform/shared"
+	"github.com/sultaniman/confetti/util"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const (
+	testProviderName = "test"
+	testClientID     = "confetti"
+	testCode         = "authorization-code"
+	testKeyID        = "test-key"
+	testNonce        = "test-nonce"
+	testState        = "test-state"
+)
+
+// testProvider is in-process identity provider which serves discovery,
+// keys and token endpoint, code challenge is taken from authorization url.
+type testProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    jwk.Key
+
+	// discoveryIssuer overrides issuer in discovery document
+	discoveryIssuer string
+	// claims override or extend claims of issued id token, nil removes claim
+	claims        map[string]interface{}
+	codeChallenge string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+
+	rawKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := jwk.New(rawKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = key.Set(jwk.KeyIDKey, testKeyID)
+	_ = key.Set(jwk.AlgorithmKey, jwa.ES256)
+
+	p := &testProvider{t: t, key: key, claims: map[string]interface{}{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc(oidc.DiscoveryPath, p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+func (p *testProvider) issuer() string {
+	return p.server.URL
+}
+
+func (p *testProvider) discovery(w http.ResponseWriter, _ *http.Request) {
+	issuer := p.issuer()
+	if p.discoveryIssuer != "" {
+		issuer = p.discoveryIssuer
+	}
+
+	p.writeJSON(w, &oidc.Discovery{
+		Issuer:                issuer,
+		AuthorizationEndpoint: p.issuer() + "/authorize",
+		TokenEndpoint:         p.issuer() + "/token",
+		JWKSURI:               p.issuer() + "/jwks",
+	})
+}
+
+func (p *testProvider) jwks(w http.ResponseWriter, _ *http.Request) {
+	publicKey, err := p.key.PublicKey()
+	if err != nil {
+		p.t.Fatal(err)
+	}
+
+	keySet := jwk.NewSet()
+	keySet.Add(publicKey)
+	p.writeJSON(w, keySet)
+}
+
+func (p *testProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.PostForm.Get("code") != testCode || r.PostForm.Get("client_id") != testClientID {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	if oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != p.codeChallenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	p.writeJSON(w, &oidc.TokenResponse{
+		AccessToken: "access-token",
+		TokenType:   "Bearer",
+		IDToken:     p.idToken(),
+	})
+}
+
+func (p *testProvider) idToken() string {
+	now := time.Now()
+	claims := map[string]interface{}{
+		jwt.IssuerKey:     p.issuer(),
+		jwt.AudienceKey:   testClientID,
+		jwt.SubjectKey:    "subject",
+		jwt.IssuedAtKey:   now,
+		jwt.ExpirationKey: now.Add(time.Minute),
+		"nonce":           testNonce,
+		"email":           "user@example.com",
+		"email_verified":  true,
+		"name":            "Test User",
+	}
+
+	for name, value := range p.claims {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+
+	token := jwt.New()
+	for name, value := range claims {
+		if err := token.Set(name, value); err != nil {
+			p.t.Fatal(err)
+		}
+	}
+
+	headers := jws.NewHeaders()
+	_ = headers.Set(jws.KeyIDKey, testKeyID)
+	signed, err := jwt.Sign(token, jwa.ES256, p.key, jwt.WithHeaders(headers))
+	if err != nil {
+		p.t.Fatal(err)
+	}
+
+	return string(signed)
+}
+
+func (p *testProvider) writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+// authorize builds authorization url the same way sign in does
+// and remembers code challenge which was sent to the provider.
+func (p *testProvider) authorize(provider *oidc.Provider, codeVerifier string) {
+	p.t.Helper()
+
+	authURL, err := provider.AuthCodeURL(context.Background(), testState, testNonce, codeVerifier)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		p.t.Fatalf("code_challenge_method = %q, want S256", query.Get("code_challenge_method"))
+	}
+
+	if query.Get("state") != testState || query.Get("nonce") != testNonce {
+		p.t.Fatalf("state and nonce are not passed to provider: %s", authURL)
+	}
+
+	p.codeChallenge = query.Get("code_challenge")
+}
+
+func (p *testProvider) provider(clientID string) *oidc.Provider {
+	return oidc.NewProvider(oidc.Config{
+		Name:         "test",
+		Issuer:       p.issuer(),
+		ClientID:     clientID,
+		ClientSecret: "secret",
+		RedirectURL:  "https://confetti.local/auth/oidc/test/callback",
+	}, p.server.Client())
+}
+
+// signIn runs code flow and returns claims of verified id token
+func (p *testProvider) signIn(provider *oidc.Provider, nonce string) (*oidc.Claims, error) {
+	codeVerifier, err := oidc.RandomString()
+	if err != nil {
+		p.t.Fatal(err)
+	}
+
+	p.authorize(provider, codeVerifier)
+	tokenResponse, err := provider.Exchange(context.Background(), testCode, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider.VerifyIDToken(context.Background(), tokenResponse.IDToken, nonce)
+}
+
+func TestSignIn(t *testing.T) {
+	p := newTestProvider(t)
+	claims, err := p.signIn(p.provider(testClientID), testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := oidc.Claims{
+		Subject:       "subject",
+		Email:         "user@example.com",
+		EmailVerified: true,
+		Name:          "Test User",
+	}
+
+	if *claims != want {
+		t.Fatalf("claims = %+v, want %+v", *claims, want)
+	}
+}
+
+func TestExchangeRequiresCodeVerifier(t *testing.T) {
+	p := newTestProvider(t)
+	provider := p.provider(testClientID)
+
+	codeVerifier, _ := oidc.RandomString()
+	p.authorize(provider, codeVerifier)
+
+	otherVerifier, _ := oidc.RandomString()
+	_, err := provider.Exchange(context.Background(), testCode, otherVerifier)
+	if !errors.Is(err, oidc.ErrExchange) {
+		t.Fatalf("err = %v, want %v", err, oidc.ErrExchange)
+	}
+
+	if _, err = provider.Exchange(context.Background(), testCode, codeVerifier); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	challenge := oidc.CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if challenge != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Fatalf("challenge = %s", challenge)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	p := newTestProvider(t)
+	p.discoveryIssuer = "https://attacker.example.com"
+
+	_, err := p.provider(testClientID).Discover(context.Background())
+	if !errors.Is(err, oidc.ErrIssuerMismatch) {
+		t.Fatalf("err = %v, want %v", err, oidc.ErrIssuerMismatch)
+	}
+}
+
+func TestVerifyIDTokenRejectsInvalidTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		nonce  string
+		want   error
+	}{
+		{
+			name:   "issuer mismatch",
+			claims: map[string]interface{}{jwt.IssuerKey: "https://attacker.example.com"},
+			nonce:  testNonce,
+			want:   oidc.ErrInvalidIDToken,
+		},
+		{
+			name:   "audience mismatch",
+			claims: map[string]interface{}{jwt.AudienceKey: "other-client"},
+			nonce:  testNonce,
+			want:   oidc.ErrInvalidIDToken,
+		},
+		{
+			name:   "expired",
+			claims: map[string]interface{}{jwt.ExpirationKey: time.Now().Add(-2 * oidc.ClockSkew)},
+			nonce:  testNonce,
+			want:   oidc.ErrInvalidIDToken,
+		},
+		{
+			name:   "missing subject",
+			claims: map[string]interface{}{jwt.SubjectKey: ""},
+			nonce:  testNonce,
+			want:   oidc.ErrInvalidIDToken,
+		},
+		{
+			name:  "bad nonce",
+			nonce: "other-nonce",
+			want:  oidc.ErrInvalidNonce,
+		},
+		{
+			name:   "missing nonce",
+			claims: map[string]interface{}{"nonce": ""},
+			nonce:  testNonce,
+			want:   oidc.ErrInvalidNonce,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestProvider(t)
+			for name, value := range test.claims {
+				p.claims[name] = value
+			}
+
+			_, err := p.signIn(p.provider(testClientID), test.nonce)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("err = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsForeignKey(t *testing.T) {
+	p := newTestProvider(t)
+	provider := p.provider(testClientID)
+	codeVerifier, _ := oidc.RandomString()
+	p.authorize(provider, codeVerifier)
+	tokenResponse, err := provider.Exchange(context.Background(), testCode, codeVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keys are rotated at provider after the token was issued
+	other := newTestProvider(t)
+	p.key = other.key
+
+	_, err = provider.VerifyIDToken(context.Background(), tokenResponse.IDToken, testNonce)
+	if !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want %v", err, oidc.ErrInvalidIDToken)
+	}
+}
+
+func TestEmailVerified(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  bool
+	}{
+		{name: "bool true", value: true, want: true},
+		{name: "bool false", value: false, want: false},
+		{name: "string true", value: "true", want: true},
+		{name: "string false", value: "false", want: false},
+		{name: "missing", value: nil, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestProvider(t)
+			p.claims["email_verified"] = test.value
+
+			claims, err := p.signIn(p.provider(testClientID), testNonce)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if claims.EmailVerified != test.want {
+				t.Fatalf("EmailVerified = %v, want %v", claims.EmailVerified, test.want)
+			}
+		})
+	}
+}
+
+// callbackService returns sign in service for provider where sign in has been started,
+// repositories are strict mocks so unexpected lookups and links fail the test.
+func callbackService(t *testing.T, p *testProvider) (services.OIDCService, *mocks.MockOIDCRepo, *mocks.MockUserRepo, string) {
+	t.Helper()
+
+	ctrl := gomock.NewController(t)
+	oidcRepo := mocks.NewMockOIDCRepo(ctrl)
+	usersRepo := mocks.NewMockUserRepo(ctrl)
+
+	provider := p.provider(testClientID)
+	codeVerifier, _ := oidc.RandomString()
+	p.authorize(provider, codeVerifier)
+
+	providers := map[string]*oidc.Provider{testProviderName: provider}
+	return services.NewOIDCService(providers, oidcRepo, usersRepo, nil), oidcRepo, usersRepo, codeVerifier
+}
+
+// expectNewIdentity expects state to be consumed once for subject which is not linked yet
+func expectNewIdentity(oidcRepo *mocks.MockOIDCRepo, codeVerifier string) {
+	oidcRepo.EXPECT().
+		ConsumeState(testState, testProviderName).
+		Return(&entities.OIDCState{
+			State:        testState,
+			Provider:     testProviderName,
+			CodeVerifier: codeVerifier,
+			Nonce:        testNonce,
+		}, nil)
+
+	oidcRepo.EXPECT().
+		GetIdentity(testProviderName, "subject").
+		Return(nil, sql.ErrNoRows)
+}
+
+func callbackRequest() *schema.OIDCCallbackRequest {
+	return &schema.OIDCCallbackRequest{
+		Code:      testCode,
+		State:     testState,
+		StateHash: util.HashToken(testState),
+	}
+}
+
+func assertStatus(t *testing.T, err error, statusCode int) {
+	t.Helper()
+
+	var serviceError *shared.ServiceError
+	if !errors.As(err, &serviceError) {
+		t.Fatalf("err = %v, want service error", err)
+	}
+
+	if serviceError.StatusCode != statusCode {
+		t.Fatalf("status = %d, want %d", serviceError.StatusCode, statusCode)
+	}
+}
+
+func TestCallbackRequiresStateCookie(t *testing.T) {
+	// state is not consumed when callback comes from another browser
+	p := newTestProvider(t)
+	service, _, _, _ := callbackService(t, p)
+
+	for _, stateHash := range []string{"", util.HashToken("other-state")} {
+		request := callbackRequest()
+		request.StateHash = stateHash
+
+		_, err := service.Callback(context.Background(), testProviderName, request)
+		assertStatus(t, err, http.StatusUnauthorized)
+	}
+}
+
+func TestCallbackRefusesUnverifiedEmail(t *testing.T) {
+	// user with the same e-mail is neither looked up nor linked
+	p := newTestProvider(t)
+	p.claims["email_verified"] = false
+
+	service, oidcRepo, _, codeVerifier := callbackService(t, p)
+	expectNewIdentity(oidcRepo, codeVerifier)
+
+	_, err := service.Callback(context.Background(), testProviderName, callbackRequest())
+	assertStatus(t, err, http.StatusUnauthorized)
+}
+
+func TestCallbackRefusesUnconfirmedUser(t *testing.T) {
+	p := newTestProvider(t)
+	service, oidcRepo, usersRepo, codeVerifier := callbackService(t, p)
+	expectNewIdentity(oidcRepo, codeVerifier)
+
+	usersRepo.EXPECT().
+		GetByEmail("user@example.com").
+		Return(&entities.User{ID: uuid.New(), Email: "user@example.com", Password: "hash"}, nil)
+
+	_, err := service.Callback(context.Background(), testProviderName, callbackRequest())
+	assertStatus(t, err, http.StatusForbidden)
+}
@@ -0,0 +1,250 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sultaniman/confetti/platform/entities"
+	"sync"
+	"time"
+)
+
+// LoginAttemptRepo keeps failed sign in counters, it has Postgres
+// implementation for multi-instance deployments and in-memory one.
+//
+//go:generate mockgen -source=attempts.go -destination=../mocks/attempts.go -package=mocks
+type LoginAttemptRepo interface {
+	// Get returns nil if there were no failures
+	Get(key string) (*entities.LoginAttempts, error)
+	// RegisterFailure increments counter, counting starts over once last failure
+	// is older than window or the previous lockout has expired.
+	RegisterFailure(key string, now time.Time, window time.Duration) (*entities.LoginAttempts, error)
+	Lock(key string, until time.Time) error
+	// Reset clears counter, when audit record is given it is written together with the change
+	Reset(key string, auditRecord *entities.NewAdminAuditRecord) error
+	// DeleteStale removes counters which are not locked and had no failures within window
+	DeleteStale(now time.Time, window time.Duration) (int64, error)
+}
+
+type loginAttemptRepo struct {
+	Base *Repo
+}
+
+func NewLoginAttemptRepo(base *Repo) LoginAttemptRepo {
+	return &loginAttemptRepo{
+		Base: base,
+	}
+}
+
+func (r *loginAttemptRepo) Get(key string) (*entities.LoginAttempts, error) {
+	query, args, err := r.Base.
+		Select("login_attempts").
+		Where(sq.Eq{"key": key}).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := new(entities.LoginAttempts)
+	err = r.Base.DB.Get(attempts, query, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return attempts, err
+}
+
+func (r *loginAttemptRepo) RegisterFailure(key string, now time.Time, window time.Duration) (*entities.LoginAttempts, error) {
+	now = now.UTC()
+	query, args, err := r.Base.Q.
+		Insert("login_attempts").
+		Columns("key", "failures", "last_failure_at").
+		Values(key, 1, now).
+		Suffix(`ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_attempts.last_failure_at < ? OR login_attempts.locked_until < ? THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			locked_until = CASE
+				WHEN login_attempts.locked_until < ? THEN NULL
+				ELSE login_attempts.locked_until
+			END,
+			last_failure_at = EXCLUDED.last_failure_at
+			RETURNING *`, now.Add(-window), now, now).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := new(entities.LoginAttempts)
+	return attempts, r.Base.DB.Get(attempts, query, args...)
+}
+
+func (r *loginAttemptRepo) Lock(key string, until time.Time) error {
+	query, args, err := r.Base.Q.
+		Update("login_attempts").
+		Set("locked_until", until.UTC()).
+		Where(sq.Eq{"key": key}).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Base.DB.Exec(query, args...)
+	return err
+}
+
+func (r *loginAttemptRepo) Reset(key string, auditRecord *entities.NewAdminAuditRecord) error {
+	query, args, err := r.Base.Q.
+		Delete("login_attempts").
+		Where(sq.Eq{"key": key}).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	if auditRecord == nil {
+		_, err = r.Base.DB.Exec(query, args...)
+		return err
+	}
+
+	tx, err := r.Base.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	auditQuery, auditArgs, err := insertAdminAuditRecord(r.Base, auditRecord).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(auditQuery, auditArgs...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *loginAttemptRepo) DeleteStale(now time.Time, window time.Duration) (int64, error) {
+	now = now.UTC()
+	query, args, err := r.Base.Q.
+		Delete("login_attempts").
+		Where(sq.Lt{"last_failure_at": now.Add(-window)}).
+		Where(sq.Or{sq.Eq{"locked_until": nil}, sq.Lt{"locked_until": now}}).
+		ToSql()
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.Base.DB.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+type memoryLoginAttemptRepo struct {
+	Base     *Repo
+	mu       sync.Mutex
+	attempts map[string]entities.LoginAttempts
+}
+
+// NewMemoryLoginAttemptRepo keeps counters in process memory,
+// it suits single instance deployments and development.
+// Audit records of admin resets are still written to Postgres.
+func NewMemoryLoginAttemptRepo(base *Repo) LoginAttemptRepo {
+	return &memoryLoginAttemptRepo{
+		Base:     base,
+		attempts: map[string]entities.LoginAttempts{},
+	}
+}
+
+func (r *memoryLoginAttemptRepo) Get(key string) (*entities.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts, found := r.attempts[key]
+	if !found {
+		return nil, nil
+	}
+
+	return &attempts, nil
+}
+
+func (r *memoryLoginAttemptRepo) RegisterFailure(key string, now time.Time, window time.Duration) (*entities.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts, found := r.attempts[key]
+	lockExpired := attempts.LockedUntil != nil && attempts.LockedUntil.Before(now)
+	if !found || attempts.LastFailureAt.Before(now.Add(-window)) || lockExpired {
+		attempts = entities.LoginAttempts{Key: key}
+	}
+
+	attempts.Failures++
+	attempts.LastFailureAt = now
+	r.attempts[key] = attempts
+	return &attempts, nil
+}
+
+func (r *memoryLoginAttemptRepo) Lock(key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempts, found := r.attempts[key]; found {
+		attempts.LockedUntil = &until
+		r.attempts[key] = attempts
+	}
+
+	return nil
+}
+
+// Reset writes audit record first, dropping counter can not fail afterwards
+func (r *memoryLoginAttemptRepo) Reset(key string, auditRecord *entities.NewAdminAuditRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if auditRecord != nil {
+		query, args, err := insertAdminAuditRecord(r.Base, auditRecord).ToSql()
+		if err != nil {
+			return err
+		}
+
+		if _, err = r.Base.DB.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	delete(r.attempts, key)
+	return nil
+}
+
+func (r *memoryLoginAttemptRepo) DeleteStale(now time.Time, window time.Duration) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for key, attempts := range r.attempts {
+		locked := attempts.LockedUntil != nil && attempts.LockedUntil.After(now)
+		if !locked && attempts.LastFailureAt.Before(now.Add(-window)) {
+			delete(r.attempts, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,172 @@
+package repo
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sultaniman/confetti/platform/entities"
+	"time"
+)
+
+type AuditFilterSpec struct {
+	ActorId *uuid.UUID
+	CardId  *uuid.UUID
+	Limit   uint64
+}
+
+//go:generate mockgen -source=audit.go -destination=../mocks/audit.go -package=mocks
+type AuditRepo interface {
+	Create(record *entities.NewCardAuditRecord) (*entities.CardAuditRecord, error)
+	List(filterSpec *AuditFilterSpec) ([]entities.CardAuditRecord, error)
+}
+
+type AdminAuditFilterSpec struct {
+	UserId *uuid.UUID
+	Limit  uint64
+}
+
+type AdminAuditRepo interface {
+	Create(record *entities.NewAdminAuditRecord) (*entities.AdminAuditRecord, error)
+	List(filterSpec *AdminAuditFilterSpec) ([]entities.AdminAuditRecord, error)
+}
+
+type auditRepo struct {
+	Base *Repo
+}
+
+func NewAuditRepo(base *Repo) AuditRepo {
+	return &auditRepo{
+		Base: base,
+	}
+}
+
+func (r *auditRepo) Create(record *entities.NewCardAuditRecord) (*entities.CardAuditRecord, error) {
+	query, args, err := insertCardAuditRecord(r.Base, record).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	auditRecord := new(entities.CardAuditRecord)
+	return auditRecord, r.Base.DB.Get(auditRecord, query, args...)
+}
+
+// insertCardAuditRecord is shared with card repo which writes
+// audit records in the same transaction as card changes.
+func insertCardAuditRecord(base *Repo, record *entities.NewCardAuditRecord) sq.InsertBuilder {
+	return base.
+		Insert(
+			"card_audit_log",
+			"actor_id",
+			"card_id",
+			"action",
+			"request_id",
+			"ip_address",
+			"user_agent",
+			"created_at",
+		).
+		Values(
+			record.ActorId,
+			record.CardId,
+			string(record.Action),
+			record.RequestId,
+			record.IPAddress,
+			record.UserAgent,
+			time.Now().UTC(),
+		)
+}
+
+func (r *auditRepo) List(filterSpec *AuditFilterSpec) ([]entities.CardAuditRecord, error) {
+	filters := sq.Eq{}
+	if filterSpec.ActorId != nil {
+		filters["actor_id"] = filterSpec.ActorId
+	}
+
+	if filterSpec.CardId != nil {
+		filters["card_id"] = filterSpec.CardId
+	}
+
+	qs := r.Base.
+		Select("card_audit_log").
+		Where(filters).
+		OrderBy("created_at DESC")
+
+	if filterSpec.Limit > 0 {
+		qs = qs.Limit(filterSpec.Limit)
+	}
+
+	query, args, err := qs.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	records := new([]entities.CardAuditRecord)
+	return *records, r.Base.DB.Select(records, query, args...)
+}
+
+type adminAuditRepo struct {
+	Base *Repo
+}
+
+func NewAdminAuditRepo(base *Repo) AdminAuditRepo {
+	return &adminAuditRepo{
+		Base: base,
+	}
+}
+
+func (r *adminAuditRepo) Create(record *entities.NewAdminAuditRecord) (*entities.AdminAuditRecord, error) {
+	query, args, err := insertAdminAuditRecord(r.Base, record).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	auditRecord := new(entities.AdminAuditRecord)
+	return auditRecord, r.Base.DB.Get(auditRecord, query, args...)
+}
+
+// insertAdminAuditRecord is shared with user repo which writes
+// audit records in the same transaction as admin changes.
+func insertAdminAuditRecord(base *Repo, record *entities.NewAdminAuditRecord) sq.InsertBuilder {
+	return base.
+		Insert(
+			"admin_audit_log",
+			"admin_id",
+			"user_id",
+			"action",
+			"request_id",
+			"ip_address",
+			"user_agent",
+			"created_at",
+		).
+		Values(
+			record.AdminId,
+			record.UserId,
+			string(record.Action),
+			record.RequestId,
+			record.IPAddress,
+			record.UserAgent,
+			time.Now().UTC(),
+		)
+}
+
+func (r *adminAuditRepo) List(filterSpec *AdminAuditFilterSpec) ([]entities.AdminAuditRecord, error) {
+	filters := sq.Eq{}
+	if filterSpec.UserId != nil {
+		filters["user_id"] = filterSpec.UserId
+	}
+
+	qs := r.Base.
+		Select("admin_audit_log").
+		Where(filters).
+		OrderBy("created_at DESC")
+
+	if filterSpec.Limit > 0 {
+		qs = qs.Limit(filterSpec.Limit)
+	}
+
+	query, args, err := qs.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	records := new([]entities.AdminAuditRecord)
+	return *records, r.Base.DB.Select(records, query, args...)
+}
@@ -1,25 +1,111 @@
 package repo
 
 import (
+	"fmt"
 	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	"github.com/sultaniman/confetti/platform/entities"
+	"github.com/sultaniman/confetti/platform/keys"
+	"strings"
 	"time"
 )
 
+// Sortable card columns
+const (
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+	SortByTitle     = "title"
+)
+
+// Cursor points at the last card of the previous page, Value
+// is the sort column value and ID breaks ties between equal values.
+type Cursor struct {
+	Value interface{}
+	ID    uuid.UUID
+}
+
 type FilterSpec struct {
 	UserId *uuid.UUID
 	ID     *uuid.UUID
+	// Title is matched case-insensitively as substring or as prefix
+	Title         string
+	TitlePrefix   bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	// SortBy defaults to created_at, newest first unless SortAsc is set
+	SortBy  string
+	SortAsc bool
+	After   *Cursor
+	Limit   uint64
+}
+
+func (f *FilterSpec) sortColumn() string {
+	switch f.SortBy {
+	case SortByUpdatedAt, SortByTitle:
+		return f.SortBy
+	default:
+		return SortByCreatedAt
+	}
+}
+
+// where builds conditions without pagination so they can be used for counting
+func (f *FilterSpec) where() sq.And {
+	filters := sq.Eq{}
+	if f.ID != nil {
+		filters["id"] = f.ID
+	}
+
+	if f.UserId != nil {
+		filters["user_id"] = f.UserId
+	}
+
+	conditions := sq.And{filters}
+	if f.Title != "" {
+		pattern := likeEscaper.Replace(f.Title) + "%"
+		if !f.TitlePrefix {
+			pattern = "%" + pattern
+		}
+
+		conditions = append(conditions, sq.ILike{"title": pattern})
+	}
+
+	if f.CreatedAfter != nil {
+		conditions = append(conditions, sq.GtOrEq{"created_at": f.CreatedAfter.UTC()})
+	}
+
+	if f.CreatedBefore != nil {
+		conditions = append(conditions, sq.Lt{"created_at": f.CreatedBefore.UTC()})
+	}
+
+	if f.UpdatedAfter != nil {
+		conditions = append(conditions, sq.GtOrEq{"updated_at": f.UpdatedAfter.UTC()})
+	}
+
+	if f.UpdatedBefore != nil {
+		conditions = append(conditions, sq.Lt{"updated_at": f.UpdatedBefore.UTC()})
+	}
+
+	return conditions
 }
 
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// RewrapFunc re-encrypts passphrase of a card with another key
+type RewrapFunc func(card *entities.Card) (*entities.KeyUpdate, error)
+
 //go:generate mockgen -source=cards.go -destination=../mocks/cards.go -package=mocks
 type CardRepo interface {
 	Get(id uuid.UUID) (*entities.Card, error)
 	List(filterSpec *FilterSpec) ([]entities.Card, error)
-	Create(card *entities.NewCard) (*entities.Card, error)
-	Update(cardId uuid.UUID, newTitle string) (*entities.Card, error)
-	Delete(id uuid.UUID) error
+	Count(filterSpec *FilterSpec) (int, error)
+	Create(card *entities.NewCard, auditRecord *entities.NewCardAuditRecord) (*entities.Card, error)
+	Update(cardId uuid.UUID, newTitle string, auditRecord *entities.NewCardAuditRecord) (*entities.Card, error)
+	Delete(id uuid.UUID, auditRecord *entities.NewCardAuditRecord) error
 	ClaimExists(cardId uuid.UUID, userId uuid.UUID) bool
+	KeyUsage() ([]entities.KeyUsage, error)
+	RewrapKeys(fromKeyID string, batchSize uint64, rewrap RewrapFunc) (int, error)
 }
 
 type cardRepo struct {
@@ -46,23 +132,34 @@ func (c *cardRepo) Get(id uuid.UUID) (*entities.Card, error) {
 	return card, c.Base.DB.Get(card, query, args...)
 }
 
+// List uses keyset pagination, rows after cursor are selected by
+// comparing (sort column, id) pairs in the direction of sorting.
 func (c *cardRepo) List(filterSpec *FilterSpec) ([]entities.Card, error) {
-	qs := c.Base.
-		Select("cards")
+	column := filterSpec.sortColumn()
+	direction, comparison := "DESC", "<"
+	if filterSpec.SortAsc {
+		direction, comparison = "ASC", ">"
+	}
 
-	filters := sq.Eq{}
-	if filterSpec.ID != nil {
-		filters["id"] = filterSpec.ID
+	conditions := filterSpec.where()
+	if filterSpec.After != nil {
+		conditions = append(conditions, sq.Expr(
+			fmt.Sprintf("(%s, id) %s (?, ?)", column, comparison),
+			filterSpec.After.Value,
+			filterSpec.After.ID,
+		))
 	}
 
-	if filterSpec.UserId != nil {
-		filters["user_id"] = filterSpec.UserId
+	qs := c.Base.
+		Select("cards").
+		Where(conditions).
+		OrderBy(fmt.Sprintf("%s %s", column, direction), fmt.Sprintf("id %s", direction))
+
+	if filterSpec.Limit > 0 {
+		qs = qs.Limit(filterSpec.Limit)
 	}
 
-	query, args, err := qs.
-		Where(filters).
-		OrderBy("created_at DESC").
-		ToSql()
+	query, args, err := qs.ToSql()
 
 	if err != nil {
 		return nil, err
@@ -72,7 +169,24 @@ func (c *cardRepo) List(filterSpec *FilterSpec) ([]entities.Card, error) {
 	return *cards, c.Base.DB.Select(cards, query, args...)
 }
 
-func (c *cardRepo) Create(card *entities.NewCard) (*entities.Card, error) {
+// Count ignores pagination of filter spec
+func (c *cardRepo) Count(filterSpec *FilterSpec) (int, error) {
+	query, args, err := c.Base.Q.
+		Select("COUNT(id)").
+		From("cards").
+		Where(filterSpec.where()).
+		ToSql()
+
+	if err != nil {
+		return 0, err
+	}
+
+	cardCount := 0
+	return cardCount, c.Base.DB.Get(&cardCount, query, args...)
+}
+
+// Create inserts card together with its audit record, card is not created if audit fails
+func (c *cardRepo) Create(card *entities.NewCard, auditRecord *entities.NewCardAuditRecord) (*entities.Card, error) {
 	query, args, err := c.Base.
 		Insert(
 			"cards",
@@ -99,11 +213,10 @@ func (c *cardRepo) Create(card *entities.NewCard) (*entities.Card, error) {
 		return nil, err
 	}
 
-	cardRow := new(entities.Card)
-	return cardRow, c.Base.DB.Get(cardRow, query, args...)
+	return c.withAudit(query, args, auditRecord)
 }
 
-func (c *cardRepo) Update(cardId uuid.UUID, newTitle string) (*entities.Card, error) {
+func (c *cardRepo) Update(cardId uuid.UUID, newTitle string, auditRecord *entities.NewCardAuditRecord) (*entities.Card, error) {
 	query, args, err := c.Base.
 		Update("cards", true).
 		Where(sq.Eq{"id": cardId}).
@@ -114,11 +227,10 @@ func (c *cardRepo) Update(cardId uuid.UUID, newTitle string) (*entities.Card, er
 		return nil, err
 	}
 
-	card := new(entities.Card)
-	return card, c.Base.DB.Get(card, query, args...)
+	return c.withAudit(query, args, auditRecord)
 }
 
-func (c *cardRepo) Delete(id uuid.UUID) error {
+func (c *cardRepo) Delete(id uuid.UUID, auditRecord *entities.NewCardAuditRecord) error {
 	query, args, err := c.Base.
 		Delete("cards", sq.Eq{"id": id}).
 		ToSql()
@@ -127,8 +239,38 @@ func (c *cardRepo) Delete(id uuid.UUID) error {
 		return err
 	}
 
+	_, err = c.withAudit(query, args, auditRecord)
+	return err
+}
+
+// withAudit runs card query which returns the card and records audit
+// for it in the same transaction, so card changes are never left unrecorded.
+func (c *cardRepo) withAudit(query string, args []interface{}, auditRecord *entities.NewCardAuditRecord) (*entities.Card, error) {
+	tx, err := c.Base.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
 	card := new(entities.Card)
-	return c.Base.DB.Get(card, query, args...)
+	if err = tx.Get(card, query, args...); err != nil {
+		return nil, err
+	}
+
+	auditRecord.CardId = card.ID
+	auditQuery, auditArgs, err := insertCardAuditRecord(c.Base, auditRecord).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec(auditQuery, auditArgs...); err != nil {
+		return nil, err
+	}
+
+	return card, tx.Commit()
 }
 
 func (c *cardRepo) ClaimExists(cardId uuid.UUID, userId uuid.UUID) bool {
@@ -148,3 +290,89 @@ func (c *cardRepo) ClaimExists(cardId uuid.UUID, userId uuid.UUID) bool {
 
 	return rowCount > 0
 }
+
+// KeyUsage counts cards per key, cards without key id are counted under the default key
+func (c *cardRepo) KeyUsage() ([]entities.KeyUsage, error) {
+	query, args, err := c.Base.Q.
+		Select().
+		Column(sq.Expr("COALESCE(NULLIF(key_id, ''), ?) AS key_id", keys.DefaultKeyID)).
+		Column("COUNT(id) AS cards").
+		From("cards").
+		GroupBy("1").
+		OrderBy("1").
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	usage := new([]entities.KeyUsage)
+	return *usage, c.Base.DB.Select(usage, query, args...)
+}
+
+// RewrapKeys updates a batch of cards encrypted with a given key in a single
+// transaction, rows are locked so concurrent runs do not overlap and since
+// updated cards no longer match the key id it is safe to resume at any time.
+func (c *cardRepo) RewrapKeys(fromKeyID string, batchSize uint64, rewrap RewrapFunc) (int, error) {
+	tx, err := c.Base.DB.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// only columns needed to rewrap are selected since legacy cards have no key id
+	query, args, err := c.Base.Q.
+		Select("id", "encrypted_key").
+		From("cards").
+		Where(keyIDCondition(fromKeyID)).
+		OrderBy("id").
+		Limit(batchSize).
+		Suffix("FOR UPDATE SKIP LOCKED").
+		ToSql()
+
+	if err != nil {
+		return 0, err
+	}
+
+	cards := new([]entities.Card)
+	if err = tx.Select(cards, query, args...); err != nil {
+		return 0, err
+	}
+
+	for _, card := range *cards {
+		keyUpdate, err := rewrap(&card)
+		if err != nil {
+			return 0, fmt.Errorf("card %s: %w", card.ID, err)
+		}
+
+		query, args, err := c.Base.Q.
+			Update("cards").
+			Set("encrypted_key", keyUpdate.EncryptedKey).
+			Set("key_id", keyUpdate.KeyID).
+			Where(sq.Eq{"id": card.ID}).
+			ToSql()
+
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err = tx.Exec(query, args...); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(*cards), tx.Commit()
+}
+
+// keyIDCondition matches cards encrypted with the key, cards which were
+// created before key ids were stored have none and use the default key.
+func keyIDCondition(keyID string) sq.Sqlizer {
+	if keyID == keys.DefaultKeyID {
+		return sq.Or{sq.Eq{"key_id": []string{keyID, ""}}, sq.Eq{"key_id": nil}}
+	}
+
+	return sq.Eq{"key_id": keyID}
+}
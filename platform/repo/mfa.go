@@ -0,0 +1,205 @@
+package repo
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sultaniman/confetti/platform/entities"
+	"time"
+)
+
+//go:generate mockgen -source=mfa.go -destination=../mocks/mfa.go -package=mocks
+type MFARepo interface {
+	GetTOTP(userId uuid.UUID) (*entities.TOTP, error)
+	SaveTOTP(totp *entities.NewTOTP) (*entities.TOTP, error)
+	ConfirmTOTP(userId uuid.UUID) error
+	UseTOTPStep(userId uuid.UUID, step int64) (bool, error)
+	DeleteTOTP(userId uuid.UUID) error
+	ReplaceRecoveryCodes(userId uuid.UUID, codeHashes []string) error
+	UseRecoveryCode(userId uuid.UUID, codeHash string) (bool, error)
+}
+
+type mfaRepo struct {
+	Base *Repo
+}
+
+func NewMFARepo(base *Repo) MFARepo {
+	return &mfaRepo{
+		Base: base,
+	}
+}
+
+func (r *mfaRepo) GetTOTP(userId uuid.UUID) (*entities.TOTP, error) {
+	query, args, err := r.Base.
+		Select("user_totp").
+		Where(sq.Eq{"user_id": userId}).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	totp := new(entities.TOTP)
+	return totp, r.Base.DB.Get(totp, query, args...)
+}
+
+// SaveTOTP stores new unconfirmed secret replacing previous enrollment
+func (r *mfaRepo) SaveTOTP(totp *entities.NewTOTP) (*entities.TOTP, error) {
+	query, args, err := r.Base.Q.
+		Insert("user_totp").
+		Columns(
+			"user_id",
+			"encrypted_secret",
+			"key_id",
+			"created_at",
+		).
+		Values(
+			totp.UserId,
+			totp.EncryptedSecret,
+			totp.KeyID,
+			time.Now().UTC(),
+		).
+		Suffix(`ON CONFLICT (user_id) DO UPDATE SET
+			encrypted_secret = EXCLUDED.encrypted_secret,
+			key_id = EXCLUDED.key_id,
+			last_used_step = 0,
+			confirmed_at = NULL,
+			created_at = EXCLUDED.created_at
+			returning *`).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	totpRow := new(entities.TOTP)
+	return totpRow, r.Base.DB.Get(totpRow, query, args...)
+}
+
+func (r *mfaRepo) ConfirmTOTP(userId uuid.UUID) error {
+	query, args, err := r.Base.Q.
+		Update("user_totp").
+		Set("confirmed_at", time.Now().UTC()).
+		Where(sq.Eq{"user_id": userId}).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Base.DB.Exec(query, args...)
+	return err
+}
+
+// UseTOTPStep marks time step as used, it fails if the same
+// or a later step was already used so codes can not be replayed.
+func (r *mfaRepo) UseTOTPStep(userId uuid.UUID, step int64) (bool, error) {
+	query, args, err := r.Base.Q.
+		Update("user_totp").
+		Set("last_used_step", step).
+		Where(sq.Eq{"user_id": userId}).
+		Where(sq.Lt{"last_used_step": step}).
+		ToSql()
+
+	if err != nil {
+		return false, err
+	}
+
+	return r.affected(query, args)
+}
+
+func (r *mfaRepo) DeleteTOTP(userId uuid.UUID) error {
+	tx, err := r.Base.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, table := range []string{"recovery_codes", "user_totp"} {
+		query, args, err := r.Base.Q.
+			Delete(table).
+			Where(sq.Eq{"user_id": userId}).
+			ToSql()
+
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes removes all previous recovery codes and stores new ones
+func (r *mfaRepo) ReplaceRecoveryCodes(userId uuid.UUID, codeHashes []string) error {
+	tx, err := r.Base.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query, args, err := r.Base.Q.
+		Delete("recovery_codes").
+		Where(sq.Eq{"user_id": userId}).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	insert := r.Base.Q.
+		Insert("recovery_codes").
+		Columns("user_id", "code_hash", "created_at")
+
+	now := time.Now().UTC()
+	for _, codeHash := range codeHashes {
+		insert = insert.Values(userId, codeHash, now)
+	}
+
+	query, args, err = insert.ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *mfaRepo) UseRecoveryCode(userId uuid.UUID, codeHash string) (bool, error) {
+	query, args, err := r.Base.Q.
+		Update("recovery_codes").
+		Set("used_at", time.Now().UTC()).
+		Where(sq.Eq{"user_id": userId, "code_hash": codeHash, "used_at": nil}).
+		ToSql()
+
+	if err != nil {
+		return false, err
+	}
+
+	return r.affected(query, args)
+}
+
+func (r *mfaRepo) affected(query string, args []interface{}) (bool, error) {
+	result, err := r.Base.DB.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	rowCount, err := result.RowsAffected()
+	return rowCount > 0, err
+}
@@ -0,0 +1,145 @@
+package repo
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sultaniman/confetti/platform/entities"
+	"time"
+)
+
+//go:generate mockgen -source=oidc.go -destination=../mocks/oidc.go -package=mocks
+type OIDCRepo interface {
+	CreateState(state *entities.NewOIDCState) error
+	ConsumeState(state string, provider string) (*entities.OIDCState, error)
+	GetIdentity(provider string, subject string) (*entities.UserIdentity, error)
+	CreateIdentity(identity *entities.NewUserIdentity) (*entities.UserIdentity, error)
+	TouchIdentity(id uuid.UUID) error
+}
+
+type oidcRepo struct {
+	Base *Repo
+}
+
+func NewOIDCRepo(base *Repo) OIDCRepo {
+	return &oidcRepo{
+		Base: base,
+	}
+}
+
+// CreateState stores pending authorization request and cleans up abandoned ones
+func (r *oidcRepo) CreateState(state *entities.NewOIDCState) error {
+	now := time.Now().UTC()
+	query, args, err := r.Base.Q.
+		Delete("oidc_states").
+		Where(sq.Lt{"expires_at": now}).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = r.Base.DB.Exec(query, args...); err != nil {
+		return err
+	}
+
+	query, args, err = r.Base.
+		Insert(
+			"oidc_states",
+			"state",
+			"provider",
+			"code_verifier",
+			"nonce",
+			"expires_at",
+			"created_at",
+		).
+		Values(
+			state.State,
+			state.Provider,
+			state.CodeVerifier,
+			state.Nonce,
+			state.ExpiresAt.UTC(),
+			now,
+		).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Base.DB.Exec(query, args...)
+	return err
+}
+
+// ConsumeState removes state so that callback can be handled only once
+func (r *oidcRepo) ConsumeState(state string, provider string) (*entities.OIDCState, error) {
+	query, args, err := r.Base.
+		Delete("oidc_states", sq.Eq{"state": state, "provider": provider}).
+		Where(sq.Gt{"expires_at": time.Now().UTC()}).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	stateRow := new(entities.OIDCState)
+	return stateRow, r.Base.DB.Get(stateRow, query, args...)
+}
+
+func (r *oidcRepo) GetIdentity(provider string, subject string) (*entities.UserIdentity, error) {
+	query, args, err := r.Base.
+		Select("user_identities").
+		Where(sq.Eq{"provider": provider, "subject": subject}).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	identity := new(entities.UserIdentity)
+	return identity, r.Base.DB.Get(identity, query, args...)
+}
+
+func (r *oidcRepo) CreateIdentity(identity *entities.NewUserIdentity) (*entities.UserIdentity, error) {
+	now := time.Now().UTC()
+	query, args, err := r.Base.
+		Insert(
+			"user_identities",
+			"user_id",
+			"provider",
+			"subject",
+			"email",
+			"created_at",
+			"last_login_at",
+		).
+		Values(
+			identity.UserId,
+			identity.Provider,
+			identity.Subject,
+			identity.Email,
+			now,
+			now,
+		).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	identityRow := new(entities.UserIdentity)
+	return identityRow, r.Base.DB.Get(identityRow, query, args...)
+}
+
+func (r *oidcRepo) TouchIdentity(id uuid.UUID) error {
+	query, args, err := r.Base.Q.
+		Update("user_identities").
+		Set("last_login_at", time.Now().UTC()).
+		Where(sq.Eq{"id": id}).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Base.DB.Exec(query, args...)
+	return err
+}
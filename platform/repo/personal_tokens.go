@@ -0,0 +1,134 @@
+package repo
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sultaniman/confetti/platform/entities"
+	"time"
+)
+
+//go:generate mockgen -source=personal_tokens.go -destination=../mocks/personal_tokens.go -package=mocks
+type PersonalTokenRepo interface {
+	Create(token *entities.NewPersonalToken) (*entities.PersonalToken, error)
+	GetByHash(tokenHash string) (*entities.PersonalToken, error)
+	List(userId uuid.UUID) ([]entities.PersonalToken, error)
+	Delete(userId uuid.UUID, id uuid.UUID) (bool, error)
+	Touch(id uuid.UUID, usedAt time.Time, interval time.Duration) error
+}
+
+type personalTokenRepo struct {
+	Base *Repo
+}
+
+func NewPersonalTokenRepo(base *Repo) PersonalTokenRepo {
+	return &personalTokenRepo{
+		Base: base,
+	}
+}
+
+func (r *personalTokenRepo) Create(token *entities.NewPersonalToken) (*entities.PersonalToken, error) {
+	var expiresAt *time.Time
+	if token.ExpiresAt != nil {
+		utc := token.ExpiresAt.UTC()
+		expiresAt = &utc
+	}
+
+	query, args, err := r.Base.
+		Insert(
+			"personal_access_tokens",
+			"user_id",
+			"name",
+			"token_hash",
+			"token_prefix",
+			"scopes",
+			"expires_at",
+			"created_at",
+		).
+		Values(
+			token.UserId,
+			token.Name,
+			token.TokenHash,
+			token.TokenPrefix,
+			token.Scopes,
+			expiresAt,
+			time.Now().UTC(),
+		).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	tokenRow := new(entities.PersonalToken)
+	return tokenRow, r.Base.DB.Get(tokenRow, query, args...)
+}
+
+func (r *personalTokenRepo) GetByHash(tokenHash string) (*entities.PersonalToken, error) {
+	query, args, err := r.Base.
+		Select("personal_access_tokens").
+		Where(sq.Eq{"token_hash": tokenHash}).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	tokenRow := new(entities.PersonalToken)
+	return tokenRow, r.Base.DB.Get(tokenRow, query, args...)
+}
+
+func (r *personalTokenRepo) List(userId uuid.UUID) ([]entities.PersonalToken, error) {
+	query, args, err := r.Base.
+		Select("personal_access_tokens").
+		Where(sq.Eq{"user_id": userId}).
+		OrderBy("created_at DESC").
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := new([]entities.PersonalToken)
+	return *tokens, r.Base.DB.Select(tokens, query, args...)
+}
+
+// Delete revokes token, reports false if user has no such token
+func (r *personalTokenRepo) Delete(userId uuid.UUID, id uuid.UUID) (bool, error) {
+	query, args, err := r.Base.Q.
+		Delete("personal_access_tokens").
+		Where(sq.Eq{"id": id, "user_id": userId}).
+		ToSql()
+
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.Base.DB.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// Touch updates last use at most once per interval to avoid write on every request
+func (r *personalTokenRepo) Touch(id uuid.UUID, usedAt time.Time, interval time.Duration) error {
+	usedAt = usedAt.UTC()
+	query, args, err := r.Base.Q.
+		Update("personal_access_tokens").
+		Set("last_used_at", usedAt).
+		Where(sq.Eq{"id": id}).
+		Where(sq.Or{
+			sq.Eq{"last_used_at": nil},
+			sq.Lt{"last_used_at": usedAt.Add(-interval)},
+		}).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Base.DB.Exec(query, args...)
+	return err
+}
@@ -11,6 +11,18 @@ type Repo struct {
 	Q  *sq.StatementBuilderType
 }
 
+func New(db *sqlx.DB) *Repo {
+	psql := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		RunWith(db)
+
+	return &Repo{
+		DB: db,
+		Q:  &psql,
+	}
+}
+
 func (r *Repo) Select(table string) sq.SelectBuilder {
 	return r.Q.Select("*").From(table)
 }
@@ -0,0 +1,237 @@
+package repo
+
+import (
+	"errors"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sultaniman/confetti/platform/entities"
+	"time"
+)
+
+var ErrTokenAlreadyRotated = errors.New("refresh token has already been rotated")
+
+//go:generate mockgen -source=tokens.go -destination=../mocks/tokens.go -package=mocks
+type TokenRepo interface {
+	Create(token *entities.NewRefreshToken) (*entities.RefreshToken, error)
+	Get(jti uuid.UUID) (*entities.RefreshToken, error)
+	Rotate(jti uuid.UUID, token *entities.NewRefreshToken) (*entities.RefreshToken, error)
+	Revoke(jti uuid.UUID) error
+	RevokeFamily(familyId uuid.UUID) error
+	RevokeAll(userId uuid.UUID) error
+	ListSessions(userId uuid.UUID) ([]entities.Session, error)
+	RevokeSession(userId uuid.UUID, familyId uuid.UUID) (bool, error)
+}
+
+type tokenRepo struct {
+	Base *Repo
+}
+
+func NewTokenRepo(base *Repo) TokenRepo {
+	return &tokenRepo{
+		Base: base,
+	}
+}
+
+func (r *tokenRepo) Create(token *entities.NewRefreshToken) (*entities.RefreshToken, error) {
+	query, args, err := r.insertQuery(uuid.New(), token)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenRow := new(entities.RefreshToken)
+	return tokenRow, r.Base.DB.Get(tokenRow, query, args...)
+}
+
+// Rotate revokes refresh token and issues its replacement within the same family,
+// if the token was already revoked then ErrTokenAlreadyRotated is returned.
+func (r *tokenRepo) Rotate(jti uuid.UUID, token *entities.NewRefreshToken) (*entities.RefreshToken, error) {
+	tx, err := r.Base.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	newJTI := uuid.New()
+	query, args, err := r.Base.Q.
+		Update("refresh_tokens").
+		Set("revoked_at", time.Now().UTC()).
+		Set("replaced_by", newJTI).
+		Where(sq.Eq{"jti": jti, "revoked_at": nil}).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if rowCount, err := result.RowsAffected(); err != nil || rowCount == 0 {
+		return nil, ErrTokenAlreadyRotated
+	}
+
+	query, args, err = r.insertQuery(newJTI, token)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenRow := new(entities.RefreshToken)
+	if err = tx.Get(tokenRow, query, args...); err != nil {
+		return nil, err
+	}
+
+	return tokenRow, tx.Commit()
+}
+
+func (r *tokenRepo) insertQuery(jti uuid.UUID, token *entities.NewRefreshToken) (string, []interface{}, error) {
+	familyId := jti
+	if token.FamilyId != nil {
+		familyId = *token.FamilyId
+	}
+
+	return r.Base.
+		Insert(
+			"refresh_tokens",
+			"jti",
+			"family_id",
+			"user_id",
+			"issued_at",
+			"expires_at",
+			"user_agent",
+			"ip_address",
+		).
+		Values(
+			jti,
+			familyId,
+			token.UserId,
+			time.Now().UTC(),
+			token.ExpiresAt.UTC(),
+			token.UserAgent,
+			token.IPAddress,
+		).
+		ToSql()
+}
+
+func (r *tokenRepo) Get(jti uuid.UUID) (*entities.RefreshToken, error) {
+	query, args, err := r.Base.
+		Select("refresh_tokens").
+		Where(sq.Eq{"jti": jti}).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	token := new(entities.RefreshToken)
+	return token, r.Base.DB.Get(token, query, args...)
+}
+
+func (r *tokenRepo) Revoke(jti uuid.UUID) error {
+	return r.revoke(sq.Eq{"jti": jti})
+}
+
+func (r *tokenRepo) RevokeFamily(familyId uuid.UUID) error {
+	return r.revoke(sq.Eq{"family_id": familyId})
+}
+
+// RevokeAll revokes refresh tokens and deletes personal access tokens of the user
+// in one transaction, so signing out everywhere also cuts off scripts and CI.
+func (r *tokenRepo) RevokeAll(userId uuid.UUID) error {
+	revokeQuery, revokeArgs, err := r.revokeQuery(sq.Eq{"user_id": userId})
+	if err != nil {
+		return err
+	}
+
+	deleteQuery, deleteArgs, err := r.Base.Q.
+		Delete("personal_access_tokens").
+		Where(sq.Eq{"user_id": userId}).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.Base.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err = tx.Exec(revokeQuery, revokeArgs...); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(deleteQuery, deleteArgs...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListSessions returns active token families, since tokens are rotated
+// on every refresh the latest token tells when the session was last used.
+func (r *tokenRepo) ListSessions(userId uuid.UUID) ([]entities.Session, error) {
+	query, args, err := r.Base.Q.
+		Select(
+			"family_id",
+			"user_id",
+			"(SELECT MIN(f.issued_at) FROM refresh_tokens f WHERE f.family_id = refresh_tokens.family_id) AS started_at",
+			"issued_at",
+			"expires_at",
+			"user_agent",
+			"ip_address",
+		).
+		From("refresh_tokens").
+		Where(sq.Eq{"user_id": userId, "revoked_at": nil}).
+		Where(sq.Gt{"expires_at": time.Now().UTC()}).
+		OrderBy("issued_at DESC").
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := new([]entities.Session)
+	return *sessions, r.Base.DB.Select(sessions, query, args...)
+}
+
+func (r *tokenRepo) RevokeSession(userId uuid.UUID, familyId uuid.UUID) (bool, error) {
+	rowCount, err := r.revokeCount(sq.Eq{"user_id": userId, "family_id": familyId})
+	return rowCount > 0, err
+}
+
+func (r *tokenRepo) revoke(wheres sq.Eq) error {
+	_, err := r.revokeCount(wheres)
+	return err
+}
+
+func (r *tokenRepo) revokeCount(wheres sq.Eq) (int64, error) {
+	query, args, err := r.revokeQuery(wheres)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.Base.DB.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *tokenRepo) revokeQuery(wheres sq.Eq) (string, []interface{}, error) {
+	return r.Base.Q.
+		Update("refresh_tokens").
+		Set("revoked_at", time.Now().UTC()).
+		Where(wheres).
+		Where(sq.Eq{"revoked_at": nil}).
+		ToSql()
+}
@@ -1,26 +1,82 @@
 package repo
 
 import (
+	"fmt"
 	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	"github.com/sultaniman/confetti/platform/entities"
+	"strings"
 	"time"
 )
 
+// UserFilterSpec filters users for admin listing, Search matches
+// beginning of e-mail or full name case-insensitively.
+type UserFilterSpec struct {
+	Search      string
+	IsActive    *bool
+	IsConfirmed *bool
+	IsAdmin     *bool
+	Provider    string
+	Limit       uint64
+	Offset      uint64
+}
+
+func (f *UserFilterSpec) where() sq.And {
+	filters := sq.Eq{}
+	if f.IsActive != nil {
+		filters["is_active"] = *f.IsActive
+	}
+
+	if f.IsConfirmed != nil {
+		filters["is_confirmed"] = *f.IsConfirmed
+	}
+
+	if f.IsAdmin != nil {
+		filters["is_admin"] = *f.IsAdmin
+	}
+
+	if f.Provider != "" {
+		filters["provider"] = f.Provider
+	}
+
+	conditions := sq.And{filters}
+	if f.Search != "" {
+		// matches ix_users_email and ix_users_full_name expressions
+		pattern := likeEscaper.Replace(strings.ToLower(f.Search)) + "%"
+		conditions = append(conditions, sq.Or{
+			sq.Like{"lower(email)": pattern},
+			sq.Like{"lower(full_name)": pattern},
+		})
+	}
+
+	return conditions
+}
+
 //go:generate mockgen -source=users.go -destination=../mocks/users.go -package=mocks
 type UserRepo interface {
 	Get(id uuid.UUID) (*entities.User, error)
 	GetByEmail(email string) (*entities.User, error)
+	List(filterSpec *UserFilterSpec) ([]entities.User, error)
+	Count(filterSpec *UserFilterSpec) (int, error)
 	Create(user *entities.NewUser) (*entities.User, error)
-	Delete(id uuid.UUID) (*entities.User, error)
+	Delete(id uuid.UUID, auditRecord *entities.NewAdminAuditRecord) (*entities.User, error)
 	Update(userId uuid.UUID, user *entities.UpdateUser) (*entities.User, error)
 	Exists(userId uuid.UUID) bool
 	EmailExists(email string) bool
 	UpdateEmail(userId uuid.UUID, newEmail string) (*entities.User, error)
 	UpdatePassword(userId uuid.UUID, newPassword string) (*entities.User, error)
-	ConfirmUser(userId uuid.UUID) (*entities.User, error)
+	ConfirmUser(userId uuid.UUID, auditRecord *entities.NewAdminAuditRecord) (*entities.User, error)
+	SetActive(userId uuid.UUID, isActive bool, auditRecord *entities.NewAdminAuditRecord) (*entities.User, error)
+	SetAdmin(userId uuid.UUID, isAdmin bool, auditRecord *entities.NewAdminAuditRecord) (*entities.User, error)
+	ScheduleDeletion(userId uuid.UUID, requestedAt time.Time) (*entities.User, error)
+	CancelDeletion(userId uuid.UUID) (*entities.User, error)
+	PurgeDeletionDue(requestedBefore time.Time, limit uint64) ([]entities.User, error)
+	AnonymizeDeletedAudit() (int64, error)
 	CreateActionCode(actionCodeRequest *entities.ActionCodeRequest) (*entities.ActionCode, error)
 	GetActionCode(actionCodeCheck *entities.ActionCodeCheck) (*entities.ActionCode, error)
+	ConsumeActionCode(actionCodeCheck *entities.ActionCodeCheck) (*entities.ActionCode, error)
+	DeleteExpiredActionCodes(now time.Time) (int64, error)
+	CommitEmailChange(userId uuid.UUID, newEmail string) (*entities.User, error)
 }
 
 type userRepo struct {
@@ -47,6 +103,45 @@ func (r *userRepo) Get(id uuid.UUID) (*entities.User, error) {
 	return user, r.Base.DB.Get(user, query, args...)
 }
 
+func (r *userRepo) List(filterSpec *UserFilterSpec) ([]entities.User, error) {
+	qs := r.Base.
+		Select("users").
+		Where(filterSpec.where()).
+		OrderBy("created_at DESC", "id DESC")
+
+	if filterSpec.Limit > 0 {
+		qs = qs.Limit(filterSpec.Limit)
+	}
+
+	if filterSpec.Offset > 0 {
+		qs = qs.Offset(filterSpec.Offset)
+	}
+
+	query, args, err := qs.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	users := new([]entities.User)
+	return *users, r.Base.DB.Select(users, query, args...)
+}
+
+// Count ignores pagination of filter spec
+func (r *userRepo) Count(filterSpec *UserFilterSpec) (int, error) {
+	query, args, err := r.Base.Q.
+		Select("COUNT(id)").
+		From("users").
+		Where(filterSpec.where()).
+		ToSql()
+
+	if err != nil {
+		return 0, err
+	}
+
+	userCount := 0
+	return userCount, r.Base.DB.Get(&userCount, query, args...)
+}
+
 func (r *userRepo) GetByEmail(email string) (*entities.User, error) {
 	query, args, err := r.Base.
 		Select("users").
@@ -186,11 +281,53 @@ func (r *userRepo) UpdatePassword(userId uuid.UUID, newPassword string) (*entiti
 	return userRow, r.Base.DB.Get(userRow, query, args...)
 }
 
-func (r *userRepo) ConfirmUser(userId uuid.UUID) (*entities.User, error) {
+// SetActive also cancels pending deletion when user is activated,
+// deactivated accounts can not be restored by their owner.
+func (r *userRepo) SetActive(userId uuid.UUID, isActive bool, auditRecord *entities.NewAdminAuditRecord) (*entities.User, error) {
+	qs := r.Base.
+		Update("users", true).
+		Where(sq.Eq{"id": userId}).
+		Set("is_active", isActive)
+
+	if isActive {
+		qs = qs.
+			Set("inactive_reason", nil).
+			Set("deletion_requested_at", nil)
+	} else {
+		qs = qs.Set("inactive_reason", string(entities.DeactivatedByAdmin))
+	}
+
+	query, args, err := qs.ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.updateWithAudit(query, args, auditRecord)
+}
+
+func (r *userRepo) SetAdmin(userId uuid.UUID, isAdmin bool, auditRecord *entities.NewAdminAuditRecord) (*entities.User, error) {
 	query, args, err := r.Base.
 		Update("users", true).
 		Where(sq.Eq{"id": userId}).
-		Set("is_confirmed", true).
+		Set("is_admin", isAdmin).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.updateWithAudit(query, args, auditRecord)
+}
+
+// ScheduleDeletion disables account until it is purged or restored
+func (r *userRepo) ScheduleDeletion(userId uuid.UUID, requestedAt time.Time) (*entities.User, error) {
+	query, args, err := r.Base.
+		Update("users", true).
+		Where(sq.Eq{"id": userId, "is_active": true, "deletion_requested_at": nil}).
+		Set("is_active", false).
+		Set("inactive_reason", string(entities.DeletionRequested)).
+		Set("deletion_requested_at", requestedAt.UTC()).
 		ToSql()
 
 	if err != nil {
@@ -201,24 +338,134 @@ func (r *userRepo) ConfirmUser(userId uuid.UUID) (*entities.User, error) {
 	return userRow, r.Base.DB.Get(userRow, query, args...)
 }
 
+// CancelDeletion only activates accounts which were disabled by deletion request
+func (r *userRepo) CancelDeletion(userId uuid.UUID) (*entities.User, error) {
+	query, args, err := r.Base.
+		Update("users", true).
+		Where(sq.Eq{"id": userId, "inactive_reason": string(entities.DeletionRequested)}).
+		Where(sq.NotEq{"deletion_requested_at": nil}).
+		Set("is_active", true).
+		Set("inactive_reason", nil).
+		Set("deletion_requested_at", nil).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	userRow := new(entities.User)
+	return userRow, r.Base.DB.Get(userRow, query, args...)
+}
+
+// PurgeDeletionDue hard deletes accounts which requested deletion before given time,
+// deletion of cards, action codes and sessions is cascaded by database.
+func (r *userRepo) PurgeDeletionDue(requestedBefore time.Time, limit uint64) ([]entities.User, error) {
+	dueQuery, dueArgs, err := sq.
+		Select("id").
+		From("users").
+		Where(sq.Lt{"deletion_requested_at": requestedBefore.UTC()}).
+		OrderBy("deletion_requested_at").
+		Limit(limit).
+		Suffix("FOR UPDATE SKIP LOCKED").
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := r.Base.Q.
+		Delete("users").
+		Where(sq.Expr(fmt.Sprintf("id IN (%s)", dueQuery), dueArgs...)).
+		Suffix("returning *").
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	users := new([]entities.User)
+	return *users, r.Base.DB.Select(users, query, args...)
+}
+
+// AnonymizeDeletedAudit clears IP address and user agent from card audit records
+// of users which no longer exist, records themselves are kept.
+func (r *userRepo) AnonymizeDeletedAudit() (int64, error) {
+	query, args, err := r.Base.Q.
+		Update("card_audit_log").
+		Set("ip_address", nil).
+		Set("user_agent", nil).
+		Where(sq.Or{sq.NotEq{"ip_address": nil}, sq.NotEq{"user_agent": nil}}).
+		Where("NOT EXISTS (SELECT 1 FROM users WHERE users.id = card_audit_log.actor_id)").
+		ToSql()
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.Base.DB.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *userRepo) ConfirmUser(userId uuid.UUID, auditRecord *entities.NewAdminAuditRecord) (*entities.User, error) {
+	query, args, err := r.Base.
+		Update("users", true).
+		Where(sq.Eq{"id": userId}).
+		Set("is_confirmed", true).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.updateWithAudit(query, args, auditRecord)
+}
+
+// CreateActionCode stores hash of new code and invalidates
+// previous codes of the same type issued to the user.
 func (r *userRepo) CreateActionCode(actionCodeRequest *entities.ActionCodeRequest) (*entities.ActionCode, error) {
 	user, err := r.GetByEmail(actionCodeRequest.Email)
 	if err != nil {
 		return nil, err
 	}
 
-	query, args, err := r.Base.
-		Insert(
-			actionCodeRequest.Type,
-			"user_id",
-			"code",
-			"created_at",
-		).
-		Values(
-			user.ID,
-			uuid.New().String(),
-			time.Now().UTC(),
-		).
+	tx, err := r.Base.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	table := string(actionCodeRequest.Type)
+	query, args, err := r.Base.Q.
+		Delete(table).
+		Where(sq.Eq{"user_id": user.ID}).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec(query, args...); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	columns := []string{"user_id", "code_hash", "created_at", "expires_at"}
+	values := []interface{}{user.ID, actionCodeRequest.CodeHash, now, now.Add(actionCodeRequest.TTL)}
+	if actionCodeRequest.NewEmail != "" {
+		columns = append(columns, "new_email")
+		values = append(values, actionCodeRequest.NewEmail)
+	}
+
+	query, args, err = r.Base.
+		Insert(table, columns...).
+		Values(values...).
 		ToSql()
 
 	if err != nil {
@@ -226,29 +473,144 @@ func (r *userRepo) CreateActionCode(actionCodeRequest *entities.ActionCodeReques
 	}
 
 	actionCode := new(entities.ActionCode)
-	return actionCode, r.Base.DB.Get(actionCode, query, args...)
+	if err = tx.Get(actionCode, query, args...); err != nil {
+		return nil, err
+	}
+
+	if actionCodeRequest.AuditRecord != nil {
+		query, args, err = insertAdminAuditRecord(r.Base, actionCodeRequest.AuditRecord).ToSql()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = tx.Exec(query, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	return actionCode, tx.Commit()
 }
 
+// GetActionCode returns valid code without using it,
+// returns sql.ErrNoRows if it is not valid.
 func (r *userRepo) GetActionCode(actionCodeCheck *entities.ActionCodeCheck) (*entities.ActionCode, error) {
-	query, args, err := r.Base.
+	qs := r.Base.
 		Select(string(actionCodeCheck.Type)).
-		Where(sq.Eq{"code": actionCodeCheck.Code}).
-		ToSql()
+		Where(sq.Eq{
+			"code_hash": actionCodeCheck.CodeHash,
+			"used_at":   nil,
+		}).
+		Where(sq.Gt{"expires_at": time.Now().UTC()})
+
+	if actionCodeCheck.UserId != nil {
+		qs = qs.Where(sq.Eq{"user_id": *actionCodeCheck.UserId})
+	}
 
+	query, args, err := qs.ToSql()
 	if err != nil {
 		return nil, err
 	}
 
 	actionCode := new(entities.ActionCode)
-	err = r.Base.DB.Get(actionCode, query, args...)
+	return actionCode, r.Base.DB.Get(actionCode, query, args...)
+}
+
+// ConsumeActionCode atomically marks code as used so that
+// it can be used only once, returns sql.ErrNoRows if it is not valid.
+func (r *userRepo) ConsumeActionCode(actionCodeCheck *entities.ActionCodeCheck) (*entities.ActionCode, error) {
+	now := time.Now().UTC()
+	qs := r.Base.
+		Update(string(actionCodeCheck.Type), false).
+		Where(sq.Eq{
+			"code_hash": actionCodeCheck.CodeHash,
+			"used_at":   nil,
+		}).
+		Where(sq.Gt{"expires_at": now}).
+		Set("used_at", now)
+
+	if actionCodeCheck.UserId != nil {
+		qs = qs.Where(sq.Eq{"user_id": *actionCodeCheck.UserId})
+	}
+
+	query, args, err := qs.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	actionCode := new(entities.ActionCode)
+	return actionCode, r.Base.DB.Get(actionCode, query, args...)
+}
+
+// DeleteExpiredActionCodes deletes expired codes of all types
+func (r *userRepo) DeleteExpiredActionCodes(now time.Time) (int64, error) {
+	var deleted int64
+	for _, codeType := range entities.ActionCodeTypes {
+		query, args, err := r.Base.Q.
+			Delete(string(codeType)).
+			Where(sq.LtOrEq{"expires_at": now.UTC()}).
+			ToSql()
+
+		if err != nil {
+			return deleted, err
+		}
+
+		result, err := r.Base.DB.Exec(query, args...)
+		if err != nil {
+			return deleted, err
+		}
+
+		rows, _ := result.RowsAffected()
+		deleted += rows
+	}
+
+	return deleted, nil
+}
+
+// CommitEmailChange sets verified email and drops all pending email changes of user,
+// following the link proves ownership of the new address so user becomes confirmed.
+func (r *userRepo) CommitEmailChange(userId uuid.UUID, newEmail string) (*entities.User, error) {
+	tx, err := r.Base.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query, args, err := r.Base.
+		Update("users", true).
+		Where(sq.Eq{"id": userId}).
+		Set("email", newEmail).
+		Set("is_confirmed", true).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	userRow := new(entities.User)
+	if err = tx.Get(userRow, query, args...); err != nil {
+		return nil, err
+	}
+
+	query, args, err = r.Base.Q.
+		Delete(string(entities.EmailChanges)).
+		Where(sq.Eq{"user_id": userId}).
+		ToSql()
+
 	if err != nil {
 		return nil, err
 	}
 
-	return actionCode, nil
+	if _, err = tx.Exec(query, args...); err != nil {
+		return nil, err
+	}
+
+	return userRow, tx.Commit()
 }
 
-func (r *userRepo) Delete(id uuid.UUID) (*entities.User, error) {
+func (r *userRepo) Delete(id uuid.UUID, auditRecord *entities.NewAdminAuditRecord) (*entities.User, error) {
 	query, args, err := r.Base.
 		Delete("users", sq.Eq{"id": id}).
 		ToSql()
@@ -257,6 +619,38 @@ func (r *userRepo) Delete(id uuid.UUID) (*entities.User, error) {
 		return nil, err
 	}
 
-	user := new(entities.User)
-	return user, r.Base.DB.Get(user, query, args...)
+	return r.updateWithAudit(query, args, auditRecord)
+}
+
+// updateWithAudit runs user update and when audit record is given
+// writes it in the same transaction, so admin actions are never left unrecorded.
+func (r *userRepo) updateWithAudit(query string, args []interface{}, auditRecord *entities.NewAdminAuditRecord) (*entities.User, error) {
+	userRow := new(entities.User)
+	if auditRecord == nil {
+		return userRow, r.Base.DB.Get(userRow, query, args...)
+	}
+
+	tx, err := r.Base.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err = tx.Get(userRow, query, args...); err != nil {
+		return nil, err
+	}
+
+	auditQuery, auditArgs, err := insertAdminAuditRecord(r.Base, auditRecord).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec(auditQuery, auditArgs...); err != nil {
+		return nil, err
+	}
+
+	return userRow, tx.Commit()
 }
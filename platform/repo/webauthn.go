@@ -0,0 +1,200 @@
+package repo
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sultaniman/confetti/platform/entities"
+	"time"
+)
+
+//go:generate mockgen -source=webauthn.go -destination=../mocks/webauthn.go -package=mocks
+type WebAuthnRepo interface {
+	CreateChallenge(challenge *entities.NewWebAuthnChallenge) error
+	ConsumeChallenge(challenge string, ceremony entities.WebAuthnCeremony) (*entities.WebAuthnChallenge, error)
+	CreateCredential(credential *entities.NewWebAuthnCredential) (*entities.WebAuthnCredential, error)
+	GetCredential(credentialId string) (*entities.WebAuthnCredential, error)
+	ListCredentials(userId uuid.UUID) ([]entities.WebAuthnCredential, error)
+	UpdateSignCount(id uuid.UUID, signCount uint32) error
+	DeleteCredential(userId uuid.UUID, id uuid.UUID) (bool, error)
+	HasCredentials(userId uuid.UUID) bool
+}
+
+type webAuthnRepo struct {
+	Base *Repo
+}
+
+func NewWebAuthnRepo(base *Repo) WebAuthnRepo {
+	return &webAuthnRepo{
+		Base: base,
+	}
+}
+
+// CreateChallenge stores pending ceremony and cleans up abandoned ones
+func (r *webAuthnRepo) CreateChallenge(challenge *entities.NewWebAuthnChallenge) error {
+	now := time.Now().UTC()
+	query, args, err := r.Base.Q.
+		Delete("webauthn_challenges").
+		Where(sq.Lt{"expires_at": now}).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = r.Base.DB.Exec(query, args...); err != nil {
+		return err
+	}
+
+	query, args, err = r.Base.
+		Insert(
+			"webauthn_challenges",
+			"challenge",
+			"user_id",
+			"ceremony",
+			"expires_at",
+			"created_at",
+		).
+		Values(
+			challenge.Challenge,
+			challenge.UserId,
+			string(challenge.Ceremony),
+			challenge.ExpiresAt.UTC(),
+			now,
+		).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Base.DB.Exec(query, args...)
+	return err
+}
+
+// ConsumeChallenge removes challenge so that it can be used only once
+func (r *webAuthnRepo) ConsumeChallenge(challenge string, ceremony entities.WebAuthnCeremony) (*entities.WebAuthnChallenge, error) {
+	query, args, err := r.Base.
+		Delete("webauthn_challenges", sq.Eq{"challenge": challenge, "ceremony": string(ceremony)}).
+		Where(sq.Gt{"expires_at": time.Now().UTC()}).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	challengeRow := new(entities.WebAuthnChallenge)
+	return challengeRow, r.Base.DB.Get(challengeRow, query, args...)
+}
+
+func (r *webAuthnRepo) CreateCredential(credential *entities.NewWebAuthnCredential) (*entities.WebAuthnCredential, error) {
+	query, args, err := r.Base.
+		Insert(
+			"webauthn_credentials",
+			"user_id",
+			"name",
+			"credential_id",
+			"public_key",
+			"aaguid",
+			"sign_count",
+			"created_at",
+		).
+		Values(
+			credential.UserId,
+			credential.Name,
+			credential.CredentialId,
+			credential.PublicKey,
+			credential.AAGUID,
+			credential.SignCount,
+			time.Now().UTC(),
+		).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	credentialRow := new(entities.WebAuthnCredential)
+	return credentialRow, r.Base.DB.Get(credentialRow, query, args...)
+}
+
+func (r *webAuthnRepo) GetCredential(credentialId string) (*entities.WebAuthnCredential, error) {
+	query, args, err := r.Base.
+		Select("webauthn_credentials").
+		Where(sq.Eq{"credential_id": credentialId}).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	credential := new(entities.WebAuthnCredential)
+	return credential, r.Base.DB.Get(credential, query, args...)
+}
+
+func (r *webAuthnRepo) ListCredentials(userId uuid.UUID) ([]entities.WebAuthnCredential, error) {
+	query, args, err := r.Base.
+		Select("webauthn_credentials").
+		Where(sq.Eq{"user_id": userId}).
+		OrderBy("created_at DESC").
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := new([]entities.WebAuthnCredential)
+	return *credentials, r.Base.DB.Select(credentials, query, args...)
+}
+
+func (r *webAuthnRepo) UpdateSignCount(id uuid.UUID, signCount uint32) error {
+	query, args, err := r.Base.Q.
+		Update("webauthn_credentials").
+		Set("sign_count", signCount).
+		Set("last_used_at", time.Now().UTC()).
+		Where(sq.Eq{"id": id}).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Base.DB.Exec(query, args...)
+	return err
+}
+
+func (r *webAuthnRepo) DeleteCredential(userId uuid.UUID, id uuid.UUID) (bool, error) {
+	query, args, err := r.Base.Q.
+		Delete("webauthn_credentials").
+		Where(sq.Eq{"id": id, "user_id": userId}).
+		ToSql()
+
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.Base.DB.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	rowCount, err := result.RowsAffected()
+	return rowCount > 0, err
+}
+
+func (r *webAuthnRepo) HasCredentials(userId uuid.UUID) bool {
+	query, args, err := r.Base.
+		Count("webauthn_credentials", sq.Eq{"user_id": userId}).
+		ToSql()
+
+	if err != nil {
+		return false
+	}
+
+	credentialCount := 0
+	err = r.Base.DB.Get(&credentialCount, query, args...)
+	if err != nil {
+		return false
+	}
+
+	return credentialCount > 0
+}
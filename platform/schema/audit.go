@@ -0,0 +1,36 @@
+package schema
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// AuditContext describes who performs an action and from where
+type AuditContext struct {
+	ActorId   uuid.UUID
+	RequestId string
+	IPAddress string
+	UserAgent string
+}
+
+type CardAuditResponse struct {
+	ID        uuid.UUID
+	ActorId   uuid.UUID
+	CardId    uuid.UUID
+	Action    string
+	RequestId string
+	IPAddress string
+	UserAgent string
+	CreatedAt time.Time
+}
+
+type AdminAuditResponse struct {
+	ID        uuid.UUID
+	AdminId   *uuid.UUID
+	UserId    uuid.UUID
+	Action    string
+	RequestId string
+	IPAddress string
+	UserAgent string
+	CreatedAt time.Time
+}
@@ -1,5 +1,10 @@
 package schema
 
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
 type LoginRequest struct {
 	Email    string
 	Password string
@@ -14,6 +19,10 @@ type ResetPasswordRequest struct {
 	Email string
 }
 
+type MagicLinkRequest struct {
+	Email string
+}
+
 type NewPasswordRequest struct {
 	Password string
 }
@@ -23,4 +32,27 @@ type TokenResponse struct {
 	TokenType    string
 	ExpiresIn    int
 	RefreshToken string
+	// set instead of tokens when the second authentication step is required
+	MFAToken   string   `json:",omitempty"`
+	MFAMethods []string `json:",omitempty"`
+}
+
+type SessionResponse struct {
+	ID         uuid.UUID
+	UserAgent  string
+	IPAddress  string
+	Current    bool
+	StartedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+}
+
+type StepUpRequest struct {
+	Password string
+	Code     string
+}
+
+// NarrowTokenRequest lists scopes of narrowed access token
+type NarrowTokenRequest struct {
+	Scopes []string
 }
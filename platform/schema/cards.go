@@ -46,3 +46,27 @@ type PlainCardResponse struct {
 	Data  string
 	Key   string
 }
+
+type KeyUsage struct {
+	KeyID string
+	Cards int
+}
+
+type CardListRequest struct {
+	Limit         uint64
+	Cursor        string
+	Title         string
+	TitlePrefix   bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	Sort          string
+	Ascending     bool
+}
+
+type CardListResponse struct {
+	Cards      []CardResponse
+	NextCursor string
+	TotalCount int
+}
@@ -0,0 +1,24 @@
+package schema
+
+type TOTPEnrollmentResponse struct {
+	Secret string
+	URI    string
+}
+
+type RecoveryCodesResponse struct {
+	Codes []string
+}
+
+type TOTPCodeRequest struct {
+	Code string
+}
+
+type DisableTOTPRequest struct {
+	Password string
+	Code     string
+}
+
+type MFALoginRequest struct {
+	MFAToken string
+	Code     string
+}
@@ -0,0 +1,12 @@
+package schema
+
+// OIDCCallbackRequest is provider redirect, StateHash comes from state cookie
+type OIDCCallbackRequest struct {
+	Code      string
+	State     string
+	StateHash string
+}
+
+type OIDCProvidersResponse struct {
+	Providers []string
+}
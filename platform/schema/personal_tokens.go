@@ -0,0 +1,35 @@
+package schema
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type NewPersonalTokenRequest struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+type PersonalTokenResponse struct {
+	ID         uuid.UUID
+	Name       string
+	Prefix     string
+	Scopes     []string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// NewPersonalTokenResponse is the only response which contains the token
+type NewPersonalTokenResponse struct {
+	PersonalTokenResponse
+	Token string
+}
+
+// PersonalTokenClaims describe authenticated personal access token
+type PersonalTokenClaims struct {
+	TokenId uuid.UUID
+	UserId  uuid.UUID
+	Scopes  []string
+}
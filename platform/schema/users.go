@@ -3,6 +3,7 @@ package schema
 import (
 	"encoding/json"
 	"github.com/google/uuid"
+	"github.com/sultaniman/confetti/platform/webauthn"
 	"time"
 )
 
@@ -41,6 +42,40 @@ type UserResponse struct {
 	Password    string          `json:"-"`
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+	// DeletionRequestedAt is set while account awaits purge
+	DeletionRequestedAt *time.Time
+	InactiveReason      *string
+}
+
+type DeleteAccountRequest struct {
+	Password string
+}
+
+// RestoreAccountRequest is either e-mail and password, challenge token with verification
+// Code or passkey Credential as the second step, or passkey Credential alone.
+type RestoreAccountRequest struct {
+	Email      string
+	Password   string
+	MFAToken   string
+	Code       string
+	Credential *webauthn.AssertionResponse
+}
+
+type RestoreAccountResponse struct {
+	User *UserResponse `json:",omitempty"`
+	// set instead of user when the second authentication step is required
+	MFAToken   string   `json:",omitempty"`
+	MFAMethods []string `json:",omitempty"`
+}
+
+type UserListRequest struct {
+	Search      string
+	IsActive    *bool
+	IsConfirmed *bool
+	IsAdmin     *bool
+	Provider    string
+	Limit       uint64
+	Offset      uint64
 }
 
 type UsersResponse struct {
@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"github.com/google/uuid"
+	"github.com/sultaniman/confetti/platform/webauthn"
+	"time"
+)
+
+// WebAuthnRegistrationRequest carries verification Code when two-factor authentication is enabled
+type WebAuthnRegistrationRequest struct {
+	Name       string
+	Code       string
+	Credential webauthn.RegistrationResponse
+}
+
+type DeleteWebAuthnCredentialRequest struct {
+	Code string
+}
+
+type WebAuthnCredentialResponse struct {
+	ID         uuid.UUID
+	Name       string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+type WebAuthnLoginRequest struct {
+	Credential webauthn.AssertionResponse
+}
+
+type WebAuthnMFABeginRequest struct {
+	MFAToken string
+}
+
+type WebAuthnMFARequest struct {
+	MFAToken   string
+	Credential webauthn.AssertionResponse
+}
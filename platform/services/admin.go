@@ -0,0 +1,256 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/sultaniman/confetti/platform/entities"
+	"github.com/sultaniman/confetti/platform/http"
+	"github.com/sultaniman/confetti/platform/mailer"
+	"github.com/sultaniman/confetti/platform/repo"
+	"github.com/sultaniman/confetti/platform/schema"
+)
+
+// AdminService performs user lifecycle actions on behalf of admins,
+// every successful action is recorded in admin audit trail.
+type AdminService interface {
+	Deactivate(userId uuid.UUID, auditContext *schema.AuditContext) (*schema.UserResponse, error)
+	Activate(userId uuid.UUID, auditContext *schema.AuditContext) (*schema.UserResponse, error)
+	GrantAdmin(userId uuid.UUID, auditContext *schema.AuditContext) (*schema.UserResponse, error)
+	RevokeAdmin(userId uuid.UUID, auditContext *schema.AuditContext) (*schema.UserResponse, error)
+	Confirm(userId uuid.UUID, auditContext *schema.AuditContext) (*schema.UserResponse, error)
+	SendPasswordReset(userId uuid.UUID, auditContext *schema.AuditContext) error
+	ClearLockout(userId uuid.UUID, auditContext *schema.AuditContext) error
+	Delete(userId uuid.UUID, auditContext *schema.AuditContext) (*schema.UserResponse, error)
+	Audit(userId *uuid.UUID, limit uint64) ([]schema.AdminAuditResponse, error)
+}
+
+type adminService struct {
+	usersService   UserService
+	lockoutService LockoutService
+	usersRepo      repo.UserRepo
+	tokensRepo     repo.TokenRepo
+	auditRepo      repo.AdminAuditRepo
+	mailHandler    mailer.Mailer
+}
+
+func NewAdminService(
+	usersService UserService,
+	lockoutService LockoutService,
+	usersRepo repo.UserRepo,
+	tokensRepo repo.TokenRepo,
+	auditRepo repo.AdminAuditRepo,
+	mailHandler mailer.Mailer,
+) AdminService {
+	return &adminService{
+		usersService:   usersService,
+		lockoutService: lockoutService,
+		usersRepo:      usersRepo,
+		tokensRepo:     tokensRepo,
+		auditRepo:      auditRepo,
+		mailHandler:    mailHandler,
+	}
+}
+
+// Deactivate blocks sign in and signs user out from all devices,
+// already issued access tokens stay valid until they expire.
+func (a *adminService) Deactivate(userId uuid.UUID, auditContext *schema.AuditContext) (*schema.UserResponse, error) {
+	if userId == auditContext.ActorId {
+		return nil, http.BadRequestWithMessage("You can not deactivate yourself")
+	}
+
+	if _, err := a.usersRepo.SetActive(userId, false, newAdminAuditRecord(userId, entities.UserDeactivated, auditContext)); err != nil {
+		return nil, a.handleError(err)
+	}
+
+	if err := a.revokeSessions(userId); err != nil {
+		return nil, err
+	}
+
+	return a.recorded(userId, entities.UserDeactivated, auditContext)
+}
+
+func (a *adminService) Activate(userId uuid.UUID, auditContext *schema.AuditContext) (*schema.UserResponse, error) {
+	if _, err := a.usersRepo.SetActive(userId, true, newAdminAuditRecord(userId, entities.UserActivated, auditContext)); err != nil {
+		return nil, a.handleError(err)
+	}
+
+	return a.recorded(userId, entities.UserActivated, auditContext)
+}
+
+func (a *adminService) GrantAdmin(userId uuid.UUID, auditContext *schema.AuditContext) (*schema.UserResponse, error) {
+	if _, err := a.usersRepo.SetAdmin(userId, true, newAdminAuditRecord(userId, entities.AdminGranted, auditContext)); err != nil {
+		return nil, a.handleError(err)
+	}
+
+	return a.recorded(userId, entities.AdminGranted, auditContext)
+}
+
+// RevokeAdmin also revokes sessions so that role claim is
+// not carried over to access tokens minted by refresh.
+func (a *adminService) RevokeAdmin(userId uuid.UUID, auditContext *schema.AuditContext) (*schema.UserResponse, error) {
+	if userId == auditContext.ActorId {
+		return nil, http.BadRequestWithMessage("You can not revoke your own admin role")
+	}
+
+	if _, err := a.usersRepo.SetAdmin(userId, false, newAdminAuditRecord(userId, entities.AdminRevoked, auditContext)); err != nil {
+		return nil, a.handleError(err)
+	}
+
+	if err := a.revokeSessions(userId); err != nil {
+		return nil, err
+	}
+
+	return a.recorded(userId, entities.AdminRevoked, auditContext)
+}
+
+func (a *adminService) Confirm(userId uuid.UUID, auditContext *schema.AuditContext) (*schema.UserResponse, error) {
+	if _, err := a.usersRepo.ConfirmUser(userId, newAdminAuditRecord(userId, entities.UserForceConfirm, auditContext)); err != nil {
+		return nil, a.handleError(err)
+	}
+
+	return a.recorded(userId, entities.UserForceConfirm, auditContext)
+}
+
+func (a *adminService) SendPasswordReset(userId uuid.UUID, auditContext *schema.AuditContext) error {
+	user, err := a.usersService.Get(userId)
+	if err != nil {
+		return err
+	}
+
+	passwordReset, err := a.usersService.ResetPasswordRequest(user.Email, newAdminAuditRecord(userId, entities.PasswordResetSent, auditContext))
+	if err != nil {
+		return err
+	}
+
+	a.logAction(userId, entities.PasswordResetSent, auditContext)
+
+	if err = a.mailHandler.SendPasswordResetCode(user.Email, passwordReset.Code); err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to send password reset email")
+
+		return http.InternalError(err)
+	}
+
+	return nil
+}
+
+func (a *adminService) ClearLockout(userId uuid.UUID, auditContext *schema.AuditContext) error {
+	if err := a.lockoutService.Clear(userId, newAdminAuditRecord(userId, entities.LockoutCleared, auditContext)); err != nil {
+		return err
+	}
+
+	a.logAction(userId, entities.LockoutCleared, auditContext)
+	return nil
+}
+
+// Delete permanently deletes user right away without grace period,
+// audit records of admin actions are kept since they do not reference users table.
+func (a *adminService) Delete(userId uuid.UUID, auditContext *schema.AuditContext) (*schema.UserResponse, error) {
+	if userId == auditContext.ActorId {
+		return nil, http.BadRequestWithMessage("You can not delete yourself")
+	}
+
+	user, err := a.usersService.Delete(userId, newAdminAuditRecord(userId, entities.UserDeleted, auditContext))
+	if err != nil {
+		return nil, err
+	}
+
+	a.logAction(userId, entities.UserDeleted, auditContext)
+
+	if err = a.mailHandler.SendAccountDeleted(user.Email); err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to send account deletion confirmation")
+	}
+
+	return user, nil
+}
+
+func (a *adminService) Audit(userId *uuid.UUID, limit uint64) ([]schema.AdminAuditResponse, error) {
+	records, err := a.auditRepo.List(&repo.AdminAuditFilterSpec{
+		UserId: userId,
+		Limit:  limit,
+	})
+
+	if err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	var auditResponse []schema.AdminAuditResponse
+	for _, record := range records {
+		recordResponse := schema.AdminAuditResponse{
+			ID:        record.ID,
+			AdminId:   record.AdminId,
+			UserId:    record.UserId,
+			Action:    record.Action,
+			CreatedAt: record.CreatedAt,
+		}
+
+		if record.RequestId != nil {
+			recordResponse.RequestId = *record.RequestId
+		}
+
+		if record.IPAddress != nil {
+			recordResponse.IPAddress = *record.IPAddress
+		}
+
+		if record.UserAgent != nil {
+			recordResponse.UserAgent = *record.UserAgent
+		}
+
+		auditResponse = append(auditResponse, recordResponse)
+	}
+
+	return auditResponse, nil
+}
+
+// recorded logs admin action which was recorded together with user change
+func (a *adminService) recorded(userId uuid.UUID, action entities.AdminAction, auditContext *schema.AuditContext) (*schema.UserResponse, error) {
+	a.logAction(userId, action, auditContext)
+	return a.usersService.Get(userId)
+}
+
+func (a *adminService) logAction(userId uuid.UUID, action entities.AdminAction, auditContext *schema.AuditContext) {
+	log.Info().
+		Str("admin_id", auditContext.ActorId.String()).
+		Str("user_id", userId.String()).
+		Str("action", string(action)).
+		Msg("Admin action performed")
+}
+
+func newAdminAuditRecord(userId uuid.UUID, action entities.AdminAction, auditContext *schema.AuditContext) *entities.NewAdminAuditRecord {
+	return &entities.NewAdminAuditRecord{
+		AdminId:   auditContext.ActorId,
+		UserId:    userId,
+		Action:    action,
+		RequestId: auditContext.RequestId,
+		IPAddress: auditContext.IPAddress,
+		UserAgent: auditContext.UserAgent,
+	}
+}
+
+func (a *adminService) revokeSessions(userId uuid.UUID) error {
+	if err := a.tokensRepo.RevokeAll(userId); err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to revoke refresh tokens")
+
+		return http.InternalError(err)
+	}
+
+	return nil
+}
+
+func (a *adminService) handleError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.NotFoundError("User not found")
+	} else {
+		return http.InternalError(err)
+	}
+}
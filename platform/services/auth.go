@@ -7,32 +7,63 @@ import (
 	"github.com/lestrrat-go/jwx/jwt"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
+	"github.com/sultaniman/confetti/platform/entities"
 	"github.com/sultaniman/confetti/platform/http"
 	"github.com/sultaniman/confetti/platform/mailer"
 	"github.com/sultaniman/confetti/platform/schema"
+	"github.com/sultaniman/confetti/platform/webauthn"
 	"github.com/sultaniman/confetti/util"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type AuthService interface {
 	AccessTokenAuthFlow(ctx *fiber.Ctx, loginRequest *schema.LoginRequest) (*schema.TokenResponse, error)
+	MFAAuthFlow(ctx *fiber.Ctx, mfaRequest *schema.MFALoginRequest) (*schema.TokenResponse, error)
+	WebAuthnLoginBegin() (*webauthn.RequestOptions, error)
+	WebAuthnLoginFlow(ctx *fiber.Ctx, loginRequest *schema.WebAuthnLoginRequest) (*schema.TokenResponse, error)
+	WebAuthnMFABegin(mfaRequest *schema.WebAuthnMFABeginRequest) (*webauthn.RequestOptions, error)
+	WebAuthnMFAFlow(ctx *fiber.Ctx, mfaRequest *schema.WebAuthnMFARequest) (*schema.TokenResponse, error)
 	RefreshAuthToken(ctx *fiber.Ctx) (*schema.TokenResponse, error)
 	Register(registerPayload *schema.RegisterRequest) error
 	ResetPasswordRequest(resetPasswordPayload *schema.ResetPasswordRequest) error
+	MagicLinkRequest(ctx *fiber.Ctx, magicLinkPayload *schema.MagicLinkRequest) error
+	MagicLinkAuthFlow(ctx *fiber.Ctx, code string) (*schema.TokenResponse, error)
+	OIDCAuthFlow(ctx *fiber.Ctx, providerName string, callback *schema.OIDCCallbackRequest) (*schema.TokenResponse, error)
+	StepUp(ctx *fiber.Ctx, userId uuid.UUID, stepUpRequest *schema.StepUpRequest) (*schema.TokenResponse, error)
+	RestoreAccount(ctx *fiber.Ctx, restoreRequest *schema.RestoreAccountRequest) (*schema.RestoreAccountResponse, error)
 	Logout(ctx *fiber.Ctx) error
 }
 
 type authService struct {
-	usersService UserService
-	jwxService   *JWXService
-	mailHandler  mailer.Mailer
+	usersService    UserService
+	mfaService      MFAService
+	webAuthnService WebAuthnService
+	oidcService     OIDCService
+	lockoutService  LockoutService
+	jwxService      *JWXService
+	mailHandler     mailer.Mailer
 }
 
-func NewAuthService(usersService UserService, jwxService *JWXService, mailHandler mailer.Mailer) AuthService {
+func NewAuthService(
+	usersService UserService,
+	mfaService MFAService,
+	webAuthnService WebAuthnService,
+	oidcService OIDCService,
+	lockoutService LockoutService,
+	jwxService *JWXService,
+	mailHandler mailer.Mailer,
+) AuthService {
 	return &authService{
-		usersService: usersService,
-		jwxService:   jwxService,
-		mailHandler:  mailHandler,
+		usersService:    usersService,
+		mfaService:      mfaService,
+		webAuthnService: webAuthnService,
+		oidcService:     oidcService,
+		lockoutService:  lockoutService,
+		jwxService:      jwxService,
+		mailHandler:     mailHandler,
 	}
 }
 
@@ -45,8 +76,13 @@ func (a *authService) AccessTokenAuthFlow(ctx *fiber.Ctx, loginRequest *schema.L
 		return nil, http.BadRequestWithMessage("Please provide password")
 	}
 
+	if err := a.checkLockout(ctx, loginRequest.Email); err != nil {
+		return nil, err
+	}
+
 	user, err := a.usersService.GetByEmail(loginRequest.Email)
 	if err != nil {
+		a.lockoutService.RegisterFailure(loginRequest.Email, ctx.IP())
 		return nil, http.UnauthorizedError("Wrong e-mail or password")
 	}
 
@@ -56,86 +92,505 @@ func (a *authService) AccessTokenAuthFlow(ctx *fiber.Ctx, loginRequest *schema.L
 
 	err = util.CheckPassword(user.Password, loginRequest.Password)
 	if err != nil {
+		a.lockoutService.RegisterFailure(loginRequest.Email, ctx.IP())
 		return nil, http.UnauthorizedError("Wrong e-mail or password")
 	}
 
-	// issue access_token (short-lived) and refresh_token (to update it)
-	now := time.Now()
-	refreshToken := jwt.New()
-	refreshTokenDuration := viper.GetDuration("refresh_token_ttl")
-	err = refreshToken.Set(jwt.ExpirationKey, now.Add(refreshTokenDuration))
+	// with two-factor authentication tokens are issued only after the second step
+	if methods := a.mfaMethods(user.ID); len(methods) > 0 {
+		return a.mfaChallenge(user, methods, []string{AMRPassword})
+	}
+
+	return a.issueTokens(ctx, user, []string{AMRPassword})
+}
+
+// MFAAuthFlow is the second step of authentication which exchanges
+// challenge token and verification code for access tokens.
+func (a *authService) MFAAuthFlow(ctx *fiber.Ctx, mfaRequest *schema.MFALoginRequest) (*schema.TokenResponse, error) {
+	userId, firstFactor, err := a.jwxService.ParseMFAToken(mfaRequest.MFAToken)
 	if err != nil {
-		log.Info().
-			Str("user_id", user.ID.String()).
-			Msg(fmt.Sprintf("JWT Refresh token unable to set %s", jwt.ExpirationKey))
+		return nil, err
 	}
 
-	err = refreshToken.Set(jwt.SubjectKey, user.ID.String())
+	user, err := a.usersService.Get(*userId)
 	if err != nil {
-		log.Info().
-			Str("user_id", user.ID.String()).
-			Msg(fmt.Sprintf("JWT Refresh token unable to set %s", jwt.SubjectKey))
+		return nil, http.UnauthorizedError("Invalid challenge token")
+	}
+
+	if !user.IsActive {
+		return nil, http.InactiveUserError()
+	}
+
+	if err = a.checkLockout(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	if err = a.mfaService.Verify(user.ID, mfaRequest.Code); err != nil {
+		a.lockoutService.RegisterFailure(user.Email, ctx.IP())
+		return nil, err
+	}
+
+	return a.issueTokens(ctx, user, multiFactorAMR(firstFactor, AMROTP))
+}
+
+// WebAuthnLoginBegin starts passwordless login with a discoverable passkey
+func (a *authService) WebAuthnLoginBegin() (*webauthn.RequestOptions, error) {
+	return a.webAuthnService.BeginLogin(nil)
+}
+
+// WebAuthnLoginFlow exchanges passkey assertion for access tokens, passkeys
+// verify the user on their own so no second factor is required.
+func (a *authService) WebAuthnLoginFlow(ctx *fiber.Ctx, loginRequest *schema.WebAuthnLoginRequest) (*schema.TokenResponse, error) {
+	userId, err := a.passkeyOwner(ctx, &loginRequest.Credential)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.webAuthnTokens(ctx, *userId, nil, &loginRequest.Credential, []string{AMRHardwareKey})
+}
+
+// WebAuthnMFABegin starts passkey assertion as a second step of authentication
+func (a *authService) WebAuthnMFABegin(mfaRequest *schema.WebAuthnMFABeginRequest) (*webauthn.RequestOptions, error) {
+	userId, _, err := a.jwxService.ParseMFAToken(mfaRequest.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.webAuthnService.BeginLogin(userId)
+}
+
+// WebAuthnMFAFlow is the second step of authentication which exchanges
+// challenge token and passkey assertion for access tokens.
+func (a *authService) WebAuthnMFAFlow(ctx *fiber.Ctx, mfaRequest *schema.WebAuthnMFARequest) (*schema.TokenResponse, error) {
+	userId, firstFactor, err := a.jwxService.ParseMFAToken(mfaRequest.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.webAuthnTokens(ctx, *userId, userId, &mfaRequest.Credential, multiFactorAMR(firstFactor, AMRHardwareKey))
+}
+
+// isRestorable tells if account was disabled by deletion request of its owner
+func isRestorable(user *schema.UserResponse) bool {
+	return user.DeletionRequestedAt != nil &&
+		user.InactiveReason != nil &&
+		*user.InactiveReason == string(entities.DeletionRequested)
+}
+
+// multiFactorAMR merges methods of both authentication steps
+func multiFactorAMR(firstFactor []string, secondFactor string) []string {
+	amr := append([]string{}, firstFactor...)
+	for _, method := range []string{secondFactor, AMRMultiFactor} {
+		if !hasScope(amr, method) {
+			amr = append(amr, method)
+		}
+	}
+
+	return amr
+}
+
+func (a *authService) webAuthnTokens(
+	ctx *fiber.Ctx,
+	userId uuid.UUID,
+	mfaUserId *uuid.UUID,
+	assertion *webauthn.AssertionResponse,
+	amr []string,
+) (*schema.TokenResponse, error) {
+	user, err := a.passkeyUser(ctx, userId, mfaUserId, assertion)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsActive {
+		return nil, http.InactiveUserError()
+	}
+
+	return a.issueTokens(ctx, user, amr)
+}
+
+// passkeyOwner finds user of passwordless passkey assertion,
+// unknown passkeys count as failures of the client address.
+func (a *authService) passkeyOwner(ctx *fiber.Ctx, assertion *webauthn.AssertionResponse) (*uuid.UUID, error) {
+	if err := a.checkLockout(ctx, ""); err != nil {
+		return nil, err
+	}
+
+	userId, err := a.webAuthnService.CredentialOwner(assertion)
+	if err != nil {
+		a.lockoutService.RegisterFailure("", ctx.IP())
+		return nil, err
+	}
+
+	return userId, nil
+}
+
+// passkeyUser verifies passkey assertion of the user under the same lockout
+// rules as other sign in steps, mfaUserId is set when passkey is a second factor.
+func (a *authService) passkeyUser(
+	ctx *fiber.Ctx,
+	userId uuid.UUID,
+	mfaUserId *uuid.UUID,
+	assertion *webauthn.AssertionResponse,
+) (*schema.UserResponse, error) {
+	user, err := a.usersService.Get(userId)
+	if err != nil {
+		return nil, http.UnauthorizedError("User not found")
+	}
+
+	if err = a.checkLockout(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	if _, err = a.webAuthnService.FinishLogin(mfaUserId, assertion); err != nil {
+		a.lockoutService.RegisterFailure(user.Email, ctx.IP())
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// checkLockout rejects attempts while account or client address is throttled
+func (a *authService) checkLockout(ctx *fiber.Ctx, email string) error {
+	retryAfter, err := a.lockoutService.Check(email, ctx.IP())
+	if err != nil {
+		if retryAfter > 0 {
+			ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// mfaMethods lists second factors available to the user
+func (a *authService) mfaMethods(userId uuid.UUID) []string {
+	var methods []string
+	if a.mfaService.IsEnabled(userId) {
+		methods = append(methods, MFAMethodTOTP, MFAMethodRecoveryCode)
+	}
+
+	if a.webAuthnService.HasCredentials(userId) {
+		methods = append(methods, MFAMethodWebAuthn)
 	}
 
+	return methods
+}
+
+// mfaChallenge issues challenge token which remembers how the first step was passed
+func (a *authService) mfaChallenge(user *schema.UserResponse, methods []string, amr []string) (*schema.TokenResponse, error) {
+	mfaToken, err := a.jwxService.IssueMFAToken(user.ID, amr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema.TokenResponse{
+		TokenType:  "MFA",
+		ExpiresIn:  int(viper.GetDuration("mfa_token_ttl").Seconds()),
+		MFAToken:   mfaToken,
+		MFAMethods: methods,
+	}, nil
+}
+
+func (a *authService) issueTokens(ctx *fiber.Ctx, user *schema.UserResponse, amr []string) (*schema.TokenResponse, error) {
+	// issue access_token (short-lived) and refresh_token (to update it)
 	// for security reasons we store refresh_token as a secure cookie (which is not in oauth standard)
-	// it can be changed when we will have an ability to revoke (or blacklist) them
-	refreshTokenCookie, err := a.jwxService.GetRefreshTokenCookie(refreshToken)
+	// every refresh token is persisted so it can be revoked on logout or password change
+	now := time.Now()
+	refreshTokenCookie, err := a.jwxService.IssueRefreshToken(user.ID, ctx.Get(fiber.HeaderUserAgent), ctx.IP())
 	if err != nil {
 		return nil, err
 	}
 
 	ctx.Cookie(refreshTokenCookie)
+	a.lockoutService.RegisterSuccess(user.Email)
 
-	authToken := jwt.New()
-	accessTokenDuration := viper.GetDuration("access_token_ttl")
-	err = authToken.Set(jwt.ExpirationKey, now.Add(accessTokenDuration))
-	if err != nil {
-		log.Info().
-			Str("user_id", user.ID.String()).
-			Msg(fmt.Sprintf("JWT Access token unable to set %s", jwt.ExpirationKey))
+	authToken := a.newAccessToken(user, now, now.Add(accessTokenTTL(user)))
+	a.setAuthentication(authToken, user, now, amr)
+	return a.jwxService.AuthTokenResponse(authToken)
+}
+
+// accessTokenTTL is shorter for admins since admin routes trust role claim,
+// so revoked role or deactivated admin loses access once the token expires.
+func accessTokenTTL(user *schema.UserResponse) time.Duration {
+	ttl := viper.GetDuration("access_token_ttl")
+	if adminTTL := viper.GetDuration("admin_access_token_ttl"); user.IsAdmin && adminTTL > 0 && adminTTL < ttl {
+		return adminTTL
 	}
 
-	err = authToken.Set(jwt.SubjectKey, user.ID.String())
+	return ttl
+}
+
+// StepUp re-authenticates already signed-in user with password or verification code
+// and issues short-lived access token which is accepted by sensitive endpoints.
+// Refresh token is not issued since elevation must not outlive step_up_ttl.
+func (a *authService) StepUp(ctx *fiber.Ctx, userId uuid.UUID, stepUpRequest *schema.StepUpRequest) (*schema.TokenResponse, error) {
+	user, err := a.usersService.Get(userId)
 	if err != nil {
-		log.Info().
-			Str("user_id", user.ID.String()).
-			Msg(fmt.Sprintf("JWT Access token unable to set %s", jwt.SubjectKey))
+		return nil, err
+	}
+
+	if !user.IsActive {
+		return nil, http.InactiveUserError()
 	}
 
+	if err = a.checkLockout(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	var amr []string
+	switch {
+	case stepUpRequest.Code != "":
+		if err = a.mfaService.Verify(userId, stepUpRequest.Code); err != nil {
+			a.lockoutService.RegisterFailure(user.Email, ctx.IP())
+			return nil, err
+		}
+
+		amr = []string{AMROTP}
+	case stepUpRequest.Password != "" || user.Password == "":
+		// users without password re-authenticate by signing in with identity provider again
+		if user.Password == "" {
+			return nil, http.ReauthenticationRequiredError()
+		}
+
+		if err = util.CheckPassword(user.Password, stepUpRequest.Password); err != nil {
+			a.lockoutService.RegisterFailure(user.Email, ctx.IP())
+			log.Info().
+				Str("user_id", userId.String()).
+				Msg("Step-up authentication with invalid password")
+
+			return nil, http.InvalidPasswordError()
+		}
+
+		amr = []string{AMRPassword}
+	default:
+		return nil, http.BadRequestWithMessage("Please provide password or verification code")
+	}
+
+	now := time.Now()
+	authToken := a.newAccessToken(user, now, now.Add(viper.GetDuration("step_up_ttl")))
+	a.setAuthentication(authToken, user, now, amr)
 	return a.jwxService.AuthTokenResponse(authToken)
 }
 
+// RestoreAccount cancels pending deletion, since account is disabled during grace period
+// user proves ownership the same way as on sign in: with e-mail and password followed
+// by the second factor when it is enabled, or with passkey alone.
+func (a *authService) RestoreAccount(ctx *fiber.Ctx, restoreRequest *schema.RestoreAccountRequest) (*schema.RestoreAccountResponse, error) {
+	switch {
+	case restoreRequest.MFAToken != "":
+		return a.restoreWithSecondFactor(ctx, restoreRequest)
+	case restoreRequest.Credential != nil:
+		userId, err := a.passkeyOwner(ctx, restoreRequest.Credential)
+		if err != nil {
+			return nil, err
+		}
+
+		user, err := a.passkeyUser(ctx, *userId, nil, restoreRequest.Credential)
+		if err != nil {
+			return nil, err
+		}
+
+		return a.restore(user)
+	}
+
+	if err := a.checkLockout(ctx, restoreRequest.Email); err != nil {
+		return nil, err
+	}
+
+	user, err := a.usersService.GetByEmail(restoreRequest.Email)
+	if err != nil {
+		a.lockoutService.RegisterFailure(restoreRequest.Email, ctx.IP())
+		return nil, http.UnauthorizedError("Wrong e-mail or password")
+	}
+
+	// users without password sign in with identity provider and send back challenge token
+	if err = verifyPassword(user.Password, restoreRequest.Password, time.Time{}); err != nil {
+		if user.Password == "" {
+			return nil, err
+		}
+
+		a.lockoutService.RegisterFailure(restoreRequest.Email, ctx.IP())
+		return nil, http.UnauthorizedError("Wrong e-mail or password")
+	}
+
+	if methods := a.mfaMethods(user.ID); len(methods) > 0 {
+		mfaToken, err := a.jwxService.IssueMFAToken(user.ID, []string{AMRPassword})
+		if err != nil {
+			return nil, err
+		}
+
+		return &schema.RestoreAccountResponse{MFAToken: mfaToken, MFAMethods: methods}, nil
+	}
+
+	return a.restore(user)
+}
+
+// restoreWithSecondFactor completes restore with challenge token and verification code or passkey,
+// passkey challenge is started with the challenge token at /auth/webauthn/mfa/begin.
+// Without two-factor authentication challenge token of identity provider sign in is enough.
+func (a *authService) restoreWithSecondFactor(ctx *fiber.Ctx, restoreRequest *schema.RestoreAccountRequest) (*schema.RestoreAccountResponse, error) {
+	userId, _, err := a.jwxService.ParseMFAToken(restoreRequest.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if restoreRequest.Credential != nil {
+		user, err := a.passkeyUser(ctx, *userId, userId, restoreRequest.Credential)
+		if err != nil {
+			return nil, err
+		}
+
+		return a.restore(user)
+	}
+
+	user, err := a.usersService.Get(*userId)
+	if err != nil {
+		return nil, http.UnauthorizedError("Invalid challenge token")
+	}
+
+	if err = a.checkLockout(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	if len(a.mfaMethods(user.ID)) == 0 {
+		return a.restore(user)
+	}
+
+	if restoreRequest.Code == "" {
+		return nil, http.BadRequestWithMessage("Please provide verification code or passkey")
+	}
+
+	if err = a.mfaService.Verify(user.ID, restoreRequest.Code); err != nil {
+		a.lockoutService.RegisterFailure(user.Email, ctx.IP())
+		return nil, err
+	}
+
+	return a.restore(user)
+}
+
+// restore cancels deletion of authenticated user, accounts deactivated by admin stay inactive
+func (a *authService) restore(user *schema.UserResponse) (*schema.RestoreAccountResponse, error) {
+	a.lockoutService.RegisterSuccess(user.Email)
+	if user.DeletionRequestedAt == nil {
+		return nil, http.Conflict("Account deletion was not requested")
+	}
+
+	if !isRestorable(user) {
+		return nil, http.InactiveUserError()
+	}
+
+	restoredUser, err := a.usersService.CancelDeletion(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema.RestoreAccountResponse{User: restoredUser}, nil
+}
+
 func (a *authService) RefreshAuthToken(ctx *fiber.Ctx) (*schema.TokenResponse, error) {
-	return a.jwxService.RefreshAuthToken(
+	tokenResponse, refreshTokenCookie, err := a.jwxService.RefreshAuthToken(
 		ctx.Cookies(RefreshTokenCookieName, ""),
+		ctx.Get(fiber.HeaderUserAgent),
+		ctx.IP(),
 		func(refreshToken jwt.Token) (jwt.Token, error) {
 			if refreshToken.Subject() == "" {
 				return nil, http.UnauthorizedError("Invalid refresh token")
 			}
 
-			userID := uuid.MustParse(refreshToken.Subject())
-			if !a.usersService.Exists(userID) {
-				return nil, http.NotFoundError("User not found")
+			userID, err := uuid.Parse(refreshToken.Subject())
+			if err != nil {
+				return nil, http.UnauthorizedError("Invalid refresh token")
 			}
 
-			authToken := jwt.New()
-			err := authToken.Set(jwt.ExpirationKey, time.Now().Add(viper.GetDuration("access_token_ttl")))
+			user, err := a.usersService.Get(userID)
 			if err != nil {
-				log.Info().
-					Str("user_id", userID.String()).
-					Msg(fmt.Sprintf("JWT Access token unable to set %s", jwt.ExpirationKey))
+				return nil, err
 			}
 
-			err = authToken.Set(jwt.SubjectKey, userID.String())
-			if err != nil {
-				log.Info().
-					Str("user_id", userID.String()).
-					Msg(fmt.Sprintf("JWT Access token unable to set %s", jwt.SubjectKey))
+			if !user.IsActive {
+				return nil, http.InactiveUserError()
 			}
 
+			now := time.Now()
+			authToken := a.newAccessToken(user, now, now.Add(accessTokenTTL(user)))
 			return authToken, nil
 		},
 	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.Cookie(refreshTokenCookie)
+	return tokenResponse, nil
+}
+
+// newAccessToken creates short-lived access token with default scope set, role is
+// kept as a claim so that admin checks do not need to fetch the user.
+func (a *authService) newAccessToken(user *schema.UserResponse, now time.Time, expiresAt time.Time) jwt.Token {
+	authToken := jwt.New()
+	err := authToken.Set(jwt.IssuedAtKey, now)
+	if err != nil {
+		log.Info().
+			Str("user_id", user.ID.String()).
+			Msg(fmt.Sprintf("JWT Access token unable to set %s", jwt.IssuedAtKey))
+	}
+
+	err = authToken.Set(jwt.ExpirationKey, expiresAt)
+	if err != nil {
+		log.Info().
+			Str("user_id", user.ID.String()).
+			Msg(fmt.Sprintf("JWT Access token unable to set %s", jwt.ExpirationKey))
+	}
+
+	err = authToken.Set(jwt.SubjectKey, user.ID.String())
+	if err != nil {
+		log.Info().
+			Str("user_id", user.ID.String()).
+			Msg(fmt.Sprintf("JWT Access token unable to set %s", jwt.SubjectKey))
+	}
+
+	err = authToken.Set(IsAdminClaim, user.IsAdmin)
+	if err != nil {
+		log.Info().
+			Str("user_id", user.ID.String()).
+			Msg(fmt.Sprintf("JWT Access token unable to set %s", IsAdminClaim))
+	}
+
+	err = authToken.Set(TokenUseClaim, AccessTokenUse)
+	if err != nil {
+		log.Info().
+			Str("user_id", user.ID.String()).
+			Msg(fmt.Sprintf("JWT Access token unable to set %s", TokenUseClaim))
+	}
+
+	err = authToken.Set(ScopeClaim, strings.Join(UserScopes(user.IsAdmin), " "))
+	if err != nil {
+		log.Info().
+			Str("user_id", user.ID.String()).
+			Msg(fmt.Sprintf("JWT Access token unable to set %s", ScopeClaim))
+	}
+
+	return authToken
+}
+
+// setAuthentication records when and how user has authenticated,
+// it is checked by endpoints which require recent authentication.
+func (a *authService) setAuthentication(authToken jwt.Token, user *schema.UserResponse, authTime time.Time, amr []string) {
+	err := authToken.Set(AuthTimeClaim, authTime.Unix())
+	if err != nil {
+		log.Info().
+			Str("user_id", user.ID.String()).
+			Msg(fmt.Sprintf("JWT Access token unable to set %s", AuthTimeClaim))
+	}
+
+	err = authToken.Set(AMRClaim, amr)
+	if err != nil {
+		log.Info().
+			Str("user_id", user.ID.String()).
+			Msg(fmt.Sprintf("JWT Access token unable to set %s", AMRClaim))
+	}
 }
 
 func (a *authService) JWKS(ctx *fiber.Ctx) error {
@@ -172,7 +627,7 @@ func (a *authService) Register(registerPayload *schema.RegisterRequest) error {
 }
 
 func (a *authService) ResetPasswordRequest(resetPasswordPayload *schema.ResetPasswordRequest) error {
-	passwordReset, err := a.usersService.ResetPasswordRequest(resetPasswordPayload.Email)
+	passwordReset, err := a.usersService.ResetPasswordRequest(resetPasswordPayload.Email, nil)
 	if err != nil {
 		log.Info().
 			Str("email", resetPasswordPayload.Email).
@@ -197,7 +652,116 @@ func (a *authService) ResetPasswordRequest(resetPasswordPayload *schema.ResetPas
 	return nil
 }
 
+// MagicLinkRequest e-mails one-time sign in link, links are not sent
+// to inactive or locked accounts.
+func (a *authService) MagicLinkRequest(ctx *fiber.Ctx, magicLinkPayload *schema.MagicLinkRequest) error {
+	// response must not tell whether the account exists, is active or locked
+	if _, err := a.lockoutService.Check(magicLinkPayload.Email, ctx.IP()); err != nil {
+		log.Info().
+			Str("email", magicLinkPayload.Email).
+			Msg("Magic link attempt for locked account")
+
+		return nil
+	}
+
+	user, err := a.usersService.GetByEmail(magicLinkPayload.Email)
+	if err != nil {
+		log.Info().
+			Str("email", magicLinkPayload.Email).
+			Msg("Magic link attempt using unknown email")
+
+		return nil
+	}
+
+	if !user.IsActive {
+		log.Info().
+			Str("user_id", user.ID.String()).
+			Msg("Magic link attempt for inactive user")
+
+		return nil
+	}
+
+	magicLink, err := a.usersService.MagicLinkRequest(user.Email)
+	if err != nil {
+		return err
+	}
+
+	if err = a.mailHandler.SendMagicLink(user.Email, magicLink.Code); err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", user.ID.String()).
+			Msg("Unable to send magic link")
+
+		return http.InternalError(err)
+	}
+
+	return nil
+}
+
+// MagicLinkAuthFlow exchanges magic link code for tokens,
+// second factor is still required when it is enabled.
+func (a *authService) MagicLinkAuthFlow(ctx *fiber.Ctx, code string) (*schema.TokenResponse, error) {
+	// link is not used up while account is locked
+	user, err := a.usersService.MagicLinkOwner(code)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = a.checkLockout(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	if user, err = a.usersService.ConsumeMagicLink(code); err != nil {
+		return nil, err
+	}
+
+	if !user.IsActive {
+		return nil, http.InactiveUserError()
+	}
+
+	if methods := a.mfaMethods(user.ID); len(methods) > 0 {
+		return a.mfaChallenge(user, methods, []string{AMROTP})
+	}
+
+	// one-time code delivered by e-mail
+	return a.issueTokens(ctx, user, []string{AMROTP})
+}
+
+// OIDCAuthFlow completes sign in with external identity provider,
+// second factor is still required when it is enabled.
+func (a *authService) OIDCAuthFlow(ctx *fiber.Ctx, providerName string, callback *schema.OIDCCallbackRequest) (*schema.TokenResponse, error) {
+	user, err := a.oidcService.Callback(ctx.Context(), providerName, callback)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = a.checkLockout(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	if !user.IsActive {
+		// challenge token restores account awaiting deletion at /accounts/restore,
+		// it can not be exchanged for tokens while account is inactive.
+		if isRestorable(user) {
+			return a.mfaChallenge(user, a.mfaMethods(user.ID), []string{AMRFederated})
+		}
+
+		return nil, http.InactiveUserError()
+	}
+
+	if methods := a.mfaMethods(user.ID); len(methods) > 0 {
+		return a.mfaChallenge(user, methods, []string{AMRFederated})
+	}
+
+	return a.issueTokens(ctx, user, []string{AMRFederated})
+}
+
 func (a *authService) Logout(ctx *fiber.Ctx) error {
+	err := a.jwxService.RevokeRefreshToken(ctx.Cookies(RefreshTokenCookieName, ""))
+	if err != nil {
+		return err
+	}
+
 	ctx.ClearCookie(RefreshTokenCookieName)
 	return ctx.SendStatus(fiber.StatusNoContent)
 }
@@ -1,45 +1,51 @@
 package services
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha512"
 	"database/sql"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 	"github.com/sultaniman/confetti/platform/entities"
 	"github.com/sultaniman/confetti/platform/http"
+	"github.com/sultaniman/confetti/platform/keys"
 	"github.com/sultaniman/confetti/platform/repo"
 	"github.com/sultaniman/confetti/platform/schema"
 	"github.com/sultaniman/pwc/crypto"
 	"github.com/sultaniman/pwc/gen"
+	"time"
 )
 
 type CardService interface {
 	Generate(options *schema.CardOptions) (*schema.NewCardResponse, error)
 	Get(cardId uuid.UUID) (*schema.CardResponse, error)
-	List(userId uuid.UUID) ([]schema.CardResponse, error)
-	Create(userId uuid.UUID, newCard *schema.NewCardRequest) (*schema.CardResponse, error)
-	Update(cardId uuid.UUID, updateRequest *schema.UpdateCardRequest) error
-	Delete(cardId uuid.UUID) error
-	Decrypt(cardId uuid.UUID) (*schema.PlainCardResponse, error)
+	List(userId uuid.UUID, listRequest *schema.CardListRequest) (*schema.CardListResponse, error)
+	Create(userId uuid.UUID, newCard *schema.NewCardRequest, auditContext *schema.AuditContext) (*schema.CardResponse, error)
+	Update(cardId uuid.UUID, updateRequest *schema.UpdateCardRequest, auditContext *schema.AuditContext) error
+	Delete(cardId uuid.UUID, auditContext *schema.AuditContext) error
+	Decrypt(cardId uuid.UUID, auditContext *schema.AuditContext) (*schema.PlainCardResponse, error)
 	ClaimExists(cardId uuid.UUID, userId uuid.UUID) bool
+	CardAudit(cardId uuid.UUID, limit uint64) ([]schema.CardAuditResponse, error)
+	UserAudit(userId uuid.UUID, limit uint64) ([]schema.CardAuditResponse, error)
+	KeyUsage() ([]schema.KeyUsage, error)
+	RotateKeys(fromKeyID string, toKeyID string, batchSize uint64) (int, error)
 }
 
 type cardService struct {
-	privateKey *rsa.PrivateKey
-	cardsRepo  repo.CardRepo
-	usersRepo  repo.UserRepo
+	keyring   *keys.Keyring
+	cardsRepo repo.CardRepo
+	usersRepo repo.UserRepo
+	auditRepo repo.AuditRepo
 }
 
-const EncryptionKeyID = "v1"
-
-func NewCardService(usersRepo repo.UserRepo, cardsRepo repo.CardRepo, privateKey *rsa.PrivateKey) CardService {
+func NewCardService(usersRepo repo.UserRepo, cardsRepo repo.CardRepo, auditRepo repo.AuditRepo, keyring *keys.Keyring) CardService {
 	return &cardService{
-		privateKey: privateKey,
-		cardsRepo:  cardsRepo,
-		usersRepo:  usersRepo,
+		keyring:   keyring,
+		cardsRepo: cardsRepo,
+		usersRepo: usersRepo,
+		auditRepo: auditRepo,
 	}
 }
 
@@ -64,32 +70,70 @@ func (c *cardService) Get(cardId uuid.UUID) (*schema.CardResponse, error) {
 	return c.cardToResponse(card), nil
 }
 
-func (c *cardService) List(userId uuid.UUID) ([]schema.CardResponse, error) {
-	cards, err := c.cardsRepo.List(&repo.FilterSpec{
-		UserId: &userId,
-	})
+// List returns page of user cards, NextCursor is empty on the last page
+func (c *cardService) List(userId uuid.UUID, listRequest *schema.CardListRequest) (*schema.CardListResponse, error) {
+	filterSpec := &repo.FilterSpec{
+		UserId:        &userId,
+		Title:         listRequest.Title,
+		TitlePrefix:   listRequest.TitlePrefix,
+		CreatedAfter:  listRequest.CreatedAfter,
+		CreatedBefore: listRequest.CreatedBefore,
+		UpdatedAfter:  listRequest.UpdatedAfter,
+		UpdatedBefore: listRequest.UpdatedBefore,
+		SortBy:        listRequest.Sort,
+		SortAsc:       listRequest.Ascending,
+	}
 
+	totalCount, err := c.cardsRepo.Count(filterSpec)
 	if err != nil {
 		return nil, c.handleError(err)
 	}
 
-	var cardsResponse []schema.CardResponse
+	if listRequest.Cursor != "" {
+		cursor, err := decodeCursor(listRequest.Cursor, filterSpec)
+		if err != nil {
+			return nil, err
+		}
+
+		filterSpec.After = cursor
+	}
+
+	// one more card tells whether there is a next page
+	filterSpec.Limit = listRequest.Limit + 1
+	cards, err := c.cardsRepo.List(filterSpec)
+	if err != nil {
+		return nil, c.handleError(err)
+	}
+
+	nextCursor := ""
+	if uint64(len(cards)) > listRequest.Limit {
+		cards = cards[:listRequest.Limit]
+		nextCursor, err = encodeCursor(&cards[len(cards)-1], filterSpec)
+		if err != nil {
+			return nil, http.InternalError(err)
+		}
+	}
+
+	cardsResponse := make([]schema.CardResponse, 0, len(cards))
 	for _, card := range cards {
 		cardsResponse = append(cardsResponse, *c.cardToResponse(&card))
 	}
 
-	return cardsResponse, nil
+	return &schema.CardListResponse{
+		Cards:      cardsResponse,
+		NextCursor: nextCursor,
+		TotalCount: totalCount,
+	}, nil
 }
 
-func (c *cardService) Create(userId uuid.UUID, newCard *schema.NewCardRequest) (*schema.CardResponse, error) {
+func (c *cardService) Create(userId uuid.UUID, newCard *schema.NewCardRequest, auditContext *schema.AuditContext) (*schema.CardResponse, error) {
 	message := crypto.NewMessage(newCard.Data, "")
 	encryptedData, err := message.Encrypt(newCard.Key)
 	if err != nil {
 		return nil, http.EncryptionError(err)
 	}
 
-	hash := sha512.New()
-	encryptedKey, err := rsa.EncryptOAEP(hash, rand.Reader, &c.privateKey.PublicKey, []byte(newCard.Key), nil)
+	encryptedKey, keyID, err := c.keyring.Encrypt([]byte(newCard.Key))
 	if err != nil {
 		return nil, http.EncryptionError(err)
 	}
@@ -99,48 +143,48 @@ func (c *cardService) Create(userId uuid.UUID, newCard *schema.NewCardRequest) (
 		Title:  newCard.Title,
 		Data:   base64.StdEncoding.EncodeToString([]byte(encryptedData)),
 		Key:    base64.StdEncoding.EncodeToString(encryptedKey),
-		KeyID:  EncryptionKeyID,
-	})
+		KeyID:  keyID,
+	}, newAuditRecord(uuid.Nil, entities.CardCreated, auditContext))
 
 	if err != nil {
-		return nil, http.InternalError(err)
+		return nil, c.auditError(err, uuid.Nil, entities.CardCreated, auditContext)
 	}
 
 	return c.cardToResponse(card), nil
 }
 
-func (c *cardService) Update(cardId uuid.UUID, updateRequest *schema.UpdateCardRequest) error {
-	_, err := c.cardsRepo.Update(cardId, updateRequest.Title)
+func (c *cardService) Update(cardId uuid.UUID, updateRequest *schema.UpdateCardRequest, auditContext *schema.AuditContext) error {
+	_, err := c.cardsRepo.Update(cardId, updateRequest.Title, newAuditRecord(cardId, entities.CardUpdated, auditContext))
 	if err != nil {
-		return c.handleError(err)
+		return c.auditError(err, cardId, entities.CardUpdated, auditContext)
 	}
 
 	return nil
 }
 
-func (c *cardService) Delete(cardId uuid.UUID) error {
-	err := c.cardsRepo.Delete(cardId)
+func (c *cardService) Delete(cardId uuid.UUID, auditContext *schema.AuditContext) error {
+	err := c.cardsRepo.Delete(cardId, newAuditRecord(cardId, entities.CardDeleted, auditContext))
 	if err != nil {
-		return c.handleError(err)
+		return c.auditError(err, cardId, entities.CardDeleted, auditContext)
 	}
 
 	return nil
 }
 
-func (c *cardService) Decrypt(cardId uuid.UUID) (*schema.PlainCardResponse, error) {
+// Decrypt reveals card data and passphrase, plaintext is returned
+// only if the access was recorded in audit log.
+func (c *cardService) Decrypt(cardId uuid.UUID, auditContext *schema.AuditContext) (*schema.PlainCardResponse, error) {
 	card, err := c.cardsRepo.Get(cardId)
 	if err != nil {
 		return nil, c.handleError(err)
 	}
 
-	hash := sha512.New()
-
 	decodedKey, err := base64.StdEncoding.DecodeString(card.EncryptedKey)
 	if err != nil {
 		return nil, http.DecodingError(err)
 	}
 
-	passphrase, err := rsa.DecryptOAEP(hash, rand.Reader, c.privateKey, decodedKey, nil)
+	passphrase, err := c.keyring.Decrypt(cardKeyID(card), decodedKey)
 	if err != nil {
 		return nil, http.DecryptionError(err)
 	}
@@ -156,6 +200,10 @@ func (c *cardService) Decrypt(cardId uuid.UUID) (*schema.PlainCardResponse, erro
 		return nil, http.DecryptionError(err)
 	}
 
+	if err = c.audit(card.ID, entities.CardDecrypted, auditContext); err != nil {
+		return nil, http.InternalError(err)
+	}
+
 	return &schema.PlainCardResponse{
 		Title: card.Title,
 		Data:  data,
@@ -167,18 +215,260 @@ func (c *cardService) ClaimExists(cardId uuid.UUID, userId uuid.UUID) bool {
 	return c.cardsRepo.ClaimExists(cardId, userId)
 }
 
+func (c *cardService) CardAudit(cardId uuid.UUID, limit uint64) ([]schema.CardAuditResponse, error) {
+	return c.listAudit(&repo.AuditFilterSpec{
+		CardId: &cardId,
+		Limit:  limit,
+	})
+}
+
+func (c *cardService) UserAudit(userId uuid.UUID, limit uint64) ([]schema.CardAuditResponse, error) {
+	return c.listAudit(&repo.AuditFilterSpec{
+		ActorId: &userId,
+		Limit:   limit,
+	})
+}
+
+func (c *cardService) listAudit(filterSpec *repo.AuditFilterSpec) ([]schema.CardAuditResponse, error) {
+	records, err := c.auditRepo.List(filterSpec)
+	if err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	var auditResponse []schema.CardAuditResponse
+	for _, record := range records {
+		recordResponse := schema.CardAuditResponse{
+			ID:        record.ID,
+			ActorId:   record.ActorId,
+			CardId:    record.CardId,
+			Action:    record.Action,
+			CreatedAt: record.CreatedAt,
+		}
+
+		if record.RequestId != nil {
+			recordResponse.RequestId = *record.RequestId
+		}
+
+		if record.IPAddress != nil {
+			recordResponse.IPAddress = *record.IPAddress
+		}
+
+		if record.UserAgent != nil {
+			recordResponse.UserAgent = *record.UserAgent
+		}
+
+		auditResponse = append(auditResponse, recordResponse)
+	}
+
+	return auditResponse, nil
+}
+
+func (c *cardService) audit(cardId uuid.UUID, action entities.CardAction, auditContext *schema.AuditContext) error {
+	_, err := c.auditRepo.Create(newAuditRecord(cardId, action, auditContext))
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", auditContext.ActorId.String()).
+			Str("card_id", cardId.String()).
+			Str("action", string(action)).
+			Msg("Unable to write card audit record")
+	}
+
+	return err
+}
+
+// auditError logs failed card change, the change and its audit record are written
+// in one transaction so the error may come from either of them.
+func (c *cardService) auditError(err error, cardId uuid.UUID, action entities.CardAction, auditContext *schema.AuditContext) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.handleError(err)
+	}
+
+	log.Error().
+		Err(err).
+		Str("user_id", auditContext.ActorId.String()).
+		Str("card_id", cardId.String()).
+		Str("action", string(action)).
+		Msg("Unable to change card and write audit record")
+
+	return http.InternalError(err)
+}
+
+func newAuditRecord(cardId uuid.UUID, action entities.CardAction, auditContext *schema.AuditContext) *entities.NewCardAuditRecord {
+	return &entities.NewCardAuditRecord{
+		ActorId:   auditContext.ActorId,
+		CardId:    cardId,
+		Action:    action,
+		RequestId: auditContext.RequestId,
+		IPAddress: auditContext.IPAddress,
+		UserAgent: auditContext.UserAgent,
+	}
+}
+
+func (c *cardService) KeyUsage() ([]schema.KeyUsage, error) {
+	usage, err := c.cardsRepo.KeyUsage()
+	if err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	var usageResponse []schema.KeyUsage
+	for _, keyUsage := range usage {
+		usageResponse = append(usageResponse, schema.KeyUsage{
+			KeyID: keyUsage.KeyID,
+			Cards: keyUsage.Cards,
+		})
+	}
+
+	return usageResponse, nil
+}
+
+// RotateKeys re-encrypts card passphrases from one key to another,
+// encrypted card data is never touched since it does not depend on server keys.
+// Old key is allowed to be revoked or expired as rotating away from it is the point,
+// if target key is not given then the active key is used.
+func (c *cardService) RotateKeys(fromKeyID string, toKeyID string, batchSize uint64) (int, error) {
+	if toKeyID == "" {
+		toKeyID = c.keyring.ActiveKeyID
+	}
+
+	if fromKeyID == toKeyID {
+		return 0, fmt.Errorf("source and target keys are the same: %s", fromKeyID)
+	}
+
+	oldKey, err := c.keyring.Get(fromKeyID)
+	if err != nil {
+		return 0, fmt.Errorf("key %s: %w", fromKeyID, err)
+	}
+
+	newKey, err := c.keyring.EncryptionKey(toKeyID)
+	if err != nil {
+		return 0, fmt.Errorf("key %s: %w", toKeyID, err)
+	}
+
+	rotated := 0
+	for {
+		count, err := c.cardsRepo.RewrapKeys(fromKeyID, batchSize, func(card *entities.Card) (*entities.KeyUpdate, error) {
+			decodedKey, err := base64.StdEncoding.DecodeString(card.EncryptedKey)
+			if err != nil {
+				return nil, err
+			}
+
+			passphrase, err := keys.DecryptWithKey(oldKey, decodedKey)
+			if err != nil {
+				return nil, err
+			}
+
+			encryptedKey, err := keys.EncryptWithKey(newKey, passphrase)
+			if err != nil {
+				return nil, err
+			}
+
+			return &entities.KeyUpdate{
+				EncryptedKey: base64.StdEncoding.EncodeToString(encryptedKey),
+				KeyID:        newKey.KeyID,
+			}, nil
+		})
+
+		if err != nil {
+			return rotated, err
+		}
+
+		rotated += count
+		if count == 0 {
+			return rotated, nil
+		}
+
+		log.Info().
+			Str("from_key_id", fromKeyID).
+			Str("to_key_id", toKeyID).
+			Int("rotated", rotated).
+			Msg("Rotated batch of card keys")
+	}
+}
+
 func (c *cardService) cardToResponse(card *entities.Card) *schema.CardResponse {
 	return &schema.CardResponse{
 		ID:            card.ID,
 		UserId:        card.UserId,
 		Title:         card.Title,
 		EncryptedData: card.EncryptedData,
-		KeyID:         card.KeyID,
+		KeyID:         cardKeyID(card),
 		CreatedAt:     card.CreatedAt,
 		UpdatedAt:     card.UpdatedAt,
 	}
 }
 
+// cardKeyID returns id of the key card passphrase is encrypted with,
+// cards created before key ids were stored use the default key.
+func cardKeyID(card *entities.Card) string {
+	if card.KeyID == nil || *card.KeyID == "" {
+		return keys.DefaultKeyID
+	}
+
+	return *card.KeyID
+}
+
+// cardCursor is an opaque pagination token, it remembers sorting
+// so that it can not be used with a different order.
+type cardCursor struct {
+	Sort  string    `json:"s"`
+	Asc   bool      `json:"a,omitempty"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+func encodeCursor(card *entities.Card, filterSpec *repo.FilterSpec) (string, error) {
+	cursor := cardCursor{
+		Sort: filterSpec.SortBy,
+		Asc:  filterSpec.SortAsc,
+		ID:   card.ID,
+	}
+
+	switch filterSpec.SortBy {
+	case repo.SortByTitle:
+		cursor.Value = card.Title
+	case repo.SortByUpdatedAt:
+		cursor.Value = card.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	default:
+		cursor.Value = card.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	encoded, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+func decodeCursor(encoded string, filterSpec *repo.FilterSpec) (*repo.Cursor, error) {
+	invalidCursor := http.BadRequestWithMessage("Invalid cursor")
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalidCursor
+	}
+
+	cursor := cardCursor{}
+	if err = json.Unmarshal(decoded, &cursor); err != nil {
+		return nil, invalidCursor
+	}
+
+	if cursor.Sort != filterSpec.SortBy || cursor.Asc != filterSpec.SortAsc {
+		return nil, http.BadRequestWithMessage("Cursor does not match sort order")
+	}
+
+	if cursor.Sort == repo.SortByTitle {
+		return &repo.Cursor{Value: cursor.Value, ID: cursor.ID}, nil
+	}
+
+	value, err := time.Parse(time.RFC3339Nano, cursor.Value)
+	if err != nil {
+		return nil, invalidCursor
+	}
+
+	return &repo.Cursor{Value: value.UTC(), ID: cursor.ID}, nil
+}
+
 func (c *cardService) handleError(err error) error {
 	if errors.Is(err, sql.ErrNoRows) {
 		return http.NotFoundError("Card not found")
@@ -1,73 +1,161 @@
 package services
 
 import (
-	"crypto/rsa"
+	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
+	"github.com/sultaniman/confetti/platform/entities"
 	"github.com/sultaniman/confetti/platform/http"
+	"github.com/sultaniman/confetti/platform/keys"
+	"github.com/sultaniman/confetti/platform/repo"
 	"github.com/sultaniman/confetti/platform/schema"
 	"github.com/sultaniman/confetti/platform/shared"
+	"strings"
 	"time"
 )
 
 const RefreshTokenCookieName = "refresh_token"
-const DefaultJWA = jwa.RS256   // alg
-const DefaultKeyID = "default" // kid
+const IsAdminClaim = "is_admin"
 
+// AuthTimeClaim and AMRClaim (RFC 8176) describe when and how the user
+// authenticated, refreshed access tokens carry neither of them.
+const (
+	AuthTimeClaim = "auth_time"
+	AMRClaim      = "amr"
+)
+
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRHardwareKey = "hwk"
+	AMRMultiFactor = "mfa"
+	// AMRFederated is not registered in RFC 8176, it marks sign in with external identity provider
+	AMRFederated = "fed"
+)
+
+// TokenUseClaim tells what kind of token it is since all tokens are signed with
+// the same key, only access tokens are accepted by the auth middleware.
+const TokenUseClaim = "token_use"
+const (
+	AccessTokenUse  = "access"
+	RefreshTokenUse = "refresh"
+	MFATokenUse     = "mfa"
+)
+
+// signingJWK is public part of signing key which is published in JWKS
+type signingJWK struct {
+	key          jwk.Key
+	invalidAfter *time.Time
+}
+
+// JWXService signs new tokens with the active signing key and verifies tokens
+// with any published key, verification key is selected by kid header.
 type JWXService struct {
 	privateJWK jwk.Key
-	jwks       *jwk.Set
+	publicJWKs []signingJWK
+	tokensRepo repo.TokenRepo
 }
 
-func NewJWXService(privateKey *rsa.PrivateKey) (*JWXService, error) {
-	privateJWK, err := jwk.New(privateKey)
+func NewJWXService(keyring *keys.SigningKeyring, tokensRepo repo.TokenRepo) (*JWXService, error) {
+	activeKey, err := keyring.Active()
 	if err != nil {
 		return nil, err
 	}
 
-	if err = privateJWK.Set(jwk.AlgorithmKey, DefaultJWA); err != nil {
+	privateJWK, err := newJWK(activeKey.PrivateKey, activeKey.Algorithm, activeKey.KeyID)
+	if err != nil {
 		return nil, err
 	}
 
-	if err = privateJWK.Set(jwk.KeyIDKey, DefaultKeyID); err != nil {
-		return nil, err
+	// revoked keys are not published so their tokens are rejected right away
+	var publicJWKs []signingJWK
+	for _, signingKey := range keyring.Keys {
+		if signingKey.Revoked {
+			continue
+		}
+
+		publicJWK, err := newJWK(signingKey.PrivateKey.Public(), signingKey.Algorithm, signingKey.KeyID)
+		if err != nil {
+			return nil, err
+		}
+
+		publicJWKs = append(publicJWKs, signingJWK{
+			key:          publicJWK,
+			invalidAfter: signingKey.InvalidAfter,
+		})
 	}
 
-	publicJWK, err := jwk.New(privateKey.Public())
+	return &JWXService{
+		privateJWK: privateJWK,
+		publicJWKs: publicJWKs,
+		tokensRepo: tokensRepo,
+	}, nil
+}
+
+// newJWK wraps RSA, EC or Ed25519 (OKP) key, algorithm and kid are set
+// so that verifiers pick the right key and do not need to guess algorithm.
+func newJWK(rawKey interface{}, algorithm jwa.SignatureAlgorithm, keyID string) (jwk.Key, error) {
+	key, err := jwk.New(rawKey)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = publicJWK.Set(jwk.AlgorithmKey, DefaultJWA); err != nil {
+	if err = key.Set(jwk.AlgorithmKey, algorithm); err != nil {
 		return nil, err
 	}
 
-	if err = publicJWK.Set(jwk.KeyIDKey, DefaultKeyID); err != nil {
+	if err = key.Set(jwk.KeyIDKey, keyID); err != nil {
 		return nil, err
 	}
 
+	return key, nil
+}
+
+// JWKS returns public keys which are valid at the moment, keys past
+// invalid_after drop out without restart once their rollover window ends.
+func (s *JWXService) JWKS() jwk.Set {
+	now := time.Now().UTC()
 	jwks := jwk.NewSet()
-	err = jwks.Set("keys", []jwk.Key{publicJWK})
-	if err != nil {
-		return nil, err
+	for _, publicJWK := range s.publicJWKs {
+		if publicJWK.invalidAfter == nil || now.Before(*publicJWK.invalidAfter) {
+			jwks.Add(publicJWK.key)
+		}
 	}
 
-	return &JWXService{
-		privateJWK: privateJWK,
-		jwks:       &jwks,
-	}, nil
+	return jwks
+}
+
+// sign signs token with the active key, its kid is set in the token header
+func (s *JWXService) sign(token jwt.Token) ([]byte, error) {
+	return jwt.Sign(token, jwa.SignatureAlgorithm(s.privateJWK.Algorithm()), s.privateJWK)
 }
 
-func (s *JWXService) JWKS() *jwk.Set {
-	return s.jwks
+// IssueRefreshToken persists refresh token record so it can be revoked
+// later and returns signed token as a cookie, each call starts a new token family.
+func (s *JWXService) IssueRefreshToken(userId uuid.UUID, userAgent string, ipAddress string) (*fiber.Cookie, error) {
+	tokenRecord, err := s.tokensRepo.Create(&entities.NewRefreshToken{
+		UserId:    userId,
+		ExpiresAt: time.Now().Add(viper.GetDuration("refresh_token_ttl")),
+		UserAgent: userAgent,
+		IPAddress: ipAddress,
+	})
+
+	if err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	return s.refreshTokenCookie(tokenRecord)
 }
 
 func (s *JWXService) GetRefreshTokenCookie(token jwt.Token) (*fiber.Cookie, error) {
-	alg := jwa.SignatureAlgorithm(s.privateJWK.Algorithm())
-	signed, err := jwt.Sign(token, alg, s.privateJWK)
+	signed, err := s.sign(token)
 	if err != nil {
 		return nil, jwxError("unable to sign refresh token")
 	}
@@ -82,33 +170,259 @@ func (s *JWXService) GetRefreshTokenCookie(token jwt.Token) (*fiber.Cookie, erro
 	}, nil
 }
 
+func (s *JWXService) refreshTokenCookie(tokenRecord *entities.RefreshToken) (*fiber.Cookie, error) {
+	refreshToken := jwt.New()
+	claims := map[string]interface{}{
+		jwt.JwtIDKey:      tokenRecord.JTI.String(),
+		jwt.SubjectKey:    tokenRecord.UserId.String(),
+		jwt.IssuedAtKey:   tokenRecord.IssuedAt,
+		jwt.ExpirationKey: tokenRecord.ExpiresAt,
+		TokenUseClaim:     RefreshTokenUse,
+	}
+
+	for claim, value := range claims {
+		if err := refreshToken.Set(claim, value); err != nil {
+			log.Info().
+				Str("user_id", tokenRecord.UserId.String()).
+				Msg(fmt.Sprintf("JWT Refresh token unable to set %s", claim))
+		}
+	}
+
+	return s.GetRefreshTokenCookie(refreshToken)
+}
+
 type RefreshTokenFunc func(jwt.Token) (jwt.Token, error)
 
-func (s *JWXService) RefreshAuthToken(refreshTokenCookie string, refreshFunc RefreshTokenFunc) (*schema.TokenResponse, error) {
+// RefreshAuthToken issues new access token and rotates refresh token,
+// presenting already rotated refresh token means it was stolen
+// and the whole token family gets revoked.
+func (s *JWXService) RefreshAuthToken(refreshTokenCookie string, userAgent string, ipAddress string, refreshFunc RefreshTokenFunc) (*schema.TokenResponse, *fiber.Cookie, error) {
 	if refreshTokenCookie == "" {
-		return nil, jwxError("refresh token is not set")
+		return nil, nil, jwxError("refresh token is not set")
 	}
 
-	refreshToken, err := jwt.Parse([]byte(refreshTokenCookie), jwt.WithKeySet(*s.jwks))
+	refreshToken, err := jwt.Parse([]byte(refreshTokenCookie), jwt.WithKeySet(s.JWKS()))
 	if err != nil {
-		return nil, jwxError("failed to verify refresh token")
+		return nil, nil, jwxError("failed to verify refresh token")
 	}
 
 	now := time.Now()
 	if refreshToken.Expiration().Before(now) {
-		return nil, jwxError("refresh token has expired")
+		return nil, nil, jwxError("refresh token has expired")
+	}
+
+	tokenRecord, err := s.getRefreshTokenRecord(refreshToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tokenRecord.RevokedAt != nil {
+		if tokenRecord.ReplacedBy != nil {
+			return nil, nil, s.revokeReusedToken(tokenRecord)
+		}
+
+		return nil, nil, jwxError("refresh token has been revoked")
 	}
 
 	token, err := refreshFunc(refreshToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newTokenRecord, err := s.tokensRepo.Rotate(tokenRecord.JTI, &entities.NewRefreshToken{
+		UserId:    tokenRecord.UserId,
+		FamilyId:  &tokenRecord.FamilyId,
+		ExpiresAt: now.Add(viper.GetDuration("refresh_token_ttl")),
+		UserAgent: userAgent,
+		IPAddress: ipAddress,
+	})
+
+	if err != nil {
+		if errors.Is(err, repo.ErrTokenAlreadyRotated) {
+			return nil, nil, s.revokeReusedToken(tokenRecord)
+		}
+
+		return nil, nil, http.InternalError(err)
+	}
+
+	cookie, err := s.refreshTokenCookie(newTokenRecord)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokenResponse, err := s.AuthTokenResponse(token)
+	return tokenResponse, cookie, err
+}
+
+// IssueMFAToken issues short-lived challenge token which proves that the first
+// authentication step was passed, amr tells how it was passed.
+func (s *JWXService) IssueMFAToken(userId uuid.UUID, amr []string) (string, error) {
+	mfaToken := jwt.New()
+	claims := map[string]interface{}{
+		jwt.SubjectKey:    userId.String(),
+		jwt.ExpirationKey: time.Now().Add(viper.GetDuration("mfa_token_ttl")),
+		TokenUseClaim:     MFATokenUse,
+		AMRClaim:          amr,
+	}
+
+	for claim, value := range claims {
+		if err := mfaToken.Set(claim, value); err != nil {
+			log.Info().
+				Str("user_id", userId.String()).
+				Msg(fmt.Sprintf("JWT MFA token unable to set %s", claim))
+		}
+	}
+
+	signed, err := s.sign(mfaToken)
+	if err != nil {
+		return "", http.InternalError(err)
+	}
+
+	return string(signed), nil
+}
+
+// ParseMFAToken returns user and methods of the first authentication step
+func (s *JWXService) ParseMFAToken(mfaToken string) (*uuid.UUID, []string, error) {
+	token, err := jwt.Parse([]byte(mfaToken), jwt.WithKeySet(s.JWKS()), jwt.WithValidate(true))
+	if err != nil {
+		return nil, nil, jwxError("invalid challenge token")
+	}
+
+	if tokenUse, _ := token.Get(TokenUseClaim); tokenUse != MFATokenUse {
+		return nil, nil, jwxError("invalid challenge token")
+	}
+
+	userId, err := uuid.Parse(token.Subject())
+	if err != nil {
+		return nil, nil, jwxError("invalid challenge token")
+	}
+
+	values, ok := token.PrivateClaims()[AMRClaim].([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, nil, jwxError("invalid challenge token")
+	}
+
+	var amr []string
+	for _, value := range values {
+		if method, ok := value.(string); ok {
+			amr = append(amr, method)
+		}
+	}
+
+	return &userId, amr, nil
+}
+
+// RevokeRefreshToken revokes refresh token from cookie,
+// tokens which fail verification are ignored as they can not be used anyway.
+func (s *JWXService) RevokeRefreshToken(refreshTokenCookie string) error {
+	if refreshTokenCookie == "" {
+		return nil
+	}
+
+	refreshToken, err := jwt.Parse([]byte(refreshTokenCookie), jwt.WithKeySet(s.JWKS()))
+	if err != nil {
+		return nil
+	}
+
+	tokenRecord, err := s.getRefreshTokenRecord(refreshToken)
+	if err != nil {
+		return nil
+	}
+
+	if err = s.tokensRepo.Revoke(tokenRecord.JTI); err != nil {
+		return http.InternalError(err)
+	}
+
+	return nil
+}
+
+// RefreshTokenFamily returns token family of a valid refresh token
+func (s *JWXService) RefreshTokenFamily(refreshTokenCookie string) (*uuid.UUID, error) {
+	refreshToken, err := jwt.Parse([]byte(refreshTokenCookie), jwt.WithKeySet(s.JWKS()))
+	if err != nil {
+		return nil, jwxError("failed to verify refresh token")
+	}
+
+	tokenRecord, err := s.getRefreshTokenRecord(refreshToken)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.AuthTokenResponse(token)
+	return &tokenRecord.FamilyId, nil
+}
+
+func (s *JWXService) revokeReusedToken(tokenRecord *entities.RefreshToken) error {
+	log.Warn().
+		Str("user_id", tokenRecord.UserId.String()).
+		Str("family_id", tokenRecord.FamilyId.String()).
+		Msg("Rotated refresh token was reused, revoking token family")
+
+	if err := s.tokensRepo.RevokeFamily(tokenRecord.FamilyId); err != nil {
+		return http.InternalError(err)
+	}
+
+	return jwxError("refresh token has been revoked")
+}
+
+func (s *JWXService) getRefreshTokenRecord(refreshToken jwt.Token) (*entities.RefreshToken, error) {
+	jti, err := uuid.Parse(refreshToken.JwtID())
+	if err != nil {
+		return nil, jwxError("invalid refresh token")
+	}
+
+	tokenRecord, err := s.tokensRepo.Get(jti)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, jwxError("invalid refresh token")
+		}
+
+		return nil, http.InternalError(err)
+	}
+
+	if tokenRecord.UserId.String() != refreshToken.Subject() {
+		return nil, jwxError("invalid refresh token")
+	}
+
+	return tokenRecord, nil
+}
+
+// NarrowAccessToken re-issues access token with a subset of its scopes, expiration and
+// authentication time are kept so narrowed token never outlives the original one.
+func (s *JWXService) NarrowAccessToken(accessToken string, scopes []string) (*schema.TokenResponse, error) {
+	token, err := jwt.Parse([]byte(accessToken), jwt.WithKeySet(s.JWKS()), jwt.WithValidate(true))
+	if err != nil {
+		return nil, jwxError("failed to verify token")
+	}
+
+	if tokenUse, _ := token.Get(TokenUseClaim); tokenUse != AccessTokenUse {
+		return nil, jwxError("invalid token")
+	}
+
+	if len(scopes) == 0 {
+		return nil, http.BadRequestWithMessage("Please provide at least one scope")
+	}
+
+	granted := TokenScopes(token)
+	for _, scope := range scopes {
+		if !hasScope(granted, scope) {
+			return nil, http.ForbiddenError("Token is missing scope " + scope)
+		}
+	}
+
+	narrowed, err := token.Clone()
+	if err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	if err = narrowed.Set(ScopeClaim, strings.Join(scopes, " ")); err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	return s.AuthTokenResponse(narrowed)
 }
 
 func (s *JWXService) AuthTokenResponse(accessToken jwt.Token) (*schema.TokenResponse, error) {
-	signed, err := jwt.Sign(accessToken, DefaultJWA, s.privateJWK)
+	signed, err := s.sign(accessToken)
 	if err != nil {
 		return nil, http.InternalError(err)
 	}
@@ -116,7 +430,7 @@ func (s *JWXService) AuthTokenResponse(accessToken jwt.Token) (*schema.TokenResp
 	return &schema.TokenResponse{
 		AccessToken:  string(signed),
 		TokenType:    "Bearer",
-		ExpiresIn:    int(viper.GetDuration("access_token_ttl").Seconds()),
+		ExpiresIn:    int(time.Until(accessToken.Expiration()).Round(time.Second).Seconds()),
 		RefreshToken: "HttpOnly",
 	}, nil
 }
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"github.com/sultaniman/confetti/platform/entities"
+	"github.com/sultaniman/confetti/platform/http"
+	"github.com/sultaniman/confetti/platform/mailer"
+	"github.com/sultaniman/confetti/platform/repo"
+	"strings"
+	"time"
+)
+
+// LockoutPolicy describes how failed sign in attempts are throttled, every failure
+// doubles the delay before the next attempt and after MaxAccountFailures (or
+// MaxIPFailures for a client address) attempts are blocked for LockoutDuration.
+type LockoutPolicy struct {
+	MaxAccountFailures int
+	MaxIPFailures      int
+	Window             time.Duration
+	LockoutDuration    time.Duration
+	BackoffBase        time.Duration
+	BackoffMax         time.Duration
+}
+
+func LockoutPolicyFromConfig() *LockoutPolicy {
+	return &LockoutPolicy{
+		MaxAccountFailures: viper.GetInt("lockout_max_failures"),
+		MaxIPFailures:      viper.GetInt("lockout_max_ip_failures"),
+		Window:             viper.GetDuration("lockout_window"),
+		LockoutDuration:    viper.GetDuration("lockout_duration"),
+		BackoffBase:        viper.GetDuration("lockout_backoff_base"),
+		BackoffMax:         viper.GetDuration("lockout_backoff_max"),
+	}
+}
+
+type LockoutService interface {
+	// Check returns error and how long to wait if attempts are throttled,
+	// only client address is checked when e-mail is not known yet.
+	Check(email string, ip string) (time.Duration, error)
+	RegisterFailure(email string, ip string)
+	RegisterSuccess(email string)
+	// Clear unlocks account and writes audit record of admin who did it in the same transaction
+	Clear(userId uuid.UUID, auditRecord *entities.NewAdminAuditRecord) error
+	// DeleteStale removes counters which no longer throttle anything
+	DeleteStale() error
+}
+
+type lockoutService struct {
+	policy       *LockoutPolicy
+	attemptsRepo repo.LoginAttemptRepo
+	usersRepo    repo.UserRepo
+	mailHandler  mailer.Mailer
+}
+
+func NewLockoutService(policy *LockoutPolicy, attemptsRepo repo.LoginAttemptRepo, usersRepo repo.UserRepo, mailHandler mailer.Mailer) LockoutService {
+	return &lockoutService{
+		policy:       policy,
+		attemptsRepo: attemptsRepo,
+		usersRepo:    usersRepo,
+		mailHandler:  mailHandler,
+	}
+}
+
+func (l *lockoutService) Check(email string, ip string) (time.Duration, error) {
+	now := time.Now()
+	var accountAttempts *entities.LoginAttempts
+	if email != "" {
+		attempts, err := l.attemptsRepo.Get(accountKey(email))
+		if err != nil {
+			return 0, http.InternalError(err)
+		}
+
+		if retryAfter := lockedFor(attempts, now); retryAfter > 0 {
+			return retryAfter, http.AccountLockedError()
+		}
+
+		accountAttempts = attempts
+	}
+
+	ipAttempts, err := l.attemptsRepo.Get(ipKey(ip))
+	if err != nil {
+		return 0, http.InternalError(err)
+	}
+
+	if retryAfter := lockedFor(ipAttempts, now); retryAfter > 0 {
+		return retryAfter, http.TooManyRequestsError("Too many failed attempts, please try again later")
+	}
+
+	retryAfter := l.backoff(accountAttempts, now)
+	if ipRetryAfter := l.backoff(ipAttempts, now); ipRetryAfter > retryAfter {
+		retryAfter = ipRetryAfter
+	}
+
+	if retryAfter > 0 {
+		return retryAfter, http.TooManyRequestsError("Too many failed attempts, please try again later")
+	}
+
+	return 0, nil
+}
+
+// RegisterFailure counts failed attempt for both account and client address,
+// failures are counted for unknown e-mails too so that they look the same.
+// Without e-mail, e.g. for unknown passkey, only client address is counted.
+func (l *lockoutService) RegisterFailure(email string, ip string) {
+	now := time.Now()
+	if email != "" {
+		accountAttempts, err := l.attemptsRepo.RegisterFailure(accountKey(email), now, l.policy.Window)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("email", email).
+				Msg("Unable to register failed sign in attempt")
+		} else if l.shouldLock(accountAttempts, l.policy.MaxAccountFailures, now) {
+			lockedUntil := now.Add(l.policy.LockoutDuration)
+			if l.lock(accountAttempts.Key, lockedUntil) {
+				l.notify(email, lockedUntil)
+			}
+		}
+	}
+
+	ipAttempts, err := l.attemptsRepo.RegisterFailure(ipKey(ip), now, l.policy.Window)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("ip", ip).
+			Msg("Unable to register failed sign in attempt")
+	} else if l.shouldLock(ipAttempts, l.policy.MaxIPFailures, now) {
+		l.lock(ipAttempts.Key, now.Add(l.policy.LockoutDuration))
+	}
+}
+
+// RegisterSuccess resets account counters, client address counters
+// are left to expire so that own account can not be used to reset them.
+func (l *lockoutService) RegisterSuccess(email string) {
+	if err := l.attemptsRepo.Reset(accountKey(email), nil); err != nil {
+		log.Error().
+			Err(err).
+			Str("email", email).
+			Msg("Unable to reset failed sign in attempts")
+	}
+}
+
+func (l *lockoutService) Clear(userId uuid.UUID, auditRecord *entities.NewAdminAuditRecord) error {
+	user, err := l.usersRepo.Get(userId)
+	if err != nil {
+		return http.NotFoundError("User not found")
+	}
+
+	if err = l.attemptsRepo.Reset(accountKey(user.Email), auditRecord); err != nil {
+		return http.InternalError(err)
+	}
+
+	log.Info().
+		Str("user_id", userId.String()).
+		Msg("Account lockout was cleared")
+
+	return nil
+}
+
+func (l *lockoutService) DeleteStale() error {
+	deleted, err := l.attemptsRepo.DeleteStale(time.Now(), l.policy.Window)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		log.Info().
+			Int64("deleted", deleted).
+			Msg("Stale sign in attempts deleted")
+	}
+
+	return nil
+}
+
+func (l *lockoutService) shouldLock(attempts *entities.LoginAttempts, maxFailures int, now time.Time) bool {
+	return maxFailures > 0 && attempts.Failures >= maxFailures && lockedFor(attempts, now) == 0
+}
+
+func (l *lockoutService) lock(key string, lockedUntil time.Time) bool {
+	if err := l.attemptsRepo.Lock(key, lockedUntil); err != nil {
+		log.Error().
+			Err(err).
+			Str("key", key).
+			Msg("Unable to lock sign in attempts")
+
+		return false
+	}
+
+	log.Warn().
+		Str("key", key).
+		Time("locked_until", lockedUntil).
+		Msg("Sign in attempts are locked after too many failures")
+
+	return true
+}
+
+func (l *lockoutService) notify(email string, lockedUntil time.Time) {
+	user, err := l.usersRepo.GetByEmail(email)
+	if err != nil {
+		return
+	}
+
+	if err = l.mailHandler.SendLockoutNotice(user.Email, lockedUntil); err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", user.ID.String()).
+			Msg("Unable to send lockout notice")
+	}
+}
+
+// backoff returns remaining delay before the next attempt is allowed
+func (l *lockoutService) backoff(attempts *entities.LoginAttempts, now time.Time) time.Duration {
+	if attempts == nil || attempts.Failures == 0 || l.policy.BackoffBase <= 0 {
+		return 0
+	}
+
+	delay := l.policy.BackoffBase
+	for i := 1; i < attempts.Failures && delay < l.policy.BackoffMax; i++ {
+		delay *= 2
+	}
+
+	if delay > l.policy.BackoffMax {
+		delay = l.policy.BackoffMax
+	}
+
+	return attempts.LastFailureAt.Add(delay).Sub(now)
+}
+
+func lockedFor(attempts *entities.LoginAttempts, now time.Time) time.Duration {
+	if attempts == nil || attempts.LockedUntil == nil {
+		return 0
+	}
+
+	if remaining := attempts.LockedUntil.Sub(now); remaining > 0 {
+		return remaining
+	}
+
+	return 0
+}
+
+func accountKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
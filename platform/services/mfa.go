@@ -0,0 +1,262 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"github.com/sultaniman/confetti/platform/entities"
+	"github.com/sultaniman/confetti/platform/http"
+	"github.com/sultaniman/confetti/platform/keys"
+	"github.com/sultaniman/confetti/platform/repo"
+	"github.com/sultaniman/confetti/platform/schema"
+	"github.com/sultaniman/confetti/util"
+	"strings"
+	"time"
+)
+
+const RecoveryCodesCount = 10
+
+const (
+	MFAMethodTOTP         = "totp"
+	MFAMethodRecoveryCode = "recovery_code"
+)
+
+type MFAService interface {
+	EnrollTOTP(userId uuid.UUID) (*schema.TOTPEnrollmentResponse, error)
+	ConfirmTOTP(userId uuid.UUID, code string) (*schema.RecoveryCodesResponse, error)
+	DisableTOTP(userId uuid.UUID, disableRequest *schema.DisableTOTPRequest, authTime time.Time) error
+	RegenerateRecoveryCodes(userId uuid.UUID, code string) (*schema.RecoveryCodesResponse, error)
+	IsEnabled(userId uuid.UUID) bool
+	Verify(userId uuid.UUID, code string) error
+}
+
+type mfaService struct {
+	mfaRepo   repo.MFARepo
+	usersRepo repo.UserRepo
+	keyring   *keys.Keyring
+}
+
+func NewMFAService(mfaRepo repo.MFARepo, usersRepo repo.UserRepo, keyring *keys.Keyring) MFAService {
+	return &mfaService{
+		mfaRepo:   mfaRepo,
+		usersRepo: usersRepo,
+		keyring:   keyring,
+	}
+}
+
+// EnrollTOTP generates new secret which becomes active only after
+// user proves that authenticator app was set up by sending a valid code.
+func (m *mfaService) EnrollTOTP(userId uuid.UUID) (*schema.TOTPEnrollmentResponse, error) {
+	if m.IsEnabled(userId) {
+		return nil, http.Conflict("Two-factor authentication is already enabled")
+	}
+
+	user, err := m.usersRepo.Get(userId)
+	if err != nil {
+		return nil, m.handleError(err)
+	}
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	encryptedSecret, keyID, err := m.keyring.Encrypt([]byte(secret))
+	if err != nil {
+		return nil, http.EncryptionError(err)
+	}
+
+	_, err = m.mfaRepo.SaveTOTP(&entities.NewTOTP{
+		UserId:          userId,
+		EncryptedSecret: base64.StdEncoding.EncodeToString(encryptedSecret),
+		KeyID:           keyID,
+	})
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to save TOTP secret")
+
+		return nil, http.InternalError(err)
+	}
+
+	return &schema.TOTPEnrollmentResponse{
+		Secret: secret,
+		URI:    util.TOTPURI(viper.GetString("totp_issuer"), user.Email, secret),
+	}, nil
+}
+
+func (m *mfaService) ConfirmTOTP(userId uuid.UUID, code string) (*schema.RecoveryCodesResponse, error) {
+	totp, err := m.mfaRepo.GetTOTP(userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, http.NotFoundError("Two-factor authentication enrollment not found")
+		}
+
+		return nil, http.InternalError(err)
+	}
+
+	if totp.ConfirmedAt != nil {
+		return nil, http.Conflict("Two-factor authentication is already enabled")
+	}
+
+	if err = m.verifyTOTP(totp, code); err != nil {
+		return nil, err
+	}
+
+	if err = m.mfaRepo.ConfirmTOTP(userId); err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	return m.newRecoveryCodes(userId)
+}
+
+func (m *mfaService) DisableTOTP(userId uuid.UUID, disableRequest *schema.DisableTOTPRequest, authTime time.Time) error {
+	user, err := m.usersRepo.Get(userId)
+	if err != nil {
+		return m.handleError(err)
+	}
+
+	if err = verifyPassword(user.Password, disableRequest.Password, authTime); err != nil {
+		return err
+	}
+
+	if err = m.Verify(userId, disableRequest.Code); err != nil {
+		return err
+	}
+
+	if err = m.mfaRepo.DeleteTOTP(userId); err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to disable two-factor authentication")
+
+		return http.InternalError(err)
+	}
+
+	return nil
+}
+
+func (m *mfaService) RegenerateRecoveryCodes(userId uuid.UUID, code string) (*schema.RecoveryCodesResponse, error) {
+	totp, err := m.getConfirmedTOTP(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = m.verifyTOTP(totp, code); err != nil {
+		return nil, err
+	}
+
+	return m.newRecoveryCodes(userId)
+}
+
+func (m *mfaService) IsEnabled(userId uuid.UUID) bool {
+	totp, err := m.mfaRepo.GetTOTP(userId)
+	return err == nil && totp.ConfirmedAt != nil
+}
+
+// Verify accepts either TOTP code or one of unused recovery codes
+func (m *mfaService) Verify(userId uuid.UUID, code string) error {
+	totp, err := m.getConfirmedTOTP(userId)
+	if err != nil {
+		return err
+	}
+
+	code = strings.ToLower(strings.TrimSpace(code))
+	if len(code) == util.TOTPDigits {
+		return m.verifyTOTP(totp, code)
+	}
+
+	used, err := m.mfaRepo.UseRecoveryCode(userId, util.HashToken(code))
+	if err != nil {
+		return http.InternalError(err)
+	}
+
+	if !used {
+		return http.UnauthorizedError("Invalid verification code")
+	}
+
+	log.Info().
+		Str("user_id", userId.String()).
+		Msg("Recovery code was used")
+
+	return nil
+}
+
+func (m *mfaService) verifyTOTP(totp *entities.TOTP, code string) error {
+	decodedSecret, err := base64.StdEncoding.DecodeString(totp.EncryptedSecret)
+	if err != nil {
+		return http.DecodingError(err)
+	}
+
+	secret, err := m.keyring.Decrypt(totp.KeyID, decodedSecret)
+	if err != nil {
+		return http.DecryptionError(err)
+	}
+
+	step, valid := util.ValidateTOTP(string(secret), code, time.Now())
+	if !valid {
+		return http.UnauthorizedError("Invalid verification code")
+	}
+
+	used, err := m.mfaRepo.UseTOTPStep(totp.UserId, step)
+	if err != nil {
+		return http.InternalError(err)
+	}
+
+	if !used {
+		return http.UnauthorizedError("Verification code has already been used")
+	}
+
+	return nil
+}
+
+func (m *mfaService) getConfirmedTOTP(userId uuid.UUID) (*entities.TOTP, error) {
+	totp, err := m.mfaRepo.GetTOTP(userId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, http.InternalError(err)
+	}
+
+	if err != nil || totp.ConfirmedAt == nil {
+		return nil, http.BadRequestWithMessage("Two-factor authentication is not enabled")
+	}
+
+	return totp, nil
+}
+
+func (m *mfaService) newRecoveryCodes(userId uuid.UUID) (*schema.RecoveryCodesResponse, error) {
+	codes, err := util.GenerateRecoveryCodes(RecoveryCodesCount)
+	if err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	codeHashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		codeHashes = append(codeHashes, util.HashToken(code))
+	}
+
+	if err = m.mfaRepo.ReplaceRecoveryCodes(userId, codeHashes); err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to save recovery codes")
+
+		return nil, http.InternalError(err)
+	}
+
+	return &schema.RecoveryCodesResponse{
+		Codes: codes,
+	}, nil
+}
+
+func (m *mfaService) handleError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.NotFoundError("User not found")
+	} else {
+		return http.InternalError(err)
+	}
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/sultaniman/confetti/platform/entities"
+	"github.com/sultaniman/confetti/platform/http"
+	"github.com/sultaniman/confetti/platform/oidc"
+	"github.com/sultaniman/confetti/platform/repo"
+	"github.com/sultaniman/confetti/platform/schema"
+	"github.com/sultaniman/confetti/util"
+	"sort"
+	"time"
+)
+
+// OIDCStateTTL is how long user has to complete sign in at provider
+const OIDCStateTTL = 10 * time.Minute
+
+// OIDCStateCookieName binds sign in request to the browser which started it,
+// cookie keeps hash of the state so callback with foreign state is rejected.
+const OIDCStateCookieName = "oidc_state"
+
+type OIDCService interface {
+	Providers() []string
+	Authorize(ctx context.Context, providerName string) (string, *fiber.Cookie, error)
+	Callback(ctx context.Context, providerName string, callback *schema.OIDCCallbackRequest) (*schema.UserResponse, error)
+}
+
+type oidcService struct {
+	providers    map[string]*oidc.Provider
+	oidcRepo     repo.OIDCRepo
+	usersRepo    repo.UserRepo
+	usersService UserService
+}
+
+func NewOIDCService(
+	providers map[string]*oidc.Provider,
+	oidcRepo repo.OIDCRepo,
+	usersRepo repo.UserRepo,
+	usersService UserService,
+) OIDCService {
+	return &oidcService{
+		providers:    providers,
+		oidcRepo:     oidcRepo,
+		usersRepo:    usersRepo,
+		usersService: usersService,
+	}
+}
+
+func (o *oidcService) Providers() []string {
+	names := make([]string, 0, len(o.providers))
+	for name := range o.providers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Authorize starts authorization code flow and returns url of provider sign in page
+// together with state cookie which must be set in user's browser.
+func (o *oidcService) Authorize(ctx context.Context, providerName string) (string, *fiber.Cookie, error) {
+	provider, err := o.provider(providerName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var values [3]string
+	for i := range values {
+		if values[i], err = oidc.RandomString(); err != nil {
+			return "", nil, http.InternalError(err)
+		}
+	}
+
+	state, nonce, codeVerifier := values[0], values[1], values[2]
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("provider", providerName).
+			Msg("Unable to build authorization url")
+
+		return "", nil, http.InternalErrorWithMessage("Identity provider is not available")
+	}
+
+	expiresAt := time.Now().Add(OIDCStateTTL)
+	err = o.oidcRepo.CreateState(&entities.NewOIDCState{
+		State:        state,
+		Provider:     providerName,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		ExpiresAt:    expiresAt,
+	})
+
+	if err != nil {
+		return "", nil, http.InternalError(err)
+	}
+
+	// provider redirects back with top-level navigation so cookie can not be strict
+	return authURL, &fiber.Cookie{
+		Name:     OIDCStateCookieName,
+		Value:    util.HashToken(state),
+		Expires:  expiresAt,
+		Secure:   true,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	}, nil
+}
+
+// Callback completes authorization code flow and returns local user, identities are
+// linked to existing confirmed users by verified e-mail and new users are created without password.
+func (o *oidcService) Callback(ctx context.Context, providerName string, callback *schema.OIDCCallbackRequest) (*schema.UserResponse, error) {
+	provider, err := o.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	// state which was not issued to this browser is not consumed
+	stateHash := util.HashToken(callback.State)
+	if subtle.ConstantTimeCompare([]byte(stateHash), []byte(callback.StateHash)) != 1 {
+		return nil, http.UnauthorizedError("Invalid or expired sign in request")
+	}
+
+	state, err := o.oidcRepo.ConsumeState(callback.State, providerName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, http.UnauthorizedError("Invalid or expired sign in request")
+		}
+
+		return nil, http.InternalError(err)
+	}
+
+	tokenResponse, err := provider.Exchange(ctx, callback.Code, state.CodeVerifier)
+	if err != nil {
+		return nil, o.providerError(providerName, err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, tokenResponse.IDToken, state.Nonce)
+	if err != nil {
+		return nil, o.providerError(providerName, err)
+	}
+
+	identity, err := o.oidcRepo.GetIdentity(providerName, claims.Subject)
+	if err == nil {
+		if err = o.oidcRepo.TouchIdentity(identity.ID); err != nil {
+			log.Error().
+				Err(err).
+				Str("user_id", identity.UserId.String()).
+				Msg("Unable to update identity last login")
+		}
+
+		return o.usersService.Get(identity.UserId)
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, http.InternalError(err)
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, http.UnauthorizedError("Identity provider did not return verified e-mail")
+	}
+
+	user, err := o.findOrCreateUser(providerName, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = o.oidcRepo.CreateIdentity(&entities.NewUserIdentity{
+		UserId:   user.ID,
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+
+	if err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	log.Info().
+		Str("user_id", user.ID.String()).
+		Str("provider", providerName).
+		Msg("Identity linked")
+
+	return o.usersService.Get(user.ID)
+}
+
+func (o *oidcService) findOrCreateUser(providerName string, claims *oidc.Claims) (*entities.User, error) {
+	// unconfirmed account may have been registered by someone else who knows its password
+	user, err := o.usersRepo.GetByEmail(claims.Email)
+	if err == nil {
+		if !user.IsConfirmed {
+			log.Warn().
+				Str("user_id", user.ID.String()).
+				Str("provider", providerName).
+				Msg("Identity not linked to unconfirmed user")
+
+			return nil, http.ForbiddenError("Please confirm your e-mail before signing in with identity provider")
+		}
+
+		return user, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, http.InternalError(err)
+	}
+
+	// provider users sign in without password, empty hash never matches
+	user, err = o.usersRepo.Create(&entities.NewUser{
+		FullName:    claims.Name,
+		Email:       claims.Email,
+		Password:    "",
+		IsActive:    true,
+		IsConfirmed: true,
+		Settings:    []byte("{}"),
+		Provider:    providerName,
+	})
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("provider", providerName).
+			Msg("Unable to create user")
+
+		return nil, http.InternalError(err)
+	}
+
+	return user, nil
+}
+
+func (o *oidcService) provider(providerName string) (*oidc.Provider, error) {
+	provider, ok := o.providers[providerName]
+	if !ok {
+		return nil, http.NotFoundError("Identity provider not found")
+	}
+
+	return provider, nil
+}
+
+func (o *oidcService) providerError(providerName string, err error) error {
+	log.Warn().
+		Err(err).
+		Str("provider", providerName).
+		Msg("Sign in with identity provider failed")
+
+	return http.UnauthorizedError("Unable to sign in with identity provider")
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/sultaniman/confetti/platform/entities"
+	"github.com/sultaniman/confetti/platform/http"
+	"github.com/sultaniman/confetti/platform/repo"
+	"github.com/sultaniman/confetti/platform/schema"
+	"github.com/sultaniman/confetti/util"
+	"strings"
+	"time"
+)
+
+// PersonalTokenPrefix tells personal access tokens apart from JWTs
+const PersonalTokenPrefix = "cfpat_"
+
+const (
+	personalTokenSize       = 32
+	personalTokenShownChars = 6
+	// personalTokenTouchEvery limits how often last use is written
+	personalTokenTouchEvery = time.Minute
+)
+
+// PersonalTokenScopes are scopes which can be granted to personal access tokens,
+// decryption requires recent authentication which long-lived tokens can not prove.
+var PersonalTokenScopes = []string{ScopeCardsRead, ScopeCardsWrite}
+
+// PersonalTokenService manages long-lived tokens for scripts and CI,
+// only hash of the token is stored and it is shown once on creation.
+type PersonalTokenService interface {
+	Create(userId uuid.UUID, tokenRequest *schema.NewPersonalTokenRequest) (*schema.NewPersonalTokenResponse, error)
+	List(userId uuid.UUID) ([]schema.PersonalTokenResponse, error)
+	Revoke(userId uuid.UUID, tokenId uuid.UUID) error
+	Verify(token string) (*schema.PersonalTokenClaims, error)
+}
+
+type personalTokenService struct {
+	tokensRepo repo.PersonalTokenRepo
+	usersRepo  repo.UserRepo
+}
+
+func NewPersonalTokenService(tokensRepo repo.PersonalTokenRepo, usersRepo repo.UserRepo) PersonalTokenService {
+	return &personalTokenService{
+		tokensRepo: tokensRepo,
+		usersRepo:  usersRepo,
+	}
+}
+
+func (p *personalTokenService) Create(userId uuid.UUID, tokenRequest *schema.NewPersonalTokenRequest) (*schema.NewPersonalTokenResponse, error) {
+	name := strings.TrimSpace(tokenRequest.Name)
+	if name == "" {
+		return nil, http.BadRequestWithMessage("Please provide token name")
+	}
+
+	if len(tokenRequest.Scopes) == 0 {
+		return nil, http.BadRequestWithMessage("Please provide at least one scope")
+	}
+
+	for _, scope := range tokenRequest.Scopes {
+		if !hasScope(PersonalTokenScopes, scope) {
+			return nil, http.BadRequestWithMessage("Unknown scope " + scope)
+		}
+	}
+
+	if tokenRequest.ExpiresAt != nil && tokenRequest.ExpiresAt.Before(time.Now()) {
+		return nil, http.BadRequestWithMessage("Expiration must be in the future")
+	}
+
+	raw := make([]byte, personalTokenSize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	token := PersonalTokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	tokenRecord, err := p.tokensRepo.Create(&entities.NewPersonalToken{
+		UserId:      userId,
+		Name:        name,
+		TokenHash:   util.HashToken(token),
+		TokenPrefix: token[:len(PersonalTokenPrefix)+personalTokenShownChars],
+		Scopes:      strings.Join(tokenRequest.Scopes, " "),
+		ExpiresAt:   tokenRequest.ExpiresAt,
+	})
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to create personal access token")
+
+		return nil, http.InternalError(err)
+	}
+
+	log.Info().
+		Str("user_id", userId.String()).
+		Str("token_id", tokenRecord.ID.String()).
+		Msg("Personal access token created")
+
+	return &schema.NewPersonalTokenResponse{
+		PersonalTokenResponse: *p.tokenToResponse(tokenRecord),
+		Token:                 token,
+	}, nil
+}
+
+func (p *personalTokenService) List(userId uuid.UUID) ([]schema.PersonalTokenResponse, error) {
+	tokens, err := p.tokensRepo.List(userId)
+	if err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	tokensResponse := make([]schema.PersonalTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		tokensResponse = append(tokensResponse, *p.tokenToResponse(&token))
+	}
+
+	return tokensResponse, nil
+}
+
+func (p *personalTokenService) Revoke(userId uuid.UUID, tokenId uuid.UUID) error {
+	deleted, err := p.tokensRepo.Delete(userId, tokenId)
+	if err != nil {
+		return http.InternalError(err)
+	}
+
+	if !deleted {
+		return http.NotFoundError("Token not found")
+	}
+
+	log.Info().
+		Str("user_id", userId.String()).
+		Str("token_id", tokenId.String()).
+		Msg("Personal access token revoked")
+
+	return nil
+}
+
+// Verify authenticates personal access token, tokens of inactive users are rejected
+func (p *personalTokenService) Verify(token string) (*schema.PersonalTokenClaims, error) {
+	if !strings.HasPrefix(token, PersonalTokenPrefix) {
+		return nil, http.UnauthorizedError("Invalid token")
+	}
+
+	tokenRecord, err := p.tokensRepo.GetByHash(util.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, http.UnauthorizedError("Invalid token")
+		}
+
+		return nil, http.InternalError(err)
+	}
+
+	now := time.Now()
+	if tokenRecord.ExpiresAt != nil && tokenRecord.ExpiresAt.Before(now) {
+		return nil, http.UnauthorizedError("Token has expired")
+	}
+
+	user, err := p.usersRepo.Get(tokenRecord.UserId)
+	if err != nil || !user.IsActive {
+		return nil, http.UnauthorizedError("Invalid token")
+	}
+
+	if err = p.tokensRepo.Touch(tokenRecord.ID, now, personalTokenTouchEvery); err != nil {
+		log.Error().
+			Err(err).
+			Str("token_id", tokenRecord.ID.String()).
+			Msg("Unable to update personal access token last use")
+	}
+
+	// scopes which are no longer granted to personal access tokens are dropped
+	var scopes []string
+	for _, scope := range strings.Fields(tokenRecord.Scopes) {
+		if hasScope(PersonalTokenScopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &schema.PersonalTokenClaims{
+		TokenId: tokenRecord.ID,
+		UserId:  tokenRecord.UserId,
+		Scopes:  scopes,
+	}, nil
+}
+
+func (p *personalTokenService) tokenToResponse(token *entities.PersonalToken) *schema.PersonalTokenResponse {
+	return &schema.PersonalTokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		Prefix:     token.TokenPrefix,
+		Scopes:     strings.Fields(token.Scopes),
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"github.com/lestrrat-go/jwx/jwt"
+	"strings"
+)
+
+// ScopeClaim keeps space separated scopes of access token (RFC 8693)
+const ScopeClaim = "scope"
+
+const (
+	ScopeCardsRead    = "cards:read"
+	ScopeCardsWrite   = "cards:write"
+	ScopeCardsDecrypt = "cards:decrypt"
+	// ScopeAccount allows to manage own account, sessions and second factors
+	ScopeAccount = "account"
+	// ScopeAdmin is only granted to admins
+	ScopeAdmin = "admin"
+)
+
+// UserScopes is default scope set of access tokens issued at sign in
+func UserScopes(isAdmin bool) []string {
+	scopes := []string{ScopeCardsRead, ScopeCardsWrite, ScopeCardsDecrypt, ScopeAccount}
+	if isAdmin {
+		scopes = append(scopes, ScopeAdmin)
+	}
+
+	return scopes
+}
+
+// ParseScopes splits scope claim, value of other types yields no scopes
+func ParseScopes(claim interface{}) []string {
+	scope, ok := claim.(string)
+	if !ok {
+		return nil
+	}
+
+	return strings.Fields(scope)
+}
+
+// TokenScopes returns scopes granted to access token, token without scope claim has no scopes
+func TokenScopes(token jwt.Token) []string {
+	scope, _ := token.Get(ScopeClaim)
+	return ParseScopes(scope)
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
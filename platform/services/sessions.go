@@ -0,0 +1,110 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/sultaniman/confetti/platform/entities"
+	"github.com/sultaniman/confetti/platform/http"
+	"github.com/sultaniman/confetti/platform/repo"
+	"github.com/sultaniman/confetti/platform/schema"
+)
+
+// SessionService manages refresh token families of a user,
+// revoking a session does not invalidate already issued access tokens
+// however they can not be refreshed once they expire. Revoking all
+// sessions also revokes personal access tokens.
+type SessionService interface {
+	List(userId uuid.UUID, refreshTokenCookie string) ([]schema.SessionResponse, error)
+	Revoke(userId uuid.UUID, sessionId uuid.UUID) error
+	RevokeAll(userId uuid.UUID) error
+}
+
+type sessionService struct {
+	tokensRepo repo.TokenRepo
+	jwxService *JWXService
+}
+
+func NewSessionService(tokensRepo repo.TokenRepo, jwxService *JWXService) SessionService {
+	return &sessionService{
+		tokensRepo: tokensRepo,
+		jwxService: jwxService,
+	}
+}
+
+func (s *sessionService) List(userId uuid.UUID, refreshTokenCookie string) ([]schema.SessionResponse, error) {
+	sessions, err := s.tokensRepo.ListSessions(userId)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to list sessions")
+
+		return nil, http.InternalError(err)
+	}
+
+	var currentSession *uuid.UUID
+	if refreshTokenCookie != "" {
+		currentSession, _ = s.jwxService.RefreshTokenFamily(refreshTokenCookie)
+	}
+
+	sessionsResponse := make([]schema.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		sessionResponse := s.sessionToResponse(&session)
+		sessionResponse.Current = currentSession != nil && *currentSession == session.FamilyId
+		sessionsResponse = append(sessionsResponse, *sessionResponse)
+	}
+
+	return sessionsResponse, nil
+}
+
+func (s *sessionService) Revoke(userId uuid.UUID, sessionId uuid.UUID) error {
+	revoked, err := s.tokensRepo.RevokeSession(userId, sessionId)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Str("session_id", sessionId.String()).
+			Msg("Unable to revoke session")
+
+		return http.InternalError(err)
+	}
+
+	if !revoked {
+		return http.NotFoundError("Session not found")
+	}
+
+	return nil
+}
+
+func (s *sessionService) RevokeAll(userId uuid.UUID) error {
+	err := s.tokensRepo.RevokeAll(userId)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to revoke sessions")
+
+		return http.InternalError(err)
+	}
+
+	return nil
+}
+
+func (s *sessionService) sessionToResponse(session *entities.Session) *schema.SessionResponse {
+	sessionResponse := &schema.SessionResponse{
+		ID:         session.FamilyId,
+		StartedAt:  session.StartedAt,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+
+	if session.UserAgent != nil {
+		sessionResponse.UserAgent = *session.UserAgent
+	}
+
+	if session.IPAddress != nil {
+		sessionResponse.IPAddress = *session.IPAddress
+	}
+
+	return sessionResponse
+}
@@ -6,46 +6,66 @@ import (
 	"github.com/google/uuid"
 	"github.com/omeid/pgerror"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
 	"github.com/sultaniman/confetti/platform/entities"
 	"github.com/sultaniman/confetti/platform/http"
 	"github.com/sultaniman/confetti/platform/mailer"
 	"github.com/sultaniman/confetti/platform/repo"
 	"github.com/sultaniman/confetti/platform/schema"
 	"github.com/sultaniman/confetti/util"
+	"strings"
 	"time"
 )
 
+// Default lifetime of action codes, can be changed with confirmation_code_ttl,
+// password_reset_code_ttl, email_change_code_ttl and magic_link_code_ttl.
 const (
 	UserConfirmationTTL = time.Hour
 	PasswordResetTTL    = 15 * time.Minute
+	EmailChangeTTL      = time.Hour
+	MagicLinkTTL        = 15 * time.Minute
 )
 
+// DeletionPurgeBatch limits how many accounts are purged in one pass
+const DeletionPurgeBatch = 100
+
 type UserService interface {
 	Get(id uuid.UUID) (*schema.UserResponse, error)
 	GetByEmail(email string) (*schema.UserResponse, error)
+	List(listRequest *schema.UserListRequest) (*schema.UsersResponse, error)
 	Create(user *schema.NewUserRequest) (*schema.UserResponse, error)
 	Update(userId uuid.UUID, user *schema.UpdateUserRequest) (*schema.UserResponse, error)
-	UpdateEmail(userId uuid.UUID, user *schema.UpdateUserEmailRequest) (*schema.UserResponse, error)
-	UpdatePassword(userId uuid.UUID, emailUpdate *schema.UpdateUserPasswordRequest) (*schema.UserResponse, error)
-	ResetPasswordRequest(email string) (*schema.ActionCode, error)
-	GetResetPasswordCode(code string) (*schema.ActionCode, error)
+	UpdateEmail(userId uuid.UUID, user *schema.UpdateUserEmailRequest, authTime time.Time) (*schema.UserResponse, error)
+	ConfirmEmailChange(userId uuid.UUID, code string) (*schema.UserResponse, error)
+	UpdatePassword(userId uuid.UUID, passwordUpdate *schema.UpdateUserPasswordRequest, authTime time.Time) (*schema.UserResponse, error)
+	ResetPasswordRequest(email string, auditRecord *entities.NewAdminAuditRecord) (*schema.ActionCode, error)
+	ResetPasswordWithCode(code string, newPassword string) error
+	MagicLinkRequest(email string) (*schema.ActionCode, error)
+	MagicLinkOwner(code string) (*schema.UserResponse, error)
+	ConsumeMagicLink(code string) (*schema.UserResponse, error)
 	ResetPassword(userId uuid.UUID, newPassword string) error
 	CreateConfirmation(userId uuid.UUID) (*schema.ActionCode, error)
 	ResendConfirmation(userId uuid.UUID) error
 	ConfirmUser(userId uuid.UUID, code string) error
-	Delete(id uuid.UUID) (*schema.UserResponse, error)
+	Delete(id uuid.UUID, auditRecord *entities.NewAdminAuditRecord) (*schema.UserResponse, error)
+	RequestDeletion(userId uuid.UUID, deleteRequest *schema.DeleteAccountRequest, authTime time.Time) (*schema.UserResponse, error)
+	CancelDeletion(userId uuid.UUID) (*schema.UserResponse, error)
+	PurgeDeleted() error
+	DeleteExpiredActionCodes() error
 	Exists(userId uuid.UUID) bool
 	EmailExists(email string) bool
 }
 
 type userService struct {
 	usersRepo   repo.UserRepo
+	tokensRepo  repo.TokenRepo
 	mailHandler mailer.Mailer
 }
 
-func NewUserService(usersRepo repo.UserRepo, mailHandler mailer.Mailer) UserService {
+func NewUserService(usersRepo repo.UserRepo, tokensRepo repo.TokenRepo, mailHandler mailer.Mailer) UserService {
 	return &userService{
 		usersRepo:   usersRepo,
+		tokensRepo:  tokensRepo,
 		mailHandler: mailHandler,
 	}
 }
@@ -92,6 +112,40 @@ func (s *userService) GetByEmail(email string) (*schema.UserResponse, error) {
 	return s.userToResponse(user), nil
 }
 
+// List returns page of users and total number of users matching filters
+func (s *userService) List(listRequest *schema.UserListRequest) (*schema.UsersResponse, error) {
+	filterSpec := &repo.UserFilterSpec{
+		Search:      listRequest.Search,
+		IsActive:    listRequest.IsActive,
+		IsConfirmed: listRequest.IsConfirmed,
+		IsAdmin:     listRequest.IsAdmin,
+		Provider:    listRequest.Provider,
+		Limit:       listRequest.Limit,
+		Offset:      listRequest.Offset,
+	}
+
+	userCount, err := s.usersRepo.Count(filterSpec)
+	if err != nil {
+		return nil, http.FetchError(err, "Unable to count users")
+	}
+
+	users, err := s.usersRepo.List(filterSpec)
+	if err != nil {
+		return nil, http.FetchError(err, "Unable to fetch users")
+	}
+
+	usersResponse := &schema.UsersResponse{
+		Count: userCount,
+		Users: make([]*schema.UserResponse, 0, len(users)),
+	}
+
+	for _, user := range users {
+		usersResponse.Users = append(usersResponse.Users, s.userToResponse(&user))
+	}
+
+	return usersResponse, nil
+}
+
 func (s *userService) Create(newUserRequest *schema.NewUserRequest) (*schema.UserResponse, error) {
 	if !util.IsStrongPassword(newUserRequest.Password) {
 		log.Warn().Msg("Password is weak")
@@ -172,7 +226,9 @@ func (s *userService) Update(userId uuid.UUID, userUpdate *schema.UpdateUserRequ
 	return response, nil
 }
 
-func (s *userService) UpdateEmail(userId uuid.UUID, emailUpdate *schema.UpdateUserEmailRequest) (*schema.UserResponse, error) {
+// UpdateEmail starts email change, current address stays in use until
+// the confirmation link sent to the new address is followed.
+func (s *userService) UpdateEmail(userId uuid.UUID, emailUpdate *schema.UpdateUserEmailRequest, authTime time.Time) (*schema.UserResponse, error) {
 	if !s.usersRepo.Exists(userId) {
 		log.Info().
 			Str("user_id", userId.String()).
@@ -183,42 +239,104 @@ func (s *userService) UpdateEmail(userId uuid.UUID, emailUpdate *schema.UpdateUs
 
 	user, err := s.usersRepo.Get(userId)
 	if err != nil {
-		if e := pgerror.UniqueViolation(err); e != nil {
-			log.Error().
-				Err(err).
-				Msg("Email already exists")
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg(fmt.Sprintf("Unable to get password hash for user"))
 
-			return nil, http.Conflict("Email already exists")
-		} else {
-			log.Error().
-				Err(err).
-				Str("user_id", userId.String()).
-				Msg(fmt.Sprintf("Unable to get password hash for user"))
+		return nil, http.InternalError(err)
+	}
 
-			return nil, err
-		}
+	if err = verifyPassword(user.Password, emailUpdate.Password, authTime); err != nil {
+		return nil, err
 	}
 
-	err = util.CheckPassword(user.Password, emailUpdate.Password)
+	if strings.EqualFold(user.Email, emailUpdate.Email) {
+		return nil, http.BadRequestWithMessage("New email is the same as current one")
+	}
+
+	if s.usersRepo.EmailExists(emailUpdate.Email) {
+		return nil, http.Conflict("Email already exists")
+	}
+
+	emailChange, err := s.createActionCode(&entities.ActionCodeRequest{
+		Type:     entities.EmailChanges,
+		Email:    user.Email,
+		NewEmail: emailUpdate.Email,
+	})
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to create email change")
+
+		return nil, http.InternalError(err)
+	}
+
+	if err = s.mailHandler.SendEmailChangeCode(emailUpdate.Email, emailChange.Code); err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to send email change confirmation")
+
+		return nil, http.InternalError(err)
+	}
+
+	if err = s.mailHandler.SendEmailChangeNotice(user.Email, emailUpdate.Email); err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to send email change notice")
+	}
+
+	return s.userToResponse(user), nil
+}
+
+// ConfirmEmailChange commits pending email change, other pending changes are discarded
+func (s *userService) ConfirmEmailChange(userId uuid.UUID, code string) (*schema.UserResponse, error) {
+	actionCode, err := s.usersRepo.ConsumeActionCode(&entities.ActionCodeCheck{
+		Type:     entities.EmailChanges,
+		CodeHash: util.HashToken(code),
+		UserId:   &userId,
+	})
+
 	if err != nil {
-		return nil, http.InvalidPasswordError()
+		return nil, s.actionCodeError(err, "Email change code not found or has expired")
+	}
+
+	if actionCode.NewEmail == nil {
+		return nil, http.NotFoundError("Email change code not found or has expired")
 	}
 
-	updatedUser, err := s.usersRepo.UpdateEmail(userId, emailUpdate.Email)
+	user, err := s.usersRepo.CommitEmailChange(userId, *actionCode.NewEmail)
 	if err != nil {
+		if e := pgerror.UniqueViolation(err); e != nil {
+			log.Error().
+				Err(err).
+				Msg("Email already exists")
+
+			return nil, http.Conflict("Email already exists")
+		}
+
 		log.Error().
 			Err(err).
 			Str("user_id", userId.String()).
-			Msg(fmt.Sprintf("Unable to update user"))
+			Msg("Unable to change email")
 
 		return nil, http.InternalError(err)
 	}
 
-	response := s.userToResponse(updatedUser)
-	return response, nil
+	log.Info().
+		Str("user_id", userId.String()).
+		Msg("Email changed")
+
+	return s.userToResponse(user), nil
 }
 
-func (s *userService) UpdatePassword(userId uuid.UUID, passwordUpdate *schema.UpdateUserPasswordRequest) (*schema.UserResponse, error) {
+// UpdatePassword requires old password, users without password
+// set the first one right after signing in with identity provider.
+func (s *userService) UpdatePassword(userId uuid.UUID, passwordUpdate *schema.UpdateUserPasswordRequest, authTime time.Time) (*schema.UserResponse, error) {
 	if !s.usersRepo.Exists(userId) {
 		return nil, http.NotFoundError("User not found")
 	}
@@ -238,9 +356,8 @@ func (s *userService) UpdatePassword(userId uuid.UUID, passwordUpdate *schema.Up
 		return nil, http.InternalError(err)
 	}
 
-	err = util.CheckPassword(user.Password, passwordUpdate.OldPassword)
-	if err != nil {
-		return nil, http.InvalidPasswordError()
+	if err = verifyPassword(user.Password, passwordUpdate.OldPassword, authTime); err != nil {
+		return nil, err
 	}
 
 	password, err := util.HashPassword(passwordUpdate.NewPassword)
@@ -262,28 +379,25 @@ func (s *userService) UpdatePassword(userId uuid.UUID, passwordUpdate *schema.Up
 		return nil, http.InternalError(err)
 	}
 
+	if err = s.revokeSessions(userId); err != nil {
+		return nil, err
+	}
+
 	return s.userToResponse(updatedUser), nil
 }
 
 func (s *userService) ConfirmUser(userId uuid.UUID, code string) error {
-	actionCode, err := s.usersRepo.GetActionCode(&entities.ActionCodeCheck{
-		Type: entities.UserConfirmations,
-		Code: code,
+	actionCode, err := s.usersRepo.ConsumeActionCode(&entities.ActionCodeCheck{
+		Type:     entities.UserConfirmations,
+		CodeHash: util.HashToken(code),
+		UserId:   &userId,
 	})
 
 	if err != nil {
-		return http.NotFoundError("Confirmation code not found")
-	}
-
-	if userId != actionCode.UserId {
-		return http.Conflict("Confirmation belongs to another user")
+		return s.actionCodeError(err, "Confirmation code not found or has expired")
 	}
 
-	if actionCode.CreatedAt.Add(UserConfirmationTTL).Before(time.Now().UTC()) {
-		return http.Conflict("Confirmation code has already expired")
-	}
-
-	_, err = s.usersRepo.ConfirmUser(actionCode.UserId)
+	_, err = s.usersRepo.ConfirmUser(actionCode.UserId, nil)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -296,12 +410,13 @@ func (s *userService) ConfirmUser(userId uuid.UUID, code string) error {
 	return nil
 }
 
-func (s *userService) Delete(userId uuid.UUID) (*schema.UserResponse, error) {
+// Delete removes user, when audit record is given it is written in the same transaction
+func (s *userService) Delete(userId uuid.UUID, auditRecord *entities.NewAdminAuditRecord) (*schema.UserResponse, error) {
 	if !s.usersRepo.Exists(userId) {
 		return nil, http.NotFoundError("User not found")
 	}
 
-	user, err := s.usersRepo.Delete(userId)
+	user, err := s.usersRepo.Delete(userId, auditRecord)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -315,14 +430,119 @@ func (s *userService) Delete(userId uuid.UUID) (*schema.UserResponse, error) {
 	return response, nil
 }
 
-func (s *userService) ResetPasswordRequest(email string) (*schema.ActionCode, error) {
+// RequestDeletion disables account and signs user out everywhere,
+// account can be restored until grace period ends and it gets purged.
+func (s *userService) RequestDeletion(userId uuid.UUID, deleteRequest *schema.DeleteAccountRequest, authTime time.Time) (*schema.UserResponse, error) {
+	user, err := s.usersRepo.Get(userId)
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+
+	if user.DeletionRequestedAt != nil {
+		return nil, http.Conflict("Account deletion has already been requested")
+	}
+
+	if err = verifyPassword(user.Password, deleteRequest.Password, authTime); err != nil {
+		return nil, err
+	}
+
+	updatedUser, err := s.usersRepo.ScheduleDeletion(userId, time.Now())
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to schedule account deletion")
+
+		return nil, http.InternalError(err)
+	}
+
+	if err = s.revokeSessions(userId); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("user_id", userId.String()).
+		Msg("Account deletion requested")
+
+	return s.userToResponse(updatedUser), nil
+}
+
+func (s *userService) CancelDeletion(userId uuid.UUID) (*schema.UserResponse, error) {
+	user, err := s.usersRepo.CancelDeletion(userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.Conflict("Account deletion was not requested")
+		}
+
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to cancel account deletion")
+
+		return nil, http.InternalError(err)
+	}
+
+	log.Info().
+		Str("user_id", userId.String()).
+		Msg("Account restored")
+
+	return s.userToResponse(user), nil
+}
+
+// PurgeDeleted permanently deletes accounts whose grace period
+// has ended and sends final confirmation to each of them,
+// afterwards card audit records of deleted users are anonymized.
+func (s *userService) PurgeDeleted() error {
+	requestedBefore := time.Now().Add(-viper.GetDuration("deletion_grace_period"))
+	for {
+		users, err := s.usersRepo.PurgeDeletionDue(requestedBefore, DeletionPurgeBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, user := range users {
+			log.Info().
+				Str("user_id", user.ID.String()).
+				Msg("Account purged")
+
+			if err = s.mailHandler.SendAccountDeleted(user.Email); err != nil {
+				log.Error().
+					Err(err).
+					Str("user_id", user.ID.String()).
+					Msg("Unable to send account deletion confirmation")
+			}
+		}
+
+		if len(users) < DeletionPurgeBatch {
+			break
+		}
+	}
+
+	anonymized, err := s.usersRepo.AnonymizeDeletedAudit()
+	if err != nil {
+		return err
+	}
+
+	if anonymized > 0 {
+		log.Info().
+			Int64("count", anonymized).
+			Msg("Audit records of deleted users anonymized")
+	}
+
+	return nil
+}
+
+// ResetPasswordRequest creates password reset code, when it is sent
+// by admin audit record is written in the same transaction.
+func (s *userService) ResetPasswordRequest(email string, auditRecord *entities.NewAdminAuditRecord) (*schema.ActionCode, error) {
 	if !s.usersRepo.EmailExists(email) {
 		return nil, http.NotFoundError("User not found")
 	}
 
-	passwordReset, err := s.usersRepo.CreateActionCode(&entities.ActionCodeRequest{
-		Type:  string(entities.PasswordResets),
-		Email: email,
+	passwordReset, err := s.createActionCode(&entities.ActionCodeRequest{
+		Type:        entities.PasswordResets,
+		Email:       email,
+		AuditRecord: auditRecord,
 	})
 
 	if err != nil {
@@ -334,43 +554,89 @@ func (s *userService) ResetPasswordRequest(email string) (*schema.ActionCode, er
 		return nil, http.InternalError(err)
 	}
 
-	return &schema.ActionCode{
-		ID:        passwordReset.ID,
-		UserId:    passwordReset.UserId,
-		Code:      passwordReset.Code,
-		CreatedAt: passwordReset.CreatedAt,
-	}, nil
+	return passwordReset, nil
 }
 
-func (s *userService) GetResetPasswordCode(code string) (*schema.ActionCode, error) {
-	actionCode, err := s.usersRepo.GetActionCode(&entities.ActionCodeCheck{
-		Type: entities.PasswordResets,
-		Code: code,
+// ResetPasswordWithCode consumes password reset code, password strength
+// is checked first so that weak password does not burn the code.
+func (s *userService) ResetPasswordWithCode(code string, newPassword string) error {
+	if !util.IsStrongPassword(newPassword) {
+		log.Warn().Msg("New password is weak")
+		return http.InsecurePasswordError()
+	}
+
+	actionCode, err := s.usersRepo.ConsumeActionCode(&entities.ActionCodeCheck{
+		Type:     entities.PasswordResets,
+		CodeHash: util.HashToken(code),
 	})
 
 	if err != nil {
-		log.Error().
-			Err(err).
-			Msg(fmt.Sprintf("Password reset code [%s] not found", code))
+		if err == sql.ErrNoRows {
+			return http.BadRequestWithMessage("Invalid password reset code")
+		}
 
-		return nil, err
+		return http.InternalError(err)
+	}
+
+	return s.ResetPassword(actionCode.UserId, newPassword)
+}
+
+func (s *userService) MagicLinkRequest(email string) (*schema.ActionCode, error) {
+	if !s.usersRepo.EmailExists(email) {
+		return nil, http.NotFoundError("User not found")
 	}
 
-	if actionCode.CreatedAt.Add(UserConfirmationTTL).Before(time.Now().UTC()) {
+	magicLink, err := s.createActionCode(&entities.ActionCodeRequest{
+		Type:  entities.MagicLinks,
+		Email: email,
+	})
+
+	if err != nil {
 		log.Error().
 			Err(err).
-			Str("user_id", actionCode.UserId.String()).
-			Msg(fmt.Sprintf("Password reset code [%s] has already expired", code))
+			Str("email", email).
+			Msg("Unable to create magic link")
 
-		return nil, err
+		return nil, http.InternalError(err)
 	}
 
-	return &schema.ActionCode{
-		ID:        actionCode.ID,
-		UserId:    actionCode.UserId,
-		Code:      actionCode.Code,
-		CreatedAt: actionCode.CreatedAt,
-	}, nil
+	return magicLink, nil
+}
+
+// MagicLinkOwner returns owner of valid magic link without using it
+func (s *userService) MagicLinkOwner(code string) (*schema.UserResponse, error) {
+	actionCode, err := s.usersRepo.GetActionCode(&entities.ActionCodeCheck{
+		Type:     entities.MagicLinks,
+		CodeHash: util.HashToken(code),
+	})
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.UnauthorizedError("Invalid or expired sign in link")
+		}
+
+		return nil, http.InternalError(err)
+	}
+
+	return s.Get(actionCode.UserId)
+}
+
+// ConsumeMagicLink returns owner of magic link, link can be used only once
+func (s *userService) ConsumeMagicLink(code string) (*schema.UserResponse, error) {
+	actionCode, err := s.usersRepo.ConsumeActionCode(&entities.ActionCodeCheck{
+		Type:     entities.MagicLinks,
+		CodeHash: util.HashToken(code),
+	})
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.UnauthorizedError("Invalid or expired sign in link")
+		}
+
+		return nil, http.InternalError(err)
+	}
+
+	return s.Get(actionCode.UserId)
 }
 
 func (s *userService) ResetPassword(userId uuid.UUID, newPassword string) error {
@@ -402,7 +668,7 @@ func (s *userService) ResetPassword(userId uuid.UUID, newPassword string) error
 		return http.InternalError(err)
 	}
 
-	return nil
+	return s.revokeSessions(userId)
 }
 
 func (s *userService) CreateConfirmation(userId uuid.UUID) (*schema.ActionCode, error) {
@@ -415,8 +681,8 @@ func (s *userService) CreateConfirmation(userId uuid.UUID) (*schema.ActionCode,
 		return nil, http.Conflict("User has already confirmed account")
 	}
 
-	actionCode, err := s.usersRepo.CreateActionCode(&entities.ActionCodeRequest{
-		Type:  "user_confirmations",
+	actionCode, err := s.createActionCode(&entities.ActionCodeRequest{
+		Type:  entities.UserConfirmations,
 		Email: user.Email,
 	})
 
@@ -429,12 +695,7 @@ func (s *userService) CreateConfirmation(userId uuid.UUID) (*schema.ActionCode,
 		return nil, s.handleError(err)
 	}
 
-	return &schema.ActionCode{
-		ID:        actionCode.ID,
-		UserId:    actionCode.UserId,
-		Code:      actionCode.Code,
-		CreatedAt: actionCode.CreatedAt,
-	}, nil
+	return actionCode, nil
 }
 
 func (s *userService) ResendConfirmation(userId uuid.UUID) error {
@@ -456,6 +717,22 @@ func (s *userService) ResendConfirmation(userId uuid.UUID) error {
 	return nil
 }
 
+// DeleteExpiredActionCodes removes expired confirmation, password reset and email change codes
+func (s *userService) DeleteExpiredActionCodes() error {
+	deleted, err := s.usersRepo.DeleteExpiredActionCodes(time.Now())
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		log.Info().
+			Int64("deleted", deleted).
+			Msg("Expired action codes deleted")
+	}
+
+	return nil
+}
+
 func (s *userService) Exists(userId uuid.UUID) bool {
 	return s.usersRepo.Exists(userId)
 }
@@ -464,19 +741,87 @@ func (s *userService) EmailExists(email string) bool {
 	return s.usersRepo.EmailExists(email)
 }
 
+// revokeSessions revokes all refresh tokens so that
+// every device has to log in again with new credentials.
+func (s *userService) revokeSessions(userId uuid.UUID) error {
+	err := s.tokensRepo.RevokeAll(userId)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to revoke refresh tokens")
+
+		return http.InternalError(err)
+	}
+
+	return nil
+}
+
+// createActionCode generates random code and stores only its hash,
+// previously issued codes of the same type stop working.
+func (s *userService) createActionCode(actionCodeRequest *entities.ActionCodeRequest) (*schema.ActionCode, error) {
+	code := uuid.New().String()
+	actionCodeRequest.CodeHash = util.HashToken(code)
+	actionCodeRequest.TTL = ActionCodeTTL(actionCodeRequest.Type)
+
+	actionCode, err := s.usersRepo.CreateActionCode(actionCodeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema.ActionCode{
+		ID:        actionCode.ID,
+		UserId:    actionCode.UserId,
+		Code:      code,
+		CreatedAt: actionCode.CreatedAt,
+	}, nil
+}
+
+func (s *userService) actionCodeError(err error, msg string) error {
+	if err == sql.ErrNoRows {
+		return http.NotFoundError(msg)
+	}
+
+	return http.InternalError(err)
+}
+
+// ActionCodeTTL returns configured lifetime of action codes of given type
+func ActionCodeTTL(codeType entities.ActionCodeType) time.Duration {
+	var ttl time.Duration
+	var fallback time.Duration
+	switch codeType {
+	case entities.UserConfirmations:
+		ttl, fallback = viper.GetDuration("confirmation_code_ttl"), UserConfirmationTTL
+	case entities.PasswordResets:
+		ttl, fallback = viper.GetDuration("password_reset_code_ttl"), PasswordResetTTL
+	case entities.EmailChanges:
+		ttl, fallback = viper.GetDuration("email_change_code_ttl"), EmailChangeTTL
+	case entities.MagicLinks:
+		ttl, fallback = viper.GetDuration("magic_link_code_ttl"), MagicLinkTTL
+	}
+
+	if ttl <= 0 {
+		return fallback
+	}
+
+	return ttl
+}
+
 func (s *userService) userToResponse(user *entities.User) *schema.UserResponse {
 	return &schema.UserResponse{
-		ID:          user.ID,
-		FullName:    user.FullName,
-		Email:       user.Email,
-		IsAdmin:     user.IsAdmin,
-		IsActive:    user.IsActive,
-		IsConfirmed: user.IsConfirmed,
-		Settings:    user.Settings,
-		Provider:    user.Provider,
-		Password:    user.Password,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
+		ID:                  user.ID,
+		FullName:            user.FullName,
+		Email:               user.Email,
+		IsAdmin:             user.IsAdmin,
+		IsActive:            user.IsActive,
+		IsConfirmed:         user.IsConfirmed,
+		Settings:            user.Settings,
+		Provider:            user.Provider,
+		Password:            user.Password,
+		CreatedAt:           user.CreatedAt,
+		UpdatedAt:           user.UpdatedAt,
+		DeletionRequestedAt: user.DeletionRequestedAt,
+		InactiveReason:      user.InactiveReason,
 	}
 }
 
@@ -487,3 +832,21 @@ func (s *userService) handleError(err error) error {
 		return http.InternalError(err)
 	}
 }
+
+// verifyPassword checks current password, users signed up with identity provider
+// have no password and prove themselves with recent sign in instead.
+func verifyPassword(passwordHash string, password string, authTime time.Time) error {
+	if passwordHash == "" {
+		if time.Since(authTime) > viper.GetDuration("step_up_ttl") {
+			return http.ReauthenticationRequiredError()
+		}
+
+		return nil
+	}
+
+	if err := util.CheckPassword(passwordHash, password); err != nil {
+		return http.InvalidPasswordError()
+	}
+
+	return nil
+}
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/sultaniman/confetti/platform/entities"
+	"github.com/sultaniman/confetti/platform/http"
+	"github.com/sultaniman/confetti/platform/repo"
+	"github.com/sultaniman/confetti/platform/schema"
+	"github.com/sultaniman/confetti/platform/webauthn"
+	"time"
+)
+
+const (
+	MFAMethodWebAuthn         = "webauthn"
+	DefaultWebAuthnCredential = "Passkey"
+)
+
+type WebAuthnService interface {
+	BeginRegistration(userId uuid.UUID) (*webauthn.CreationOptions, error)
+	FinishRegistration(userId uuid.UUID, registrationRequest *schema.WebAuthnRegistrationRequest) (*schema.WebAuthnCredentialResponse, error)
+	ListCredentials(userId uuid.UUID) ([]schema.WebAuthnCredentialResponse, error)
+	DeleteCredential(userId uuid.UUID, credentialId uuid.UUID, code string) error
+	HasCredentials(userId uuid.UUID) bool
+	BeginLogin(userId *uuid.UUID) (*webauthn.RequestOptions, error)
+	CredentialOwner(assertion *webauthn.AssertionResponse) (*uuid.UUID, error)
+	FinishLogin(userId *uuid.UUID, assertion *webauthn.AssertionResponse) (*uuid.UUID, error)
+}
+
+type webAuthnService struct {
+	relyingParty *webauthn.RelyingParty
+	webAuthnRepo repo.WebAuthnRepo
+	usersRepo    repo.UserRepo
+	mfaService   MFAService
+}
+
+func NewWebAuthnService(
+	relyingParty *webauthn.RelyingParty,
+	webAuthnRepo repo.WebAuthnRepo,
+	usersRepo repo.UserRepo,
+	mfaService MFAService,
+) WebAuthnService {
+	return &webAuthnService{
+		relyingParty: relyingParty,
+		webAuthnRepo: webAuthnRepo,
+		usersRepo:    usersRepo,
+		mfaService:   mfaService,
+	}
+}
+
+func (w *webAuthnService) BeginRegistration(userId uuid.UUID) (*webauthn.CreationOptions, error) {
+	user, err := w.usersRepo.Get(userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, http.NotFoundError("User not found")
+		}
+
+		return nil, http.InternalError(err)
+	}
+
+	existing, err := w.credentialIDs(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := w.newChallenge(&userId, entities.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := user.FullName
+	if displayName == "" {
+		displayName = user.Email
+	}
+
+	userEntity := webauthn.UserEntity{
+		ID:          webauthn.EncodeURL(userId[:]),
+		Name:        user.Email,
+		DisplayName: displayName,
+	}
+
+	return w.relyingParty.NewCreationOptions(challenge, userEntity, existing, "preferred"), nil
+}
+
+func (w *webAuthnService) FinishRegistration(userId uuid.UUID, registrationRequest *schema.WebAuthnRegistrationRequest) (*schema.WebAuthnCredentialResponse, error) {
+	challenge, err := w.consumeChallenge(registrationRequest.Credential.Response.ClientDataJSON, entities.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	if challenge.UserId == nil || *challenge.UserId != userId {
+		return nil, http.UnauthorizedError("Passkey challenge is invalid or expired")
+	}
+
+	if err = w.verifySecondFactor(userId, registrationRequest.Code); err != nil {
+		return nil, err
+	}
+
+	credential, err := w.relyingParty.VerifyRegistration(&registrationRequest.Credential, challenge.Challenge, false)
+	if err != nil {
+		log.Info().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Passkey registration failed")
+
+		return nil, http.UnauthorizedError("Passkey verification failed")
+	}
+
+	credentialId := webauthn.EncodeURL(credential.ID)
+	if _, err = w.webAuthnRepo.GetCredential(credentialId); err == nil {
+		return nil, http.Conflict("Passkey is already registered")
+	}
+
+	name := registrationRequest.Name
+	if name == "" {
+		name = DefaultWebAuthnCredential
+	}
+
+	aaguid := ""
+	if parsed, err := uuid.FromBytes(credential.AAGUID); err == nil {
+		aaguid = parsed.String()
+	}
+
+	credentialRow, err := w.webAuthnRepo.CreateCredential(&entities.NewWebAuthnCredential{
+		UserId:       userId,
+		Name:         name,
+		CredentialId: credentialId,
+		PublicKey:    webauthn.EncodeURL(credential.PublicKey),
+		AAGUID:       aaguid,
+		SignCount:    credential.SignCount,
+	})
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("user_id", userId.String()).
+			Msg("Unable to save passkey")
+
+		return nil, http.InternalError(err)
+	}
+
+	response := credentialResponse(credentialRow)
+	return &response, nil
+}
+
+func (w *webAuthnService) ListCredentials(userId uuid.UUID) ([]schema.WebAuthnCredentialResponse, error) {
+	credentials, err := w.webAuthnRepo.ListCredentials(userId)
+	if err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	var result []schema.WebAuthnCredentialResponse
+	for _, credential := range credentials {
+		result = append(result, credentialResponse(&credential))
+	}
+
+	return result, nil
+}
+
+func (w *webAuthnService) DeleteCredential(userId uuid.UUID, credentialId uuid.UUID, code string) error {
+	if err := w.verifySecondFactor(userId, code); err != nil {
+		return err
+	}
+
+	deleted, err := w.webAuthnRepo.DeleteCredential(userId, credentialId)
+	if err != nil {
+		return http.InternalError(err)
+	}
+
+	if !deleted {
+		return http.NotFoundError("Passkey not found")
+	}
+
+	return nil
+}
+
+func (w *webAuthnService) HasCredentials(userId uuid.UUID) bool {
+	return w.webAuthnRepo.HasCredentials(userId)
+}
+
+// BeginLogin starts assertion ceremony, without user it is a passwordless
+// login with discoverable credentials otherwise a second factor check.
+func (w *webAuthnService) BeginLogin(userId *uuid.UUID) (*webauthn.RequestOptions, error) {
+	if userId == nil {
+		challenge, err := w.newChallenge(nil, entities.WebAuthnLogin)
+		if err != nil {
+			return nil, err
+		}
+
+		return w.relyingParty.NewRequestOptions(challenge, nil, "required"), nil
+	}
+
+	allowed, err := w.credentialIDs(*userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(allowed) == 0 {
+		return nil, http.BadRequestWithMessage("No passkeys are registered")
+	}
+
+	challenge, err := w.newChallenge(userId, entities.WebAuthnMFA)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.relyingParty.NewRequestOptions(challenge, allowed, "discouraged"), nil
+}
+
+// CredentialOwner returns user of the passkey in assertion without verifying it,
+// so that sign in lockout of the user can be checked before the verification.
+func (w *webAuthnService) CredentialOwner(assertion *webauthn.AssertionResponse) (*uuid.UUID, error) {
+	credential, err := w.assertedCredential(assertion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &credential.UserId, nil
+}
+
+// FinishLogin verifies assertion and returns id of the authenticated user,
+// passwordless login additionally requires user verification.
+func (w *webAuthnService) FinishLogin(userId *uuid.UUID, assertion *webauthn.AssertionResponse) (*uuid.UUID, error) {
+	ceremony := entities.WebAuthnLogin
+	if userId != nil {
+		ceremony = entities.WebAuthnMFA
+	}
+
+	challenge, err := w.consumeChallenge(assertion.Response.ClientDataJSON, ceremony)
+	if err != nil {
+		return nil, err
+	}
+
+	if userId != nil && (challenge.UserId == nil || *challenge.UserId != *userId) {
+		return nil, http.UnauthorizedError("Passkey challenge is invalid or expired")
+	}
+
+	credential, err := w.assertedCredential(assertion)
+	if err != nil {
+		return nil, err
+	}
+
+	if userId != nil && credential.UserId != *userId {
+		return nil, http.UnauthorizedError("Passkey verification failed")
+	}
+
+	publicKey, err := webauthn.DecodeURL(credential.PublicKey)
+	if err != nil {
+		return nil, http.DecodingError(err)
+	}
+
+	result, err := w.relyingParty.VerifyAssertion(
+		assertion,
+		challenge.Challenge,
+		publicKey,
+		uint32(credential.SignCount),
+		userId == nil,
+	)
+
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("user_id", credential.UserId.String()).
+			Str("credential_id", credential.ID.String()).
+			Msg("Passkey assertion failed")
+
+		return nil, http.UnauthorizedError("Passkey verification failed")
+	}
+
+	if err = w.webAuthnRepo.UpdateSignCount(credential.ID, result.SignCount); err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	return &credential.UserId, nil
+}
+
+// verifySecondFactor requires verification code from users with two-factor authentication,
+// otherwise stolen access token would be enough to add a passkey and skip the second factor.
+func (w *webAuthnService) verifySecondFactor(userId uuid.UUID, code string) error {
+	if !w.mfaService.IsEnabled(userId) {
+		return nil
+	}
+
+	if code == "" {
+		return http.BadRequestWithMessage("Please provide verification code")
+	}
+
+	return w.mfaService.Verify(userId, code)
+}
+
+func (w *webAuthnService) newChallenge(userId *uuid.UUID, ceremony entities.WebAuthnCeremony) (string, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", http.InternalError(err)
+	}
+
+	err = w.webAuthnRepo.CreateChallenge(&entities.NewWebAuthnChallenge{
+		Challenge: challenge,
+		UserId:    userId,
+		Ceremony:  ceremony,
+		ExpiresAt: time.Now().Add(webauthn.DefaultTimeout),
+	})
+
+	if err != nil {
+		return "", http.InternalError(err)
+	}
+
+	return challenge, nil
+}
+
+func (w *webAuthnService) consumeChallenge(clientDataJSON string, ceremony entities.WebAuthnCeremony) (*entities.WebAuthnChallenge, error) {
+	challenge, err := webauthn.ChallengeOf(clientDataJSON)
+	if err != nil {
+		return nil, http.BadRequestWithMessage("Invalid client data")
+	}
+
+	pending, err := w.webAuthnRepo.ConsumeChallenge(challenge, ceremony)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, http.UnauthorizedError("Passkey challenge is invalid or expired")
+		}
+
+		return nil, http.InternalError(err)
+	}
+
+	return pending, nil
+}
+
+func (w *webAuthnService) assertedCredential(assertion *webauthn.AssertionResponse) (*entities.WebAuthnCredential, error) {
+	rawId, err := webauthn.DecodeURL(assertion.RawID)
+	if err != nil {
+		return nil, http.UnauthorizedError("Passkey verification failed")
+	}
+
+	credential, err := w.webAuthnRepo.GetCredential(webauthn.EncodeURL(rawId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, http.UnauthorizedError("Passkey verification failed")
+		}
+
+		return nil, http.InternalError(err)
+	}
+
+	return credential, nil
+}
+
+func (w *webAuthnService) credentialIDs(userId uuid.UUID) ([][]byte, error) {
+	credentials, err := w.webAuthnRepo.ListCredentials(userId)
+	if err != nil {
+		return nil, http.InternalError(err)
+	}
+
+	var credentialIDs [][]byte
+	for _, credential := range credentials {
+		credentialId, err := webauthn.DecodeURL(credential.CredentialId)
+		if err != nil {
+			continue
+		}
+
+		credentialIDs = append(credentialIDs, credentialId)
+	}
+
+	return credentialIDs, nil
+}
+
+func credentialResponse(credential *entities.WebAuthnCredential) schema.WebAuthnCredentialResponse {
+	name := DefaultWebAuthnCredential
+	if credential.Name != nil {
+		name = *credential.Name
+	}
+
+	return schema.WebAuthnCredentialResponse{
+		ID:         credential.ID,
+		Name:       name,
+		CreatedAt:  credential.CreatedAt,
+		LastUsedAt: credential.LastUsedAt,
+	}
+}
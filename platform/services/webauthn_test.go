@@ -0,0 +1,167 @@
+package services_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/sultaniman/confetti/platform/entities"
+	"github.com/sultaniman/confetti/platform/mocks"
+	"github.com/sultaniman/confetti/platform/services"
+	"github.com/sultaniman/confetti/platform/shared"
+	"github.com/sultaniman/confetti/platform/webauthn"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var testRP = &webauthn.RelyingParty{
+	ID:      "confetti.local",
+	Name:    "Confetti",
+	Origins: []string{"https://confetti.local"},
+}
+
+// challengeStore behaves like challenge table, challenges are removed on first use
+type challengeStore map[string]*entities.WebAuthnChallenge
+
+func (s challengeStore) consume(challenge string, ceremony entities.WebAuthnCeremony) (*entities.WebAuthnChallenge, error) {
+	pending, found := s[challenge]
+	if !found || pending.Ceremony != string(ceremony) {
+		return nil, sql.ErrNoRows
+	}
+
+	delete(s, challenge)
+	return pending, nil
+}
+
+func assertion(t *testing.T, challenge string) *webauthn.AssertionResponse {
+	t.Helper()
+
+	clientData, err := json.Marshal(&webauthn.ClientData{
+		Type:      webauthn.CeremonyAssertion,
+		Challenge: challenge,
+		Origin:    "https://confetti.local",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := &webauthn.AssertionResponse{
+		ID:    "credential",
+		RawID: "credential",
+		Type:  "public-key",
+	}
+
+	response.Response.ClientDataJSON = webauthn.EncodeURL(clientData)
+	return response
+}
+
+func assertStatus(t *testing.T, err error, statusCode int) {
+	t.Helper()
+
+	var serviceError *shared.ServiceError
+	if !errors.As(err, &serviceError) {
+		t.Fatalf("err = %v, want service error", err)
+	}
+
+	if serviceError.StatusCode != statusCode {
+		t.Fatalf("status = %d, want %d", serviceError.StatusCode, statusCode)
+	}
+}
+
+func TestFinishLoginRejectsReusedChallenge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	webAuthnRepo := mocks.NewMockWebAuthnRepo(ctrl)
+	service := services.NewWebAuthnService(testRP, webAuthnRepo, mocks.NewMockUserRepo(ctrl), nil)
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := challengeStore{
+		challenge: {
+			Challenge: challenge,
+			Ceremony:  string(entities.WebAuthnLogin),
+			ExpiresAt: time.Now().Add(webauthn.DefaultTimeout),
+		},
+	}
+
+	webAuthnRepo.EXPECT().
+		ConsumeChallenge(challenge, entities.WebAuthnLogin).
+		DoAndReturn(store.consume).
+		Times(2)
+
+	// only the first attempt gets to the credential, stored key is not valid so it fails verification
+	webAuthnRepo.EXPECT().
+		GetCredential(gomock.Any()).
+		Return(&entities.WebAuthnCredential{UserId: uuid.New()}, nil).
+		Times(1)
+
+	response := assertion(t, challenge)
+	_, err = service.FinishLogin(nil, response)
+	assertStatus(t, err, http.StatusUnauthorized)
+
+	// replayed response is rejected before credential lookup
+	_, err = service.FinishLogin(nil, response)
+	assertStatus(t, err, http.StatusUnauthorized)
+}
+
+func TestFinishLoginRejectsChallengeOfOtherCeremony(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	webAuthnRepo := mocks.NewMockWebAuthnRepo(ctrl)
+	service := services.NewWebAuthnService(testRP, webAuthnRepo, mocks.NewMockUserRepo(ctrl), nil)
+
+	userId := uuid.New()
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// challenge of passwordless login can not be used as a second factor
+	store := challengeStore{
+		challenge: {
+			Challenge: challenge,
+			Ceremony:  string(entities.WebAuthnLogin),
+			ExpiresAt: time.Now().Add(webauthn.DefaultTimeout),
+		},
+	}
+
+	webAuthnRepo.EXPECT().
+		ConsumeChallenge(challenge, entities.WebAuthnMFA).
+		DoAndReturn(store.consume)
+
+	_, err = service.FinishLogin(&userId, assertion(t, challenge))
+	assertStatus(t, err, http.StatusUnauthorized)
+}
+
+func TestFinishLoginRejectsChallengeOfOtherUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	webAuthnRepo := mocks.NewMockWebAuthnRepo(ctrl)
+	service := services.NewWebAuthnService(testRP, webAuthnRepo, mocks.NewMockUserRepo(ctrl), nil)
+
+	userId := uuid.New()
+	otherUserId := uuid.New()
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := challengeStore{
+		challenge: {
+			Challenge: challenge,
+			UserId:    &otherUserId,
+			Ceremony:  string(entities.WebAuthnMFA),
+			ExpiresAt: time.Now().Add(webauthn.DefaultTimeout),
+		},
+	}
+
+	webAuthnRepo.EXPECT().
+		ConsumeChallenge(challenge, entities.WebAuthnMFA).
+		DoAndReturn(store.consume)
+
+	_, err = service.FinishLogin(&userId, assertion(t, challenge))
+	assertStatus(t, err, http.StatusUnauthorized)
+}
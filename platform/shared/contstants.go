@@ -18,4 +18,8 @@ const (
 	EncryptionError ErrorCode = "encryption_error"
 	DecryptionError ErrorCode = "decryption_error"
 	DecodingError   ErrorCode = "decoding_error"
+
+	ReauthenticationRequired ErrorCode = "reauthentication_required"
+	TooManyRequests          ErrorCode = "too_many_requests"
+	AccountLocked            ErrorCode = "account_locked"
 )
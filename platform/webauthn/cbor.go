@@ -0,0 +1,170 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Minimal CBOR (RFC 8949) decoder which covers what authenticators send:
+// integers, byte and text strings, arrays, maps, tags and simple values.
+// Indefinite length items and floats are not used in WebAuthn and rejected.
+
+const maxCBORDepth = 16
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// decodeCBOR decodes first item and returns number of bytes it occupied
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	decoder := &cborDecoder{data: data}
+	value, err := decoder.decode(0)
+	return value, decoder.pos, err
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, errors.New("cbor: nesting is too deep")
+	}
+
+	if d.pos >= len(d.data) {
+		return nil, errCBORTruncated
+	}
+
+	initial := d.data[d.pos]
+	d.pos++
+	major := initial >> 5
+	info := initial & 0x1f
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+		}
+	}
+
+	argument, err := d.argument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if argument > 1<<63-1 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return int64(argument), nil
+	case 1:
+		if argument > 1<<63-1 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(argument), nil
+	case 2:
+		raw, err := d.take(argument)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, raw...), nil
+	case 3:
+		raw, err := d.take(argument)
+		if err != nil {
+			return nil, err
+		}
+		return string(raw), nil
+	case 4:
+		if argument > uint64(len(d.data)) {
+			return nil, errCBORTruncated
+		}
+
+		items := make([]interface{}, 0, argument)
+		for i := uint64(0); i < argument; i++ {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5:
+		if argument > uint64(len(d.data)) {
+			return nil, errCBORTruncated
+		}
+
+		items := make(map[interface{}]interface{}, argument)
+		for i := uint64(0); i < argument; i++ {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, errors.New("cbor: unsupported map key type")
+			}
+
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items[key] = value
+		}
+		return items, nil
+	default:
+		// tags carry no meaning for us, so the tagged item is returned as is
+		return d.decode(depth + 1)
+	}
+}
+
+func (d *cborDecoder) argument(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		raw, err := d.take(1)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(raw[0]), nil
+	case info == 25:
+		raw, err := d.take(2)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint16(raw)), nil
+	case info == 26:
+		raw, err := d.take(4)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint32(raw)), nil
+	case info == 27:
+		raw, err := d.take(8)
+		if err != nil {
+			return 0, err
+		}
+		return binary.BigEndian.Uint64(raw), nil
+	default:
+		return 0, errors.New("cbor: indefinite length items are not supported")
+	}
+}
+
+func (d *cborDecoder) take(size uint64) ([]byte, error) {
+	if size > uint64(len(d.data)-d.pos) {
+		return nil, errCBORTruncated
+	}
+
+	raw := d.data[d.pos : d.pos+int(size)]
+	d.pos += int(size)
+	return raw, nil
+}
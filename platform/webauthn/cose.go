@@ -0,0 +1,133 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers which are offered to authenticators
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters (RFC 8152)
+const (
+	coseKeyType     = 1
+	coseAlgorithm   = 3
+	coseCurve       = -1
+	coseX           = -2
+	coseY           = -3
+	coseRSAModulus  = -1
+	coseRSAExponent = -2
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+var ErrUnsupportedKey = errors.New("unsupported credential public key")
+
+// PublicKey is credential public key decoded from COSE format
+type PublicKey struct {
+	Algorithm int
+	Key       crypto.PublicKey
+}
+
+func ParsePublicKey(coseKey []byte) (*PublicKey, error) {
+	decoded, _, err := decodeCBOR(coseKey)
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+
+	keyType, _ := params[int64(coseKeyType)].(int64)
+	algorithm, _ := params[int64(coseAlgorithm)].(int64)
+
+	switch {
+	case keyType == coseKeyTypeEC2 && algorithm == AlgES256:
+		curve, _ := params[int64(coseCurve)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		y, _ := params[int64(coseY)].([]byte)
+		if curve != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, ErrUnsupportedKey
+		}
+
+		publicKey := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, ErrUnsupportedKey
+		}
+
+		return &PublicKey{Algorithm: AlgES256, Key: publicKey}, nil
+	case keyType == coseKeyTypeOKP && algorithm == AlgEdDSA:
+		curve, _ := params[int64(coseCurve)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		if curve != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+
+		return &PublicKey{Algorithm: AlgEdDSA, Key: ed25519.PublicKey(x)}, nil
+	case keyType == coseKeyTypeRSA && algorithm == AlgRS256:
+		modulus, _ := params[int64(coseRSAModulus)].([]byte)
+		exponent, _ := params[int64(coseRSAExponent)].([]byte)
+		if len(modulus) < 256 || len(exponent) == 0 || len(exponent) > 4 {
+			return nil, ErrUnsupportedKey
+		}
+
+		return &PublicKey{
+			Algorithm: AlgRS256,
+			Key: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(modulus),
+				E: int(new(big.Int).SetBytes(exponent).Int64()),
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: kty=%d alg=%d", ErrUnsupportedKey, keyType, algorithm)
+	}
+}
+
+// Verify checks assertion signature over signed data
+func (p *PublicKey) Verify(signedData []byte, signature []byte) error {
+	switch key := p.Key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(signedData)
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return ErrInvalidSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, signedData, signature) {
+			return ErrInvalidSignature
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(signedData)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return ErrInvalidSignature
+		}
+	default:
+		return ErrUnsupportedKey
+	}
+
+	return nil
+}
@@ -0,0 +1,431 @@
+// Package webauthn implements relying party side of WebAuthn ceremonies,
+// attestation statements are not verified as we only request "none" attestation.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	CeremonyRegistration = "webauthn.create"
+	CeremonyAssertion    = "webauthn.get"
+
+	DefaultTimeout = 5 * time.Minute
+	challengeSize  = 32
+)
+
+// authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+var (
+	ErrInvalidChallenge  = errors.New("challenge does not match")
+	ErrInvalidOrigin     = errors.New("origin is not allowed")
+	ErrInvalidCeremony   = errors.New("unexpected ceremony type")
+	ErrInvalidRPID       = errors.New("relying party id does not match")
+	ErrUserNotPresent    = errors.New("user presence is required")
+	ErrUserNotVerified   = errors.New("user verification is required")
+	ErrInvalidSignature  = errors.New("invalid signature")
+	ErrSignCountRollback = errors.New("signature counter did not increase, credential might be cloned")
+	ErrMalformedData     = errors.New("malformed authenticator data")
+)
+
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RegistrationResponse is PublicKeyCredential returned by navigator.credentials.create
+// with binary fields encoded as base64url.
+type RegistrationResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"`
+	} `json:"response"`
+}
+
+// AssertionResponse is PublicKeyCredential returned by navigator.credentials.get
+// with binary fields encoded as base64url.
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+type ClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type AuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte // COSE encoded
+}
+
+// Credential is a verified newly registered credential
+type Credential struct {
+	ID           []byte
+	PublicKey    []byte
+	AAGUID       []byte
+	SignCount    uint32
+	UserVerified bool
+}
+
+type AssertionResult struct {
+	CredentialID []byte
+	SignCount    uint32
+	UserVerified bool
+}
+
+func NewChallenge() (string, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return "", err
+	}
+
+	return EncodeURL(challenge), nil
+}
+
+func (rp *RelyingParty) NewCreationOptions(challenge string, user UserEntity, exclude [][]byte, userVerification string) *CreationOptions {
+	params := make([]CredentialParameter, 0, len(SupportedAlgorithms))
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, CredentialParameter{Type: "public-key", Alg: alg})
+	}
+
+	return &CreationOptions{
+		Challenge:          challenge,
+		RP:                 RelyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User:               user,
+		PubKeyCredParams:   params,
+		Timeout:            DefaultTimeout.Milliseconds(),
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: userVerification,
+		},
+		Attestation: "none",
+	}
+}
+
+func (rp *RelyingParty) NewRequestOptions(challenge string, allow [][]byte, userVerification string) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          DefaultTimeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: userVerification,
+	}
+}
+
+// ChallengeOf extracts challenge from client data so that the
+// matching server side ceremony state can be looked up.
+func ChallengeOf(clientDataJSON string) (string, error) {
+	clientData, _, err := parseClientData(clientDataJSON)
+	if err != nil {
+		return "", err
+	}
+
+	return clientData.Challenge, nil
+}
+
+func (rp *RelyingParty) VerifyRegistration(response *RegistrationResponse, challenge string, requireUV bool) (*Credential, error) {
+	if response.Type != "public-key" {
+		return nil, ErrMalformedData
+	}
+
+	if _, err := rp.verifyClientData(response.Response.ClientDataJSON, CeremonyRegistration, challenge); err != nil {
+		return nil, err
+	}
+
+	rawAttestation, err := DecodeURL(response.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrMalformedData
+	}
+
+	decoded, _, err := decodeCBOR(rawAttestation)
+	if err != nil {
+		return nil, err
+	}
+
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrMalformedData
+	}
+
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrMalformedData
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = rp.verifyAuthenticatorData(authData, requireUV); err != nil {
+		return nil, err
+	}
+
+	if authData.Flags&flagAttestedData == 0 || len(authData.CredentialID) == 0 {
+		return nil, ErrMalformedData
+	}
+
+	if _, err = ParsePublicKey(authData.PublicKey); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:           authData.CredentialID,
+		PublicKey:    authData.PublicKey,
+		AAGUID:       authData.AAGUID,
+		SignCount:    authData.SignCount,
+		UserVerified: authData.Flags&flagUserVerified != 0,
+	}, nil
+}
+
+// VerifyAssertion verifies assertion against stored credential public key and counter
+func (rp *RelyingParty) VerifyAssertion(response *AssertionResponse, challenge string, publicKey []byte, signCount uint32, requireUV bool) (*AssertionResult, error) {
+	if response.Type != "public-key" {
+		return nil, ErrMalformedData
+	}
+
+	rawClientData, err := rp.verifyClientData(response.Response.ClientDataJSON, CeremonyAssertion, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	rawAuthData, err := DecodeURL(response.Response.AuthenticatorData)
+	if err != nil {
+		return nil, ErrMalformedData
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = rp.verifyAuthenticatorData(authData, requireUV); err != nil {
+		return nil, err
+	}
+
+	signature, err := DecodeURL(response.Response.Signature)
+	if err != nil {
+		return nil, ErrMalformedData
+	}
+
+	credentialKey, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(rawClientData)
+	signedData := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	if err = credentialKey.Verify(signedData, signature); err != nil {
+		return nil, err
+	}
+
+	// authenticators which do not implement counters always send zero
+	if (authData.SignCount != 0 || signCount != 0) && authData.SignCount <= signCount {
+		return nil, ErrSignCountRollback
+	}
+
+	credentialID, err := DecodeURL(response.RawID)
+	if err != nil {
+		return nil, ErrMalformedData
+	}
+
+	return &AssertionResult{
+		CredentialID: credentialID,
+		SignCount:    authData.SignCount,
+		UserVerified: authData.Flags&flagUserVerified != 0,
+	}, nil
+}
+
+func ParseAuthenticatorData(raw []byte) (*AuthenticatorData, error) {
+	// rpIdHash (32) + flags (1) + signCount (4)
+	if len(raw) < 37 {
+		return nil, ErrMalformedData
+	}
+
+	authData := &AuthenticatorData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	if authData.Flags&flagAttestedData == 0 {
+		return authData, nil
+	}
+
+	// aaguid (16) + credentialIdLength (2) + credentialId + credentialPublicKey
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return nil, ErrMalformedData
+	}
+
+	authData.AAGUID = rest[:16]
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLength == 0 || len(rest) < idLength {
+		return nil, ErrMalformedData
+	}
+
+	authData.CredentialID = rest[:idLength]
+	rest = rest[idLength:]
+
+	_, keyLength, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, ErrMalformedData
+	}
+
+	authData.PublicKey = rest[:keyLength]
+	return authData, nil
+}
+
+func (rp *RelyingParty) verifyClientData(clientDataJSON string, ceremony string, challenge string) ([]byte, error) {
+	clientData, rawClientData, err := parseClientData(clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	if clientData.Type != ceremony {
+		return nil, ErrInvalidCeremony
+	}
+
+	if subtle.ConstantTimeCompare([]byte(clientData.Challenge), []byte(challenge)) != 1 {
+		return nil, ErrInvalidChallenge
+	}
+
+	for _, origin := range rp.Origins {
+		if clientData.Origin == origin {
+			return rawClientData, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrInvalidOrigin, clientData.Origin)
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(authData *AuthenticatorData, requireUV bool) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.RPIDHash, rpIDHash[:]) {
+		return ErrInvalidRPID
+	}
+
+	if authData.Flags&flagUserPresent == 0 {
+		return ErrUserNotPresent
+	}
+
+	if requireUV && authData.Flags&flagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+
+	return nil
+}
+
+func parseClientData(clientDataJSON string) (*ClientData, []byte, error) {
+	rawClientData, err := DecodeURL(clientDataJSON)
+	if err != nil {
+		return nil, nil, ErrMalformedData
+	}
+
+	clientData := new(ClientData)
+	if err = json.Unmarshal(rawClientData, clientData); err != nil {
+		return nil, nil, ErrMalformedData
+	}
+
+	return clientData, rawClientData, nil
+}
+
+func descriptors(credentialIDs [][]byte) []CredentialDescriptor {
+	credentials := make([]CredentialDescriptor, 0, len(credentialIDs))
+	for _, credentialID := range credentialIDs {
+		credentials = append(credentials, CredentialDescriptor{
+			Type: "public-key",
+			ID:   EncodeURL(credentialID),
+		})
+	}
+
+	return credentials
+}
+
+func EncodeURL(raw []byte) string {
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeURL decodes base64url with or without padding
+func DecodeURL(encoded string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+}
@@ -0,0 +1,444 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+const (
+	testRPID   = "confetti.local"
+	testOrigin = "https://confetti.local"
+)
+
+var testRP = &RelyingParty{
+	ID:      testRPID,
+	Name:    "Confetti",
+	Origins: []string{testOrigin},
+}
+
+// cborPair keeps map entries in order so that encoded data is deterministic
+type cborPair struct {
+	key   interface{}
+	value interface{}
+}
+
+type cborMap []cborPair
+
+// encodeCBOR is minimal encoder of what authenticators send, it is the counterpart of decodeCBOR
+func encodeCBOR(value interface{}) []byte {
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case cborMap:
+		encoded := cborHead(5, uint64(len(v)))
+		for _, pair := range v {
+			encoded = append(encoded, encodeCBOR(pair.key)...)
+			encoded = append(encoded, encodeCBOR(pair.value)...)
+		}
+		return encoded
+	default:
+		panic("cbor: unsupported type")
+	}
+}
+
+func cborHead(major byte, argument uint64) []byte {
+	switch {
+	case argument < 24:
+		return []byte{major<<5 | byte(argument)}
+	case argument <= 0xff:
+		return []byte{major<<5 | 24, byte(argument)}
+	case argument <= 0xffff:
+		head := []byte{major<<5 | 25, 0, 0}
+		binary.BigEndian.PutUint16(head[1:], uint16(argument))
+		return head
+	default:
+		head := []byte{major<<5 | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(head[1:], uint32(argument))
+		return head
+	}
+}
+
+// softAuthenticator is in-process ES256 authenticator, fields
+// can be changed between ceremonies to produce invalid responses.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	rpID         string
+	origin       string
+	flags        byte
+	signCount    uint32
+
+	// ceremony overrides type of client data when set
+	ceremony       string
+	withoutCounter bool
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err = rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+
+	return &softAuthenticator{
+		key:          key,
+		credentialID: credentialID,
+		rpID:         testRPID,
+		origin:       testOrigin,
+		flags:        flagUserPresent | flagUserVerified,
+	}
+}
+
+func (a *softAuthenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+
+	return encodeCBOR(cborMap{
+		{coseKeyType, coseKeyTypeEC2},
+		{coseAlgorithm, AlgES256},
+		{coseCurve, coseCurveP256},
+		{coseX, x},
+		{coseY, y},
+	})
+}
+
+func (a *softAuthenticator) authenticatorData(flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	authData := append([]byte{}, rpIDHash[:]...)
+	authData = append(authData, flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(authData[33:], a.signCount)
+	if !attested {
+		return authData
+	}
+
+	// zero aaguid and credential id length
+	authData = append(authData, make([]byte, 18)...)
+	binary.BigEndian.PutUint16(authData[53:], uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	return append(authData, a.coseKey()...)
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremony string, challenge string) []byte {
+	t.Helper()
+
+	if a.ceremony != "" {
+		ceremony = a.ceremony
+	}
+
+	clientData, err := json.Marshal(&ClientData{
+		Type:      ceremony,
+		Challenge: challenge,
+		Origin:    a.origin,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return clientData
+}
+
+// register answers navigator.credentials.create with "none" attestation
+func (a *softAuthenticator) register(t *testing.T, challenge string) *RegistrationResponse {
+	t.Helper()
+
+	attestationObject := encodeCBOR(cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", a.authenticatorData(a.flags|flagAttestedData, true)},
+	})
+
+	response := &RegistrationResponse{
+		ID:    EncodeURL(a.credentialID),
+		RawID: EncodeURL(a.credentialID),
+		Type:  "public-key",
+	}
+
+	response.Response.ClientDataJSON = EncodeURL(a.clientData(t, CeremonyRegistration, challenge))
+	response.Response.AttestationObject = EncodeURL(attestationObject)
+	return response
+}
+
+// assert answers navigator.credentials.get, counter is increased on every assertion
+// unless the authenticator does not implement it
+func (a *softAuthenticator) assert(t *testing.T, challenge string) *AssertionResponse {
+	t.Helper()
+
+	if !a.withoutCounter {
+		a.signCount++
+	}
+
+	authData := a.authenticatorData(a.flags, false)
+	clientData := a.clientData(t, CeremonyAssertion, challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := &AssertionResponse{
+		ID:    EncodeURL(a.credentialID),
+		RawID: EncodeURL(a.credentialID),
+		Type:  "public-key",
+	}
+
+	response.Response.ClientDataJSON = EncodeURL(clientData)
+	response.Response.AuthenticatorData = EncodeURL(authData)
+	response.Response.Signature = EncodeURL(signature)
+	return response
+}
+
+func newTestChallenge(t *testing.T) string {
+	t.Helper()
+
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return challenge
+}
+
+// registered returns authenticator together with credential stored by relying party
+func registered(t *testing.T) (*softAuthenticator, *Credential) {
+	t.Helper()
+
+	authenticator := newSoftAuthenticator(t)
+	challenge := newTestChallenge(t)
+	credential, err := testRP.VerifyRegistration(authenticator.register(t, challenge), challenge, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return authenticator, credential
+}
+
+func TestRegistration(t *testing.T) {
+	authenticator, credential := registered(t)
+	if !bytes.Equal(credential.ID, authenticator.credentialID) {
+		t.Fatalf("credential id = %x, want %x", credential.ID, authenticator.credentialID)
+	}
+
+	publicKey, err := ParsePublicKey(credential.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if publicKey.Algorithm != AlgES256 || !authenticator.key.PublicKey.Equal(publicKey.Key) {
+		t.Fatal("credential public key does not match authenticator key")
+	}
+
+	if !credential.UserVerified || credential.SignCount != 0 {
+		t.Fatalf("credential = %+v", credential)
+	}
+}
+
+func TestAssertion(t *testing.T) {
+	authenticator, credential := registered(t)
+	signCount := credential.SignCount
+	for i := 0; i < 2; i++ {
+		challenge := newTestChallenge(t)
+		result, err := testRP.VerifyAssertion(authenticator.assert(t, challenge), challenge, credential.PublicKey, signCount, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(result.CredentialID, authenticator.credentialID) || result.SignCount != signCount+1 {
+			t.Fatalf("result = %+v", result)
+		}
+
+		signCount = result.SignCount
+	}
+}
+
+func TestAssertionWithoutCounter(t *testing.T) {
+	// authenticators which do not implement counters always send zero
+	authenticator, credential := registered(t)
+	authenticator.withoutCounter = true
+	for i := 0; i < 2; i++ {
+		challenge := newTestChallenge(t)
+		if _, err := testRP.VerifyAssertion(authenticator.assert(t, challenge), challenge, credential.PublicKey, 0, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// ceremonyTests are invalid responses shared by registration and assertion
+var ceremonyTests = []struct {
+	name      string
+	mutate    func(a *softAuthenticator)
+	challenge string
+	requireUV bool
+	want      error
+}{
+	{
+		name:   "wrong origin",
+		mutate: func(a *softAuthenticator) { a.origin = "https://attacker.example.com" },
+		want:   ErrInvalidOrigin,
+	},
+	{
+		name:   "wrong rp id hash",
+		mutate: func(a *softAuthenticator) { a.rpID = "attacker.example.com" },
+		want:   ErrInvalidRPID,
+	},
+	{
+		name:   "user not present",
+		mutate: func(a *softAuthenticator) { a.flags &^= flagUserPresent },
+		want:   ErrUserNotPresent,
+	},
+	{
+		name:      "user not verified",
+		mutate:    func(a *softAuthenticator) { a.flags &^= flagUserVerified },
+		requireUV: true,
+		want:      ErrUserNotVerified,
+	},
+	{
+		name:      "wrong challenge",
+		challenge: "b3RoZXItY2hhbGxlbmdl",
+		want:      ErrInvalidChallenge,
+	},
+	{
+		name:   "wrong ceremony",
+		mutate: func(a *softAuthenticator) { a.ceremony = "payment.get" },
+		want:   ErrInvalidCeremony,
+	},
+}
+
+func TestRegistrationRejectsInvalidResponses(t *testing.T) {
+	for _, test := range ceremonyTests {
+		t.Run(test.name, func(t *testing.T) {
+			authenticator := newSoftAuthenticator(t)
+			if test.mutate != nil {
+				test.mutate(authenticator)
+			}
+
+			challenge := newTestChallenge(t)
+			responseChallenge := challenge
+			if test.challenge != "" {
+				responseChallenge = test.challenge
+			}
+
+			_, err := testRP.VerifyRegistration(authenticator.register(t, responseChallenge), challenge, test.requireUV)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("err = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestAssertionRejectsInvalidResponses(t *testing.T) {
+	for _, test := range ceremonyTests {
+		t.Run(test.name, func(t *testing.T) {
+			authenticator, credential := registered(t)
+			if test.mutate != nil {
+				test.mutate(authenticator)
+			}
+
+			challenge := newTestChallenge(t)
+			responseChallenge := challenge
+			if test.challenge != "" {
+				responseChallenge = test.challenge
+			}
+
+			response := authenticator.assert(t, responseChallenge)
+			_, err := testRP.VerifyAssertion(response, challenge, credential.PublicKey, credential.SignCount, test.requireUV)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("err = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestAssertionRejectsSignCountRollback(t *testing.T) {
+	authenticator, credential := registered(t)
+	for _, storedCount := range []uint32{5, 6} {
+		authenticator.signCount = 4
+		challenge := newTestChallenge(t)
+
+		// authenticator sends 5 which is not greater than stored counter
+		response := authenticator.assert(t, challenge)
+		_, err := testRP.VerifyAssertion(response, challenge, credential.PublicKey, storedCount, true)
+		if !errors.Is(err, ErrSignCountRollback) {
+			t.Fatalf("stored %d: err = %v, want %v", storedCount, err, ErrSignCountRollback)
+		}
+	}
+}
+
+func TestAssertionRejectsInvalidSignature(t *testing.T) {
+	authenticator, credential := registered(t)
+
+	t.Run("other key", func(t *testing.T) {
+		other := newSoftAuthenticator(t)
+		other.credentialID = authenticator.credentialID
+		challenge := newTestChallenge(t)
+
+		_, err := testRP.VerifyAssertion(other.assert(t, challenge), challenge, credential.PublicKey, credential.SignCount, true)
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("err = %v, want %v", err, ErrInvalidSignature)
+		}
+	})
+
+	t.Run("tampered authenticator data", func(t *testing.T) {
+		challenge := newTestChallenge(t)
+		response := authenticator.assert(t, challenge)
+
+		// counter is raised after signing
+		authData, _ := DecodeURL(response.Response.AuthenticatorData)
+		binary.BigEndian.PutUint32(authData[33:37], 1000)
+		response.Response.AuthenticatorData = EncodeURL(authData)
+
+		_, err := testRP.VerifyAssertion(response, challenge, credential.PublicKey, credential.SignCount, true)
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("err = %v, want %v", err, ErrInvalidSignature)
+		}
+	})
+}
+
+func TestChallengeOf(t *testing.T) {
+	authenticator := newSoftAuthenticator(t)
+	challenge := newTestChallenge(t)
+	response := authenticator.assert(t, challenge)
+
+	found, err := ChallengeOf(response.Response.ClientDataJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found != challenge {
+		t.Fatalf("challenge = %s, want %s", found, challenge)
+	}
+}
+
+func TestParseAuthenticatorDataRejectsTruncatedData(t *testing.T) {
+	authenticator := newSoftAuthenticator(t)
+	authData := authenticator.authenticatorData(flagUserPresent|flagAttestedData, true)
+	for _, size := range []int{0, 36, 37, 54, 60, len(authData) - 1} {
+		if _, err := ParseAuthenticatorData(authData[:size]); !errors.Is(err, ErrMalformedData) {
+			t.Fatalf("size %d: err = %v, want %v", size, err, ErrMalformedData)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters as per RFC 6238 which are supported by all authenticator apps
+const (
+	TOTPPeriod     = 30 * time.Second
+	TOTPDigits     = 6
+	TOTPSkew       = 1 // steps allowed before and after current
+	totpSecretSize = 20
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return base32NoPadding.EncodeToString(secret), nil
+}
+
+// TOTPURI builds otpauth URI which authenticator apps accept as QR code
+func TOTPURI(issuer string, accountName string, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	params.Set("period", fmt.Sprintf("%d", int(TOTPPeriod.Seconds())))
+
+	label := url.PathEscape(fmt.Sprintf("%s:%s", issuer, accountName))
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, params.Encode())
+}
+
+func TOTPStep(now time.Time) int64 {
+	return now.Unix() / int64(TOTPPeriod.Seconds())
+}
+
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", TOTPDigits, value%modulo), nil
+}
+
+// ValidateTOTP checks code against current time step with allowed skew,
+// matched step is returned so callers can reject replayed codes.
+func ValidateTOTP(secret string, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	currentStep := TOTPStep(now)
+	for step := currentStep - TOTPSkew; step <= currentStep+TOTPSkew; step++ {
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// GenerateRecoveryCodes generates one-time codes formatted as xxxxx-xxxxx
+func GenerateRecoveryCodes(count int) ([]string, error) {
+	codes := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(base32NoPadding.EncodeToString(raw))[:10]
+		codes = append(codes, fmt.Sprintf("%s-%s", code[:5], code[5:]))
+	}
+
+	return codes, nil
+}
+
+// HashToken hashes high entropy secrets like recovery codes,
+// unlike passwords they do not need slow hashing.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}